@@ -0,0 +1,156 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+)
+
+// States of an alert.
+const (
+	// The condition holds, but not for the duration of the rule yet.
+	StatePending = "pending"
+	// The condition held for the duration of the rule.
+	StateFiring = "firing"
+	// The condition stopped holding after the alert fired.
+	StateResolved = "resolved"
+)
+
+type alertKey struct {
+	rule          string
+	containerName string
+}
+
+// Evaluates the rules and keeps track of the alerts.
+type Engine struct {
+	rules []*Rule
+	lock  sync.Mutex
+	// The alerts whose condition holds.
+	alerts map[alertKey]*v2.Alert
+}
+
+func NewEngine(rules []*Rule) *Engine {
+	return &Engine{
+		rules:  rules,
+		alerts: make(map[alertKey]*v2.Alert),
+	}
+}
+
+// Evaluates the rules on the samples of the containers, returning the events
+// of the alerts that started or stopped firing. The alerts of the containers
+// without a sample are resolved.
+func (self *Engine) Evaluate(samples []*Sample, now time.Time) []*info.Event {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var events []*info.Event
+	evaluated := make(map[alertKey]bool)
+	for _, rule := range self.rules {
+		for _, sample := range samples {
+			if !rule.appliesTo(sample) {
+				continue
+			}
+			key := alertKey{rule: rule.Name, containerName: sample.Name}
+			evaluated[key] = true
+			value, holds, ok := rule.Condition.Evaluate(sample)
+			alert, active := self.alerts[key]
+			if !ok || !holds {
+				if active {
+					if alert.State == StateFiring {
+						events = append(events, newAlertEvent(alert, StateResolved, value, now))
+					}
+					delete(self.alerts, key)
+				}
+				continue
+			}
+			if !active {
+				alert = &v2.Alert{
+					Rule:          rule.Name,
+					ContainerName: sample.Name,
+					Expression:    rule.Condition.String(),
+					State:         StatePending,
+					ActiveSince:   now,
+				}
+				self.alerts[key] = alert
+			}
+			alert.Value = value
+			if alert.State == StatePending && now.Sub(alert.ActiveSince) >= rule.For {
+				alert.State = StateFiring
+				alert.FiringSince = now
+				events = append(events, newAlertEvent(alert, StateFiring, value, now))
+			}
+		}
+	}
+	// Resolve the alerts of the containers that went away and of the rules
+	// that do not apply to their containers anymore.
+	for key, alert := range self.alerts {
+		if evaluated[key] {
+			continue
+		}
+		if alert.State == StateFiring {
+			events = append(events, newAlertEvent(alert, StateResolved, 0, now))
+		}
+		delete(self.alerts, key)
+	}
+	return events
+}
+
+func newAlertEvent(alert *v2.Alert, state string, value float64, now time.Time) *info.Event {
+	return &info.Event{
+		ContainerName: alert.ContainerName,
+		Timestamp:     now,
+		EventType:     info.EventAlert,
+		EventData: info.EventData{
+			Alert: &info.AlertEventData{
+				Rule:        alert.Rule,
+				Expression:  alert.Expression,
+				State:       state,
+				Value:       value,
+				ActiveSince: alert.ActiveSince,
+			},
+		},
+	}
+}
+
+// Sorts by container name, then by rule.
+type byContainerAndRule []v2.Alert
+
+func (s byContainerAndRule) Len() int      { return len(s) }
+func (s byContainerAndRule) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byContainerAndRule) Less(i, j int) bool {
+	if s[i].ContainerName != s[j].ContainerName {
+		return s[i].ContainerName < s[j].ContainerName
+	}
+	return s[i].Rule < s[j].Rule
+}
+
+// Returns copies of the pending and firing alerts of the containers for which
+// include returns true.
+func (self *Engine) Alerts(include func(containerName string) bool) []v2.Alert {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	alerts := []v2.Alert{}
+	for _, alert := range self.alerts {
+		if include(alert.ContainerName) {
+			alerts = append(alerts, *alert)
+		}
+	}
+	sort.Sort(byContainerAndRule(alerts))
+	return alerts
+}
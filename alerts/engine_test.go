@@ -0,0 +1,155 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `{
+  "rules": [
+    {
+      "name": "MemoryNearLimit",
+      "expr": "memory_working_set / memory_limit > 0.8",
+      "for": "5m",
+      "labels": "app=web",
+      "container_name": "/docker",
+      "include_subcontainers": true
+    },
+    {
+      "name": "HighCpu",
+      "expr": "cpu_usage > 1"
+    }
+  ]
+}`
+
+func parseTestRules(t *testing.T) []*Rule {
+	rules, err := ParseRules([]byte(testRules))
+	require.NoError(t, err)
+	return rules
+}
+
+func TestParseRules(t *testing.T) {
+	rules := parseTestRules(t)
+	require.Equal(t, 2, len(rules))
+	assert.Equal(t, "MemoryNearLimit", rules[0].Name)
+	assert.Equal(t, 5*time.Minute, rules[0].For)
+	assert.Equal(t, "/docker", rules[0].ContainerName)
+	assert.True(t, rules[0].IncludeSubcontainers)
+	require.Equal(t, 1, len(rules[0].LabelSelector))
+	assert.Equal(t, time.Duration(0), rules[1].For)
+
+	for _, invalid := range []string{
+		`{"rules": [{"expr": "cpu_usage > 1"}]}`,
+		`{"rules": [{"name": "a", "expr": "cpu_usage > 1"}, {"name": "a", "expr": "cpu_usage > 2"}]}`,
+		`{"rules": [{"name": "a", "expr": "cpu_usage"}]}`,
+		`{"rules": [{"name": "a", "expr": "cpu_usage > 1", "for": "soon"}]}`,
+		`{"rules": [{"name": "a", "expr": "cpu_usage > 1", "labels": "=web"}]}`,
+		`{"rules": `,
+	} {
+		_, err := ParseRules([]byte(invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRuleAppliesTo(t *testing.T) {
+	rules := parseTestRules(t)
+	sample := newTestSample()
+	assert.False(t, rules[0].appliesTo(sample))
+	sample.Spec.Labels = map[string]string{"app": "web"}
+	assert.True(t, rules[0].appliesTo(sample))
+	sample.Name = "/system.slice/docker.service"
+	assert.False(t, rules[0].appliesTo(sample))
+	assert.True(t, rules[1].appliesTo(sample))
+}
+
+func TestEngineFiresAfterDuration(t *testing.T) {
+	engine := NewEngine(parseTestRules(t)[:1])
+	sample := newTestSample()
+	sample.Spec.Labels = map[string]string{"app": "web"}
+	start := time.Unix(1420000000, 0)
+
+	// Pending until the condition held for 5 minutes.
+	assert.Empty(t, engine.Evaluate([]*Sample{sample}, start))
+	assert.Empty(t, engine.Evaluate([]*Sample{sample}, start.Add(4*time.Minute)))
+	alerts := engine.Alerts(func(string) bool { return true })
+	require.Equal(t, 1, len(alerts))
+	assert.Equal(t, StatePending, alerts[0].State)
+	assert.Equal(t, "/docker/abc", alerts[0].ContainerName)
+	assert.True(t, start.Equal(alerts[0].ActiveSince))
+
+	firing := engine.Evaluate([]*Sample{sample}, start.Add(5*time.Minute))
+	require.Equal(t, 1, len(firing))
+	assert.Equal(t, info.EventType(info.EventAlert), firing[0].EventType)
+	assert.Equal(t, "/docker/abc", firing[0].ContainerName)
+	require.NotNil(t, firing[0].EventData.Alert)
+	assert.Equal(t, "MemoryNearLimit", firing[0].EventData.Alert.Rule)
+	assert.Equal(t, StateFiring, firing[0].EventData.Alert.State)
+	assert.InDelta(t, 900.0/1024, firing[0].EventData.Alert.Value, 1e-9)
+	alerts = engine.Alerts(func(string) bool { return true })
+	require.Equal(t, 1, len(alerts))
+	assert.Equal(t, StateFiring, alerts[0].State)
+
+	// Fires once.
+	assert.Empty(t, engine.Evaluate([]*Sample{sample}, start.Add(6*time.Minute)))
+
+	sample.Stats[1].Memory.WorkingSet = 100 << 20
+	resolved := engine.Evaluate([]*Sample{sample}, start.Add(7*time.Minute))
+	require.Equal(t, 1, len(resolved))
+	assert.Equal(t, StateResolved, resolved[0].EventData.Alert.State)
+	assert.True(t, start.Equal(resolved[0].EventData.Alert.ActiveSince))
+	assert.Empty(t, engine.Alerts(func(string) bool { return true }))
+}
+
+func TestEnginePendingAlertIsReset(t *testing.T) {
+	engine := NewEngine(parseTestRules(t)[:1])
+	sample := newTestSample()
+	sample.Spec.Labels = map[string]string{"app": "web"}
+	start := time.Unix(1420000000, 0)
+
+	engine.Evaluate([]*Sample{sample}, start)
+	// The condition stops holding before the alert fires, which is not reported.
+	sample.Stats[1].Memory.WorkingSet = 100 << 20
+	assert.Empty(t, engine.Evaluate([]*Sample{sample}, start.Add(time.Minute)))
+	sample.Stats[1].Memory.WorkingSet = 900 << 20
+	engine.Evaluate([]*Sample{sample}, start.Add(2*time.Minute))
+	assert.Empty(t, engine.Evaluate([]*Sample{sample}, start.Add(6*time.Minute)))
+	assert.Equal(t, 1, len(engine.Evaluate([]*Sample{sample}, start.Add(7*time.Minute))))
+}
+
+func TestEngineResolvesAlertsOfRemovedContainers(t *testing.T) {
+	engine := NewEngine(parseTestRules(t)[1:])
+	web := newTestSample()
+	db := newTestSample()
+	db.Name = "/docker/def"
+	now := time.Unix(1420000000, 0)
+
+	firing := engine.Evaluate([]*Sample{web, db}, now)
+	require.Equal(t, 2, len(firing))
+	alerts := engine.Alerts(func(name string) bool { return name == "/docker/def" })
+	require.Equal(t, 1, len(alerts))
+	assert.Equal(t, "HighCpu", alerts[0].Rule)
+
+	resolved := engine.Evaluate([]*Sample{web}, now.Add(time.Minute))
+	require.Equal(t, 1, len(resolved))
+	assert.Equal(t, "/docker/def", resolved[0].ContainerName)
+	assert.Equal(t, StateResolved, resolved[0].EventData.Alert.State)
+	assert.Equal(t, 1, len(engine.Alerts(func(string) bool { return true })))
+}
@@ -0,0 +1,327 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A condition compares two arithmetic expressions of the metrics of a
+// container and numbers, e.g.: "memory_working_set / memory_limit > 0.9" or
+// "cpu_usage_minute_mean >= 1.5". Numbers may have a unit suffix, e.g.:
+// "memory_usage > 512Mi".
+type Condition struct {
+	text       string
+	left       expression
+	comparator string
+	right      expression
+}
+
+// An arithmetic expression.
+type expression interface {
+	// Returns the value of the expression for the sample, or false when a
+	// metric is not available or a division by zero occurs.
+	evaluate(sample *Sample) (float64, bool)
+}
+
+type number float64
+
+func (self number) evaluate(sample *Sample) (float64, bool) {
+	return float64(self), true
+}
+
+type metricExpression string
+
+func (self metricExpression) evaluate(sample *Sample) (float64, bool) {
+	return metrics[string(self)](sample)
+}
+
+type negation struct {
+	operand expression
+}
+
+func (self *negation) evaluate(sample *Sample) (float64, bool) {
+	value, ok := self.operand.evaluate(sample)
+	return -value, ok
+}
+
+type binaryExpression struct {
+	operator    string
+	left, right expression
+}
+
+func (self *binaryExpression) evaluate(sample *Sample) (float64, bool) {
+	left, ok := self.left.evaluate(sample)
+	if !ok {
+		return 0, false
+	}
+	right, ok := self.right.evaluate(sample)
+	if !ok {
+		return 0, false
+	}
+	switch self.operator {
+	case "+":
+		return left + right, true
+	case "-":
+		return left - right, true
+	case "*":
+		return left * right, true
+	case "/":
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+	return 0, false
+}
+
+// Multipliers of the unit suffixes of numbers.
+var unitSuffixes = map[string]float64{
+	"k":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+}
+
+var comparators = map[string]bool{
+	">":  true,
+	">=": true,
+	"<":  true,
+	"<=": true,
+	"==": true,
+	"!=": true,
+}
+
+type tokenKind int
+
+const (
+	numberToken tokenKind = iota
+	identifierToken
+	operatorToken
+	endToken
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+}
+
+// Splits a condition into tokens.
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			value, err := strconv.ParseFloat(string(runes[start:i]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", string(runes[start:i]))
+			}
+			suffixStart := i
+			for i < len(runes) && unicode.IsLetter(runes[i]) {
+				i++
+			}
+			if suffix := string(runes[suffixStart:i]); suffix != "" {
+				multiplier, ok := unitSuffixes[suffix]
+				if !ok {
+					return nil, fmt.Errorf("unknown unit %q", suffix)
+				}
+				value *= multiplier
+			}
+			tokens = append(tokens, token{kind: numberToken, text: string(runes[start:i]), value: value})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: identifierToken, text: string(runes[start:i])})
+		default:
+			operator := string(r)
+			if i+1 < len(runes) && comparators[string(runes[i:i+2])] {
+				operator = string(runes[i : i+2])
+			}
+			if !strings.Contains("+-*/()", operator) && !comparators[operator] {
+				return nil, fmt.Errorf("unexpected %q", operator)
+			}
+			tokens = append(tokens, token{kind: operatorToken, text: operator})
+			i += len(operator)
+		}
+	}
+	return append(tokens, token{kind: endToken}), nil
+}
+
+// A recursive descent parser of conditions.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (self *parser) peek() token {
+	return self.tokens[self.pos]
+}
+
+func (self *parser) next() token {
+	t := self.tokens[self.pos]
+	if t.kind != endToken {
+		self.pos++
+	}
+	return t
+}
+
+// sum := product (("+" | "-") product)*
+func (self *parser) parseSum() (expression, error) {
+	left, err := self.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for t := self.peek(); t.kind == operatorToken && (t.text == "+" || t.text == "-"); t = self.peek() {
+		self.next()
+		right, err := self.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpression{operator: t.text, left: left, right: right}
+	}
+	return left, nil
+}
+
+// product := unary (("*" | "/") unary)*
+func (self *parser) parseProduct() (expression, error) {
+	left, err := self.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for t := self.peek(); t.kind == operatorToken && (t.text == "*" || t.text == "/"); t = self.peek() {
+		self.next()
+		right, err := self.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpression{operator: t.text, left: left, right: right}
+	}
+	return left, nil
+}
+
+// unary := "-" unary | number | metric | "(" sum ")"
+func (self *parser) parseUnary() (expression, error) {
+	t := self.next()
+	switch t.kind {
+	case numberToken:
+		return number(t.value), nil
+	case identifierToken:
+		if _, ok := metrics[t.text]; !ok {
+			return nil, fmt.Errorf("unknown metric %q", t.text)
+		}
+		return metricExpression(t.text), nil
+	case operatorToken:
+		switch t.text {
+		case "-":
+			operand, err := self.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &negation{operand: operand}, nil
+		case "(":
+			inner, err := self.parseSum()
+			if err != nil {
+				return nil, err
+			}
+			if closing := self.next(); closing.text != ")" {
+				return nil, fmt.Errorf("expected \")\", got %q", closing.text)
+			}
+			return inner, nil
+		}
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	return nil, fmt.Errorf("unexpected end of condition")
+}
+
+// Parses a condition: an expression, a comparator (one of >, >=, <, <=, ==
+// and !=) and another expression.
+func ParseCondition(text string) (*Condition, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %v", text, err)
+	}
+	p := &parser{tokens: tokens}
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %v", text, err)
+	}
+	comparator := p.next()
+	if !comparators[comparator.text] {
+		return nil, fmt.Errorf("invalid condition %q: expected a comparison", text)
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %v", text, err)
+	}
+	if rest := p.next(); rest.kind != endToken {
+		return nil, fmt.Errorf("invalid condition %q: unexpected %q", text, rest.text)
+	}
+	return &Condition{
+		text:       text,
+		left:       left,
+		comparator: comparator.text,
+		right:      right,
+	}, nil
+}
+
+// Returns the value of the left side of the condition and whether the
+// condition holds, or false when it could not be evaluated.
+func (self *Condition) Evaluate(sample *Sample) (value float64, holds bool, ok bool) {
+	left, ok := self.left.evaluate(sample)
+	if !ok {
+		return 0, false, false
+	}
+	right, ok := self.right.evaluate(sample)
+	if !ok {
+		return 0, false, false
+	}
+	switch self.comparator {
+	case ">":
+		holds = left > right
+	case ">=":
+		holds = left >= right
+	case "<":
+		holds = left < right
+	case "<=":
+		holds = left <= right
+	case "==":
+		holds = left == right
+	case "!=":
+		holds = left != right
+	}
+	return left, holds, true
+}
+
+func (self *Condition) String() string {
+	return self.text
+}
@@ -0,0 +1,126 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a sample of a container using 1.5 cores and 900MiB of its 1GiB of
+// memory.
+func newTestSample() *Sample {
+	start := time.Unix(1420000000, 0)
+	previous := &info.ContainerStats{Timestamp: start}
+	previous.Cpu.Usage.Total = 1e9
+	previous.Cpu.CFS.Periods = 100
+	previous.Cpu.CFS.ThrottledPeriods = 10
+	previous.Network.RxBytes = 1000
+	current := &info.ContainerStats{Timestamp: start.Add(2 * time.Second)}
+	current.Cpu.Usage.Total = 4e9
+	current.Cpu.CFS.Periods = 120
+	current.Cpu.CFS.ThrottledPeriods = 15
+	current.Network.RxBytes = 5000
+	current.Memory.Usage = 1000 << 20
+	current.Memory.WorkingSet = 900 << 20
+	current.Filesystem = []info.FsStats{
+		{Device: "sda1", Limit: 1000, Usage: 300},
+		{Device: "sdb1", Limit: 1000, Usage: 500},
+	}
+	sample := &Sample{
+		ContainerReference: info.ContainerReference{Name: "/docker/abc"},
+		Stats:              []*info.ContainerStats{previous, current},
+		Derived:            &v2.DerivedStats{},
+	}
+	sample.Spec.HasMemory = true
+	sample.Spec.Memory.Limit = 1 << 30
+	sample.Derived.MinuteUsage.Cpu = v2.Percentiles{Present: true, Mean: 1200, Ninety: 1800}
+	return sample
+}
+
+func TestConditionEvaluate(t *testing.T) {
+	sample := newTestSample()
+	cases := []struct {
+		condition string
+		value     float64
+		holds     bool
+	}{
+		{"memory_working_set / memory_limit > 0.8", 900.0 / 1024, true},
+		{"memory_working_set / memory_limit > 0.9", 900.0 / 1024, false},
+		{"memory_usage >= 1000Mi", 1000 << 20, true},
+		{"memory_usage < 1G", 1000 << 20, false},
+		{"cpu_usage > 1", 1.5, true},
+		{"cpu_throttled_ratio == 0.25", 0.25, true},
+		{"fs_usage / fs_limit != 0.4", 0.4, false},
+		{"network_rx_bytes_rate <= 2k", 2000, true},
+		{"cpu_usage_minute_mean > 1", 1.2, true},
+		{"cpu_usage_minute_ninety - cpu_usage_minute_mean > 0.5", 0.6, true},
+		{"(memory_limit - memory_working_set) / 1Mi < 128", 124, true},
+		{"-cpu_usage < -1", -1.5, true},
+		{"2 * 3 + 1 == 7", 7, true},
+		{"2 * (3 + 1) == 8", 8, true},
+	}
+	for _, c := range cases {
+		condition, err := ParseCondition(c.condition)
+		require.NoError(t, err, c.condition)
+		value, holds, ok := condition.Evaluate(sample)
+		require.True(t, ok, c.condition)
+		assert.InDelta(t, c.value, value, 1e-9, c.condition)
+		assert.Equal(t, c.holds, holds, c.condition)
+		assert.Equal(t, c.condition, condition.String())
+	}
+}
+
+func TestConditionNotEvaluated(t *testing.T) {
+	sample := newTestSample()
+	sample.Stats = sample.Stats[1:]
+	sample.Derived = nil
+	sample.Spec.HasMemory = false
+	for _, text := range []string{
+		// Rates need two stats.
+		"cpu_usage > 1",
+		"cpu_usage_hour_max > 1",
+		"memory_usage / memory_limit > 0.5",
+		"memory_usage / 0 > 1",
+	} {
+		condition, err := ParseCondition(text)
+		require.NoError(t, err, text)
+		_, _, ok := condition.Evaluate(sample)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestParseInvalidCondition(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"memory_usage",
+		"memory_usage > ",
+		"memory_usage > 1 > 2",
+		"memory_usage = 1",
+		"unknown_metric > 1",
+		"memory_usage > 1Xi",
+		"(memory_usage > 1",
+		"memory_usage > 1..2",
+		"memory_usage > 1 1",
+	} {
+		_, err := ParseCondition(text)
+		assert.Error(t, err, text)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerts
+
+import (
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+)
+
+// What the rules are evaluated on for a container.
+type Sample struct {
+	info.ContainerReference
+	Spec info.ContainerSpec
+	// The most recent stats, oldest first. Rates need the last two.
+	Stats []*info.ContainerStats
+	// Summary of the recent usage. Nil when it is not available.
+	Derived *v2.DerivedStats
+}
+
+// Returns the latest stats.
+func (self *Sample) latest() (*info.ContainerStats, bool) {
+	if len(self.Stats) == 0 {
+		return nil, false
+	}
+	return self.Stats[len(self.Stats)-1], true
+}
+
+// Returns the latest two stats and the seconds between them.
+func (self *Sample) lastTwo() (*info.ContainerStats, *info.ContainerStats, float64, bool) {
+	if len(self.Stats) < 2 {
+		return nil, nil, 0, false
+	}
+	previous, current := self.Stats[len(self.Stats)-2], self.Stats[len(self.Stats)-1]
+	seconds := current.Timestamp.Sub(previous.Timestamp).Seconds()
+	if seconds <= 0 {
+		return nil, nil, 0, false
+	}
+	return previous, current, seconds, true
+}
+
+type metric func(sample *Sample) (float64, bool)
+
+// Returns a metric of the latest stats.
+func latestMetric(f func(stats *info.ContainerStats) float64) metric {
+	return func(sample *Sample) (float64, bool) {
+		stats, ok := sample.latest()
+		if !ok {
+			return 0, false
+		}
+		return f(stats), true
+	}
+}
+
+// Returns the rate per second of a counter of the stats.
+func rateMetric(counter func(stats *info.ContainerStats) uint64) metric {
+	return func(sample *Sample) (float64, bool) {
+		previous, current, seconds, ok := sample.lastTwo()
+		if !ok || counter(current) < counter(previous) {
+			return 0, false
+		}
+		return float64(counter(current)-counter(previous)) / seconds, true
+	}
+}
+
+// Returns a metric of the summary of the usage.
+func derivedMetric(f func(derived *v2.DerivedStats) v2.Percentiles, percentile func(p v2.Percentiles) uint64, scale float64) metric {
+	return func(sample *Sample) (float64, bool) {
+		if sample.Derived == nil {
+			return 0, false
+		}
+		p := f(sample.Derived)
+		if !p.Present {
+			return 0, false
+		}
+		return float64(percentile(p)) * scale, true
+	}
+}
+
+// The metrics conditions can use, by name. CPU usage is in cores, memory and
+// filesystem usage in bytes.
+var metrics = map[string]metric{
+	"memory_usage":       latestMetric(func(s *info.ContainerStats) float64 { return float64(s.Memory.Usage) }),
+	"memory_working_set": latestMetric(func(s *info.ContainerStats) float64 { return float64(s.Memory.WorkingSet) }),
+	"memory_rss":         latestMetric(func(s *info.ContainerStats) float64 { return float64(s.Memory.RSS) }),
+	"memory_cache":       latestMetric(func(s *info.ContainerStats) float64 { return float64(s.Memory.Cache) }),
+	"memory_swap":        latestMetric(func(s *info.ContainerStats) float64 { return float64(s.Memory.Swap) }),
+	"memory_failcnt":     latestMetric(func(s *info.ContainerStats) float64 { return float64(s.Memory.Failcnt) }),
+	"memory_limit": func(sample *Sample) (float64, bool) {
+		if !sample.Spec.HasMemory {
+			return 0, false
+		}
+		return float64(sample.Spec.Memory.Limit), true
+	},
+	"cpu_usage": func(sample *Sample) (float64, bool) {
+		usage, ok := rateMetric(func(s *info.ContainerStats) uint64 { return s.Cpu.Usage.Total })(sample)
+		// From nanoseconds per second to cores.
+		return usage / 1e9, ok
+	},
+	"cpu_throttled_ratio": func(sample *Sample) (float64, bool) {
+		previous, current, _, ok := sample.lastTwo()
+		if !ok || current.Cpu.CFS.Periods <= previous.Cpu.CFS.Periods || current.Cpu.CFS.ThrottledPeriods < previous.Cpu.CFS.ThrottledPeriods {
+			return 0, false
+		}
+		return float64(current.Cpu.CFS.ThrottledPeriods-previous.Cpu.CFS.ThrottledPeriods) / float64(current.Cpu.CFS.Periods-previous.Cpu.CFS.Periods), true
+	},
+	"fs_usage": latestMetric(func(s *info.ContainerStats) float64 {
+		var usage uint64
+		for _, fs := range s.Filesystem {
+			usage += fs.Usage
+		}
+		return float64(usage)
+	}),
+	"fs_limit": latestMetric(func(s *info.ContainerStats) float64 {
+		var limit uint64
+		for _, fs := range s.Filesystem {
+			limit += fs.Limit
+		}
+		return float64(limit)
+	}),
+	"network_rx_bytes_rate":  rateMetric(func(s *info.ContainerStats) uint64 { return s.Network.RxBytes }),
+	"network_tx_bytes_rate":  rateMetric(func(s *info.ContainerStats) uint64 { return s.Network.TxBytes }),
+	"network_rx_errors_rate": rateMetric(func(s *info.ContainerStats) uint64 { return s.Network.RxErrors }),
+	"network_tx_errors_rate": rateMetric(func(s *info.ContainerStats) uint64 { return s.Network.TxErrors }),
+}
+
+// Adds the summaries of the usage, e.g.: cpu_usage_minute_mean or
+// memory_usage_hour_ninety.
+func init() {
+	windows := map[string]func(d *v2.DerivedStats) v2.Usage{
+		"minute": func(d *v2.DerivedStats) v2.Usage { return d.MinuteUsage },
+		"hour":   func(d *v2.DerivedStats) v2.Usage { return d.HourUsage },
+		"day":    func(d *v2.DerivedStats) v2.Usage { return d.DayUsage },
+	}
+	percentiles := map[string]func(p v2.Percentiles) uint64{
+		"mean":       func(p v2.Percentiles) uint64 { return p.Mean },
+		"max":        func(p v2.Percentiles) uint64 { return p.Max },
+		"fifty":      func(p v2.Percentiles) uint64 { return p.Fifty },
+		"ninety":     func(p v2.Percentiles) uint64 { return p.Ninety },
+		"ninetyfive": func(p v2.Percentiles) uint64 { return p.NinetyFive },
+	}
+	for windowName, window := range windows {
+		window := window
+		for percentileName, percentile := range percentiles {
+			// CPU summaries are in millicores.
+			metrics["cpu_usage_"+windowName+"_"+percentileName] = derivedMetric(func(d *v2.DerivedStats) v2.Percentiles { return window(d).Cpu }, percentile, 1e-3)
+			metrics["memory_usage_"+windowName+"_"+percentileName] = derivedMetric(func(d *v2.DerivedStats) v2.Percentiles { return window(d).Memory }, percentile, 1)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package alerts evaluates alerting rules on the stats of the containers.
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/events"
+)
+
+// Configuration of the alerting rules.
+type rulesConfiguration struct {
+	Rules []ruleConfig `json:"rules"`
+}
+
+type ruleConfig struct {
+	// Unique name of the rule, e.g.: "MemoryNearLimit".
+	Name string `json:"name"`
+	// The condition, e.g.: "memory_working_set / memory_limit > 0.9".
+	Expr string `json:"expr"`
+	// How long the condition has to hold before the alert fires, e.g.: "5m".
+	// It fires at the first evaluation when empty.
+	For string `json:"for,omitempty"`
+	// Label selector of the containers the rule applies to, e.g.: "app=web".
+	Labels string `json:"labels,omitempty"`
+	// Absolute name of the container the rule applies to. All when empty.
+	ContainerName string `json:"container_name,omitempty"`
+	// Whether the rule applies to the subcontainers of the container.
+	IncludeSubcontainers bool `json:"include_subcontainers,omitempty"`
+}
+
+// A rule raising an alert for the containers its condition holds for.
+type Rule struct {
+	Name                 string
+	Condition            *Condition
+	For                  time.Duration
+	LabelSelector        events.LabelSelector
+	ContainerName        string
+	IncludeSubcontainers bool
+}
+
+// Parses the JSON configuration of the rules.
+func ParseRules(content []byte) ([]*Rule, error) {
+	config := &rulesConfiguration{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("invalid alerting rules configuration: %v", err)
+	}
+	names := make(map[string]bool, len(config.Rules))
+	rules := make([]*Rule, 0, len(config.Rules))
+	for i, ruleConfig := range config.Rules {
+		if ruleConfig.Name == "" {
+			return nil, fmt.Errorf("alerting rule %d has no name", i)
+		}
+		if names[ruleConfig.Name] {
+			return nil, fmt.Errorf("duplicate alerting rule %q", ruleConfig.Name)
+		}
+		names[ruleConfig.Name] = true
+		rule, err := ruleConfig.rule()
+		if err != nil {
+			return nil, fmt.Errorf("invalid alerting rule %q: %v", ruleConfig.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (self *ruleConfig) rule() (*Rule, error) {
+	condition, err := ParseCondition(self.Expr)
+	if err != nil {
+		return nil, err
+	}
+	rule := &Rule{
+		Name:                 self.Name,
+		Condition:            condition,
+		ContainerName:        self.ContainerName,
+		IncludeSubcontainers: self.IncludeSubcontainers,
+	}
+	if self.For != "" {
+		rule.For, err = time.ParseDuration(self.For)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %v", self.For, err)
+		}
+	}
+	rule.LabelSelector, err = events.ParseLabelSelector(self.Labels)
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Returns whether the rule applies to the container of the sample.
+func (self *Rule) appliesTo(sample *Sample) bool {
+	if self.ContainerName != "" && sample.Name != self.ContainerName {
+		if !self.IncludeSubcontainers {
+			return false
+		}
+		if self.ContainerName != "/" && !strings.HasPrefix(sample.Name, self.ContainerName+"/") {
+			return false
+		}
+	}
+	return self.LabelSelector.Matches(sample.Spec.Labels)
+}
@@ -17,7 +17,6 @@ package api
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -49,12 +48,26 @@ func RegisterHandlers(mux httpMux.Mux, m manager.Manager) error {
 	mux.HandleFunc(apiResource, func(w http.ResponseWriter, r *http.Request) {
 		err := handleRequest(supportedApiVersions, m, w, r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			status := 500
+			if httpErr, ok := err.(*httpError); ok {
+				status = httpErr.status
+			}
+			http.Error(w, err.Error(), status)
 		}
 	})
 	return nil
 }
 
+// An error of a request that is answered with a status other than 500.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (self *httpError) Error() string {
+	return self.err.Error()
+}
+
 // Captures the API version, requestType [optional], and remaining request [optional].
 var apiRegexp = regexp.MustCompile("/api/([^/]+)/?([^/]+)?(.*)")
 
@@ -133,36 +146,6 @@ func writeResult(res interface{}, w http.ResponseWriter) error {
 
 }
 
-func streamResults(eventChannel *events.EventChannel, w http.ResponseWriter, r *http.Request, m manager.Manager) error {
-	cn, ok := w.(http.CloseNotifier)
-	if !ok {
-		return errors.New("could not access http.CloseNotifier")
-	}
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		return errors.New("could not access http.Flusher")
-	}
-
-	w.Header().Set("Transfer-Encoding", "chunked")
-	w.WriteHeader(http.StatusOK)
-	flusher.Flush()
-
-	enc := json.NewEncoder(w)
-	for {
-		select {
-		case <-cn.CloseNotify():
-			m.CloseEventChannel(eventChannel.GetWatchId())
-			return nil
-		case ev := <-eventChannel.GetChannel():
-			err := enc.Encode(ev)
-			if err != nil {
-				glog.Errorf("error encoding message %+v for result stream: %v", ev, err)
-			}
-			flusher.Flush()
-		}
-	}
-}
-
 func getContainerInfoRequest(body io.ReadCloser) (*info.ContainerInfoRequest, error) {
 	query := info.DefaultContainerInfoRequest()
 	decoder := json.NewDecoder(body)
@@ -178,8 +161,13 @@ func getContainerInfoRequest(body io.ReadCloser) (*info.ContainerInfoRequest, er
 // with any twice defined arguments being assigned the first value.
 // If the value type for the argument is wrong the field will be assumed to be
 // unassigned
-// bools: stream, subcontainers, oom_events, creation_events, deletion_events
+// bools: stream, subcontainers, oom_events, creation_events, deletion_events,
+// start_events, stop_events, exit_events, restart_events, pause_events,
+// unpause_events, health_events, alert_events, memory_limit_approached_events,
+// cpu_throttled_events, fs_nearly_full_events
 // ints: max_events, start_time (unix timestamp), end_time (unix timestamp)
+// strings: labels (label selector, ex. app=web,tier!=db), alias (glob pattern
+// of docker aliases, ex. web-*), namespace (ex. docker)
 // example r.URL: http://localhost:8080/api/v1.3/events?oom_events=true&stream=true
 func getEventRequest(r *http.Request) (*events.Request, bool, error) {
 	query := events.NewRequest()
@@ -199,12 +187,6 @@ func getEventRequest(r *http.Request) (*events.Request, bool, error) {
 			query.IncludeSubcontainers = newBool
 		}
 	}
-	eventTypes := map[string]info.EventType{
-		"oom_events":      info.EventOom,
-		"oom_kill_events": info.EventOomKill,
-		"creation_events": info.EventContainerCreation,
-		"deletion_events": info.EventContainerDeletion,
-	}
 	allEventTypes := false
 	if val, ok := urlMap["all_events"]; ok {
 		newBool, err := strconv.ParseBool(val[0])
@@ -212,7 +194,7 @@ func getEventRequest(r *http.Request) (*events.Request, bool, error) {
 			allEventTypes = newBool
 		}
 	}
-	for opt, eventType := range eventTypes {
+	for opt, eventType := range events.EventTypeParams {
 		if allEventTypes {
 			query.EventType[eventType] = true
 		} else if val, ok := urlMap[opt]; ok {
@@ -240,10 +222,67 @@ func getEventRequest(r *http.Request) (*events.Request, bool, error) {
 			query.EndTime = newTime
 		}
 	}
+	if val, ok := urlMap["labels"]; ok {
+		selector, err := events.ParseLabelSelector(val[0])
+		if err != nil {
+			return nil, false, err
+		}
+		query.LabelSelector = selector
+	}
+	if val, ok := urlMap["alias"]; ok {
+		if err := events.ValidateAliasPattern(val[0]); err != nil {
+			return nil, false, err
+		}
+		query.AliasPattern = val[0]
+	}
+	if val, ok := urlMap["namespace"]; ok {
+		query.Namespace = val[0]
+	}
 
 	return query, stream, nil
 }
 
+// Takes the arguments of getEventRequest to select the events to count, and:
+// durations: window (only count the events of this past duration, ex. 24h),
+// bucket (duration of the time buckets the events are counted in, all events
+// are counted in one bucket if unset)
+// strings: group_by (comma-separated groupings among type, container and
+// label:<key>, defaults to type,container)
+// example r.URL: http://localhost:8080/api/v2.0/eventcounts?oom_kill_events=true&subcontainers=true&window=24h
+func getEventCountsRequest(r *http.Request) (*events.Request, events.Aggregation, error) {
+	query, _, err := getEventRequest(r)
+	if err != nil {
+		return nil, events.Aggregation{}, err
+	}
+	urlMap := r.URL.Query()
+	groupBy := "type,container"
+	if val, ok := urlMap["group_by"]; ok {
+		groupBy = val[0]
+	}
+	aggregation, err := events.ParseGroupBy(groupBy)
+	if err != nil {
+		return nil, events.Aggregation{}, err
+	}
+	if val, ok := urlMap["bucket"]; ok {
+		bucket, err := time.ParseDuration(val[0])
+		if err != nil || bucket <= 0 {
+			return nil, events.Aggregation{}, fmt.Errorf("invalid bucket %q", val[0])
+		}
+		aggregation.BucketSize = bucket
+	}
+	if val, ok := urlMap["window"]; ok {
+		if _, ok := urlMap["start_time"]; ok {
+			return nil, events.Aggregation{}, fmt.Errorf("window and start_time cannot both be set")
+		}
+		window, err := time.ParseDuration(val[0])
+		if err != nil || window <= 0 {
+			return nil, events.Aggregation{}, fmt.Errorf("invalid window %q", val[0])
+		}
+		query.StartTime = time.Now().Add(-window)
+	}
+	return query, aggregation, nil
+}
+
 func getContainerName(request []string) string {
 	return path.Join("/", strings.Join(request, "/"))
 }
@@ -0,0 +1,446 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/google/cadvisor/manager"
+)
+
+// Interval between heartbeats when the request does not set one.
+const defaultHeartbeatInterval = 30 * time.Second
+
+// A transport of a stream of JSON messages to a client.
+type streamWriter interface {
+	// Sends a message. The id is where a reconnecting client resumes the
+	// stream from and the name is the kind of the message.
+	write(id string, name string, v interface{}) error
+	// Tells the client and the proxies in between that the stream is alive.
+	heartbeat() error
+	// Receives when the client went away.
+	done() <-chan bool
+	close()
+}
+
+// Returns the transport the request asks for: a WebSocket when it upgrades
+// the connection, Server-Sent Events when it accepts text/event-stream, and
+// newline-delimited JSON otherwise.
+func newStreamWriter(w http.ResponseWriter, r *http.Request) (streamWriter, error) {
+	if isWebsocketRequest(r) {
+		conn, err := upgradeWebsocket(w, r)
+		if err != nil {
+			return nil, err
+		}
+		return &websocketStreamWriter{conn: conn}, nil
+	}
+	cn, ok := w.(http.CloseNotifier)
+	if !ok {
+		return nil, errors.New("could not access http.CloseNotifier")
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("could not access http.Flusher")
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Keeps nginx from buffering the stream.
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		return &sseStreamWriter{w: w, flusher: flusher, closed: cn.CloseNotify()}, nil
+	}
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &jsonStreamWriter{encoder: json.NewEncoder(w), flusher: flusher, closed: cn.CloseNotify()}, nil
+}
+
+// Writes each message as a line of JSON.
+type jsonStreamWriter struct {
+	encoder *json.Encoder
+	flusher http.Flusher
+	closed  <-chan bool
+}
+
+func (self *jsonStreamWriter) write(id string, name string, v interface{}) error {
+	if err := self.encoder.Encode(v); err != nil {
+		return err
+	}
+	self.flusher.Flush()
+	return nil
+}
+
+// Newline-delimited JSON has no room for heartbeats.
+func (self *jsonStreamWriter) heartbeat() error {
+	return nil
+}
+
+func (self *jsonStreamWriter) done() <-chan bool {
+	return self.closed
+}
+
+func (self *jsonStreamWriter) close() {}
+
+// Writes each message as a Server-Sent Event.
+type sseStreamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	closed  <-chan bool
+}
+
+func (self *sseStreamWriter) write(id string, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(self.w, "id: %s\nevent: %s\ndata: %s\n\n", id, name, data); err != nil {
+		return err
+	}
+	self.flusher.Flush()
+	return nil
+}
+
+// Sends a comment, which clients ignore.
+func (self *sseStreamWriter) heartbeat() error {
+	if _, err := fmt.Fprint(self.w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	self.flusher.Flush()
+	return nil
+}
+
+func (self *sseStreamWriter) done() <-chan bool {
+	return self.closed
+}
+
+func (self *sseStreamWriter) close() {}
+
+// Writes each message as a WebSocket text message.
+type websocketStreamWriter struct {
+	conn *websocketConn
+}
+
+func (self *websocketStreamWriter) write(id string, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return self.conn.writeText(data)
+}
+
+func (self *websocketStreamWriter) heartbeat() error {
+	return self.conn.ping()
+}
+
+func (self *websocketStreamWriter) done() <-chan bool {
+	return self.conn.closed
+}
+
+func (self *websocketStreamWriter) close() {
+	self.conn.close()
+}
+
+// Options of a stream.
+type streamOptions struct {
+	// Interval between heartbeats, none when 0.
+	heartbeatInterval time.Duration
+	// Only the messages after this time are sent, when set.
+	resumeFrom time.Time
+	// Only the events after this sequence number are sent, when set.
+	resumeAfter uint64
+}
+
+// The user can set the following arguments:
+// heartbeat: interval between heartbeats, e.g.: 10s, 0 disables them
+// resume_from: RFC 3339 timestamp after which to resume the stream
+// resume_after: sequence number of the event after which to resume the
+// stream of events
+// The Last-Event-ID header a reconnecting Server-Sent Events client sends,
+// the sequence number of an event or the timestamp of stats, takes
+// precedence over both.
+func getStreamOptions(r *http.Request) (streamOptions, error) {
+	opt := streamOptions{
+		heartbeatInterval: defaultHeartbeatInterval,
+	}
+	if heartbeat := r.URL.Query().Get("heartbeat"); heartbeat != "" {
+		interval, err := time.ParseDuration(heartbeat)
+		if err != nil || interval < 0 {
+			return opt, fmt.Errorf("failed to parse 'heartbeat' option: %v", heartbeat)
+		}
+		opt.heartbeatInterval = interval
+	}
+	resumeFrom := r.URL.Query().Get("resume_from")
+	resumeAfter := r.URL.Query().Get("resume_after")
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		resumeFrom, resumeAfter = "", ""
+		if _, err := strconv.ParseUint(lastEventId, 10, 64); err == nil {
+			resumeAfter = lastEventId
+		} else {
+			resumeFrom = lastEventId
+		}
+	}
+	if resumeAfter != "" {
+		sequence, err := strconv.ParseUint(resumeAfter, 10, 64)
+		if err != nil {
+			return opt, fmt.Errorf("failed to parse resume sequence number: %v", resumeAfter)
+		}
+		opt.resumeAfter = sequence
+	}
+	if resumeFrom != "" {
+		timestamp, err := time.Parse(time.RFC3339Nano, resumeFrom)
+		if err != nil {
+			return opt, fmt.Errorf("failed to parse resume timestamp: %v", resumeFrom)
+		}
+		opt.resumeFrom = timestamp
+	}
+	return opt, nil
+}
+
+// Returns the channel the heartbeats are received on, which is nil when there
+// are none, and a function stopping them.
+func newHeartbeat(interval time.Duration) (<-chan time.Time, func()) {
+	if interval == 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+// Formats a timestamp as the id of a message.
+func streamId(timestamp time.Time) string {
+	return timestamp.UTC().Format(time.RFC3339Nano)
+}
+
+// Formats the sequence number of an event as the id of a message. Unlike
+// timestamps, sequence numbers tell apart the events that occurred at the
+// same time.
+func eventStreamId(e *info.Event) string {
+	return strconv.FormatUint(e.Sequence, 10)
+}
+
+// Events sorted by sequence number.
+type bySequence []*info.Event
+
+func (e bySequence) Len() int           { return len(e) }
+func (e bySequence) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e bySequence) Less(i, j int) bool { return e[i].Sequence < e[j].Sequence }
+
+// Streams the events that satisfy the query until the client goes away. When
+// resuming, the stored events after the resume sequence number or time are
+// sent first.
+func streamEvents(query *events.Request, m manager.Manager, w http.ResponseWriter, r *http.Request) error {
+	opt, err := getStreamOptions(r)
+	if err != nil {
+		return err
+	}
+	// Watch before getting the stored events so that none is missed.
+	eventChannel, err := m.WatchForEvents(query)
+	if err != nil {
+		return err
+	}
+	defer m.CloseEventChannel(eventChannel.GetWatchId())
+	var pastEvents []*info.Event
+	if opt.resumeAfter != 0 || !opt.resumeFrom.IsZero() {
+		pastQuery := *query
+		pastQuery.StartTime = opt.resumeFrom
+		pastQuery.MaxEventsReturned = -1
+		stored, err := m.GetPastEvents(&pastQuery)
+		if err != nil {
+			return err
+		}
+		for _, e := range stored {
+			if e.Sequence > opt.resumeAfter && (opt.resumeFrom.IsZero() || e.Timestamp.After(opt.resumeFrom)) {
+				pastEvents = append(pastEvents, e)
+			}
+		}
+		if opt.resumeAfter != 0 {
+			sort.Sort(bySequence(pastEvents))
+		}
+	}
+	stream, err := newStreamWriter(w, r)
+	if err != nil {
+		return err
+	}
+	defer stream.close()
+
+	// An event sent from the store is recognized by its sequence number when
+	// it is received from the watch too.
+	sent := make(map[uint64]bool)
+	for _, e := range pastEvents {
+		if err := stream.write(eventStreamId(e), string(e.EventType), e); err != nil {
+			glog.V(2).Infof("Failed to send event to watch %d: %v", eventChannel.GetWatchId(), err)
+			return nil
+		}
+		sent[e.Sequence] = true
+	}
+
+	heartbeat, stopHeartbeat := newHeartbeat(opt.heartbeatInterval)
+	defer stopHeartbeat()
+	for {
+		select {
+		case <-stream.done():
+			if dropped := eventChannel.GetDroppedEvents(); dropped > 0 {
+				glog.V(2).Infof("Event watch %d dropped %d events", eventChannel.GetWatchId(), dropped)
+			}
+			return nil
+		case <-heartbeat:
+			if err := stream.heartbeat(); err != nil {
+				glog.V(2).Infof("Failed to send heartbeat to event watch %d: %v", eventChannel.GetWatchId(), err)
+				return nil
+			}
+		case e, ok := <-eventChannel.GetChannel():
+			if !ok {
+				// The watch was stopped because the client did not keep up.
+				glog.V(2).Infof("Event watch %d was stopped after dropping %d events", eventChannel.GetWatchId(), eventChannel.GetDroppedEvents())
+				return nil
+			}
+			if sent[e.Sequence] {
+				delete(sent, e.Sequence)
+				continue
+			}
+			// Replayed events added again after a restart keep their sequence
+			// number, which the client may have seen.
+			if e.Sequence <= opt.resumeAfter {
+				continue
+			}
+			if err := stream.write(eventStreamId(e), string(e.EventType), e); err != nil {
+				glog.V(2).Infof("Failed to send event to watch %d: %v", eventChannel.GetWatchId(), err)
+				return nil
+			}
+		}
+	}
+}
+
+// Samples of a single stats each, sorted by the time they were collected.
+type samplesByTimestamp []*info.ContainerInfo
+
+func (s samplesByTimestamp) Len() int      { return len(s) }
+func (s samplesByTimestamp) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s samplesByTimestamp) Less(i, j int) bool {
+	return s[i].Stats[0].Timestamp.Before(s[j].Stats[0].Timestamp)
+}
+
+// Streams the stats samples of the requested containers as they are
+// collected, until the client goes away. When resuming, the stored samples
+// after the resume time are sent first. Each message maps the name of a
+// container to its sample.
+func streamStats(containerName string, options v2.RequestOptions, m manager.Manager, w http.ResponseWriter, r *http.Request) error {
+	opt, err := getStreamOptions(r)
+	if err != nil {
+		return err
+	}
+	subcontainers := options.Recursive
+	if options.IdType == v2.TypeDocker {
+		if options.Recursive {
+			return fmt.Errorf("streaming the stats of all docker containers is not supported")
+		}
+		// Resolve the absolute name of the docker container.
+		conts, err := m.GetRequestedContainersInfo(containerName, v2.RequestOptions{IdType: v2.TypeDocker, Count: 0})
+		if err != nil {
+			return err
+		}
+		for name := range conts {
+			containerName = name
+		}
+	}
+	// Watch before getting the stored samples so that none is missed.
+	statsStream, err := m.WatchStats(containerName, subcontainers)
+	if err != nil {
+		return err
+	}
+	defer m.StopWatchingStats(statsStream.GetId())
+	// Timestamp of the last sample sent of each container.
+	lastSent := make(map[string]time.Time)
+	var pastSamples []*info.ContainerInfo
+	if !opt.resumeFrom.IsZero() {
+		conts, err := m.GetRequestedContainersInfo(containerName, v2.RequestOptions{IdType: v2.TypeName, Count: -1, Recursive: subcontainers})
+		if err != nil {
+			return err
+		}
+		for _, cont := range conts {
+			for _, stats := range cont.Stats {
+				if stats.Timestamp.After(opt.resumeFrom) {
+					pastSamples = append(pastSamples, &info.ContainerInfo{
+						ContainerReference: cont.ContainerReference,
+						Spec:               cont.Spec,
+						Stats:              []*info.ContainerStats{stats},
+					})
+				}
+			}
+		}
+		sort.Sort(samplesByTimestamp(pastSamples))
+	}
+	stream, err := newStreamWriter(w, r)
+	if err != nil {
+		return err
+	}
+	defer stream.close()
+
+	send := func(sample *info.ContainerInfo) error {
+		timestamp := sample.Stats[0].Timestamp
+		if last, ok := lastSent[sample.Name]; ok && !timestamp.After(last) {
+			return nil
+		}
+		lastSent[sample.Name] = timestamp
+		message := map[string][]v2.ContainerStats{
+			sample.Name: convertStats(sample),
+		}
+		return stream.write(streamId(timestamp), statsApi, message)
+	}
+	for _, sample := range pastSamples {
+		if err := send(sample); err != nil {
+			glog.V(2).Infof("Failed to send stats to stream %d: %v", statsStream.GetId(), err)
+			return nil
+		}
+	}
+
+	heartbeat, stopHeartbeat := newHeartbeat(opt.heartbeatInterval)
+	defer stopHeartbeat()
+	for {
+		select {
+		case <-stream.done():
+			if dropped := statsStream.GetDroppedSamples(); dropped > 0 {
+				glog.V(2).Infof("Stats stream %d dropped %d samples", statsStream.GetId(), dropped)
+			}
+			return nil
+		case <-heartbeat:
+			if err := stream.heartbeat(); err != nil {
+				glog.V(2).Infof("Failed to send heartbeat to stats stream %d: %v", statsStream.GetId(), err)
+				return nil
+			}
+		case sample, ok := <-statsStream.GetChannel():
+			if !ok {
+				return nil
+			}
+			if err := send(sample); err != nil {
+				glog.V(2).Infof("Failed to send stats to stream %d: %v", statsStream.GetId(), err)
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A manager that only has events.
+type eventsManager struct {
+	manager.Manager
+	eventHandler events.EventManager
+}
+
+func (self *eventsManager) WatchForEvents(request *events.Request) (*events.EventChannel, error) {
+	return self.eventHandler.WatchEvents(request)
+}
+
+func (self *eventsManager) GetPastEvents(request *events.Request) ([]*info.Event, error) {
+	return self.eventHandler.GetEvents(request)
+}
+
+func (self *eventsManager) CloseEventChannel(watchId int) {
+	self.eventHandler.StopWatch(watchId)
+}
+
+// Starts a server of the API of a manager with the events.
+func newEventsServer(t *testing.T, pastEvents ...*info.Event) (*httptest.Server, events.EventManager) {
+	eventHandler := events.NewEventManager(events.DefaultStoragePolicy(), events.DefaultWatchPolicy())
+	for _, e := range pastEvents {
+		require.NoError(t, eventHandler.AddEvent(e))
+	}
+	mux := http.NewServeMux()
+	require.NoError(t, RegisterHandlers(mux, &eventsManager{eventHandler: eventHandler}))
+	return httptest.NewServer(mux), eventHandler
+}
+
+func newOomEvent(timestamp time.Time) *info.Event {
+	return &info.Event{
+		ContainerName: "/",
+		Timestamp:     timestamp,
+		EventType:     info.EventOom,
+	}
+}
+
+// Reads a Server-Sent Event, returning its fields.
+func readServerSentEvent(t *testing.T, reader *bufio.Reader) map[string]string {
+	fields := make(map[string]string)
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return fields
+		}
+		if strings.HasPrefix(line, ":") {
+			fields["comment"] = strings.TrimSpace(line[1:])
+			continue
+		}
+		parts := strings.SplitN(line, ": ", 2)
+		require.Equal(t, 2, len(parts), "malformed line %q", line)
+		fields[parts[0]] = parts[1]
+	}
+}
+
+func TestGetStreamOptions(t *testing.T) {
+	opt, err := getStreamOptions(makeHTTPRequest("http://localhost:8080/api/v2.0/stats?stream=true", t))
+	require.NoError(t, err)
+	assert.Equal(t, defaultHeartbeatInterval, opt.heartbeatInterval)
+	assert.True(t, opt.resumeFrom.IsZero())
+
+	opt, err = getStreamOptions(makeHTTPRequest("http://localhost:8080/api/v2.0/stats?heartbeat=5s&resume_from=2015-06-01T10:00:00.5Z", t))
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, opt.heartbeatInterval)
+	assert.True(t, time.Date(2015, 6, 1, 10, 0, 0, 500000000, time.UTC).Equal(opt.resumeFrom))
+
+	// Last-Event-ID takes precedence.
+	r := makeHTTPRequest("http://localhost:8080/api/v2.0/stats?resume_from=2015-06-01T10:00:00Z", t)
+	r.Header.Set("Last-Event-ID", "2015-06-01T11:00:00Z")
+	opt, err = getStreamOptions(r)
+	require.NoError(t, err)
+	assert.True(t, time.Date(2015, 6, 1, 11, 0, 0, 0, time.UTC).Equal(opt.resumeFrom))
+
+	// Events are resumed after their sequence number.
+	opt, err = getStreamOptions(makeHTTPRequest("http://localhost:8080/api/v1.3/events?resume_after=42", t))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), opt.resumeAfter)
+	r = makeHTTPRequest("http://localhost:8080/api/v1.3/events?resume_from=2015-06-01T10:00:00Z", t)
+	r.Header.Set("Last-Event-ID", "43")
+	opt, err = getStreamOptions(r)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(43), opt.resumeAfter)
+	assert.True(t, opt.resumeFrom.IsZero())
+
+	_, err = getStreamOptions(makeHTTPRequest("http://localhost:8080/api/v2.0/stats?heartbeat=often", t))
+	assert.Error(t, err)
+	_, err = getStreamOptions(makeHTTPRequest("http://localhost:8080/api/v2.0/stats?resume_from=yesterday", t))
+	assert.Error(t, err)
+	_, err = getStreamOptions(makeHTTPRequest("http://localhost:8080/api/v1.3/events?resume_after=-1", t))
+	assert.Error(t, err)
+}
+
+func TestStreamEventsServerSentEvents(t *testing.T) {
+	now := time.Now().UTC()
+	before := newOomEvent(now.Add(-2 * time.Minute))
+	after := newOomEvent(now.Add(-time.Minute))
+	server, eventHandler := newEventsServer(t, before, after)
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL+"/api/v1.3/events?stream=true&oom_events=true", nil)
+	require.NoError(t, err)
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Last-Event-ID", eventStreamId(before))
+	resp, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	// The stored events after the resume sequence number are sent first.
+	fields := readServerSentEvent(t, reader)
+	assert.Equal(t, eventStreamId(after), fields["id"])
+	assert.Equal(t, string(info.EventOom), fields["event"])
+	var received info.Event
+	require.NoError(t, json.Unmarshal([]byte(fields["data"]), &received))
+	assert.True(t, after.Timestamp.Equal(received.Timestamp))
+
+	live := newOomEvent(now)
+	require.NoError(t, eventHandler.AddEvent(live))
+	fields = readServerSentEvent(t, reader)
+	assert.Equal(t, eventStreamId(live), fields["id"])
+}
+
+// Events that occurred at the same time as the last one received are still
+// sent on resume.
+func TestStreamEventsResumesEventsAtTheSameTime(t *testing.T) {
+	now := time.Now().UTC()
+	first := newOomEvent(now)
+	second := newOomEvent(now)
+	second.ContainerName = "/docker"
+	server, _ := newEventsServer(t, first, second)
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL+"/api/v1.3/events?stream=true&oom_events=true&subcontainers=true", nil)
+	require.NoError(t, err)
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Last-Event-ID", eventStreamId(first))
+	resp, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	fields := readServerSentEvent(t, bufio.NewReader(resp.Body))
+	assert.Equal(t, eventStreamId(second), fields["id"])
+}
+
+func TestStreamEventsHeartbeat(t *testing.T) {
+	server, _ := newEventsServer(t)
+	defer server.Close()
+
+	request, err := http.NewRequest("GET", server.URL+"/api/v1.3/events?stream=true&oom_events=true&heartbeat=10ms", nil)
+	require.NoError(t, err)
+	request.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	fields := readServerSentEvent(t, bufio.NewReader(resp.Body))
+	assert.Equal(t, "heartbeat", fields["comment"])
+}
+
+func TestStreamEventsNewlineDelimitedJson(t *testing.T) {
+	now := time.Now()
+	server, _ := newEventsServer(t, newOomEvent(now.Add(-time.Minute)), newOomEvent(now))
+	defer server.Close()
+
+	resumeFrom := streamId(now.Add(-time.Hour))
+	resp, err := http.Get(server.URL + "/api/v1.3/events?stream=true&oom_events=true&resume_from=" + resumeFrom)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for i := 0; i < 2; i++ {
+		var received info.Event
+		require.NoError(t, decoder.Decode(&received))
+		assert.Equal(t, info.EventOom, received.EventType)
+	}
+}
+
+// Reads a frame sent by the server, which are not masked.
+func readWebsocketFrame(t *testing.T, reader *bufio.Reader) (byte, []byte) {
+	var header [2]byte
+	_, err := io.ReadFull(reader, header[:])
+	require.NoError(t, err)
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		var extended [2]byte
+		_, err := io.ReadFull(reader, extended[:])
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	require.NoError(t, err)
+	return header[0] & 0x0f, payload
+}
+
+// Sends a masked frame, as clients do.
+func writeWebsocketFrame(t *testing.T, conn net.Conn, opcode byte, payload []byte) {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := conn.Write(frame)
+	require.NoError(t, err)
+}
+
+func TestStreamEventsWebsocket(t *testing.T) {
+	server, eventHandler := newEventsServer(t)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /api/v1.3/events?stream=true&oom_events=true HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	// Pings are answered.
+	writeWebsocketFrame(t, conn, websocketPing, []byte("hello"))
+	opcode, payload := readWebsocketFrame(t, reader)
+	assert.Equal(t, byte(websocketPong), opcode)
+	assert.Equal(t, "hello", string(payload))
+
+	live := newOomEvent(time.Now())
+	require.NoError(t, eventHandler.AddEvent(live))
+	opcode, payload = readWebsocketFrame(t, reader)
+	assert.Equal(t, byte(websocketText), opcode)
+	var received info.Event
+	require.NoError(t, json.Unmarshal(payload, &received))
+	assert.True(t, live.Timestamp.Equal(received.Timestamp))
+
+	// Closes are answered.
+	writeWebsocketFrame(t, conn, websocketClose, []byte{0x03, 0xe8})
+	opcode, _ = readWebsocketFrame(t, reader)
+	assert.Equal(t, byte(websocketClose), opcode)
+}
+
+// Sends the handshake of a WebSocket request with the extra headers.
+func dialWebsocket(t *testing.T, server *httptest.Server, headers string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	fmt.Fprintf(conn, "GET /api/v1.3/events?stream=true&oom_events=true HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n%s\r\n", headers)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	return conn, reader, resp
+}
+
+func TestWebsocketHandshakeIsChecked(t *testing.T) {
+	server, _ := newEventsServer(t)
+	defer server.Close()
+
+	conn, _, resp := dialWebsocket(t, server, "Sec-WebSocket-Version: 8\r\n")
+	conn.Close()
+	assert.Equal(t, 426, resp.StatusCode)
+	assert.Equal(t, "13", resp.Header.Get("Sec-WebSocket-Version"))
+
+	// Other sites can not open a WebSocket.
+	conn, _, resp = dialWebsocket(t, server, "Sec-WebSocket-Version: 13\r\nOrigin: http://evil.example.com\r\n")
+	conn.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	conn, _, resp = dialWebsocket(t, server, "Sec-WebSocket-Version: 13\r\nOrigin: http://localhost\r\n")
+	conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+}
+
+func TestWebsocketUnmaskedFrameClosesConnection(t *testing.T) {
+	server, _ := newEventsServer(t)
+	defer server.Close()
+
+	conn, reader, resp := dialWebsocket(t, server, "Sec-WebSocket-Version: 13\r\n")
+	defer conn.Close()
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	_, err := conn.Write([]byte{0x80 | websocketPing, 0})
+	require.NoError(t, err)
+	opcode, payload := readWebsocketFrame(t, reader)
+	assert.Equal(t, byte(websocketClose), opcode)
+	assert.Equal(t, []byte{0x03, 0xea}, payload)
+}
+
+func TestWebsocketInvalidFramesCloseConnection(t *testing.T) {
+	server, _ := newEventsServer(t)
+	defer server.Close()
+
+	for _, frame := range [][]byte{
+		// A 64-bit length with its most significant bit set.
+		{0x80 | websocketText, 0x80 | 127, 0x80, 0, 0, 0, 0, 0, 0, 1, 1, 2, 3, 4},
+		// The first fragment of a message.
+		{websocketText, 0x80 | 1, 1, 2, 3, 4, 'a'},
+		// A continuation frame.
+		{0x80 | websocketContinuation, 0x80 | 1, 1, 2, 3, 4, 'a'},
+		// A reserved bit.
+		{0x80 | 0x40 | websocketText, 0x80 | 1, 1, 2, 3, 4, 'a'},
+		// A ping longer than 125 bytes.
+		{0x80 | websocketPing, 0x80 | 126, 0, 126, 1, 2, 3, 4},
+	} {
+		conn, reader, resp := dialWebsocket(t, server, "Sec-WebSocket-Version: 13\r\n")
+		require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+		_, err := conn.Write(frame)
+		require.NoError(t, err)
+		opcode, payload := readWebsocketFrame(t, reader)
+		assert.Equal(t, byte(websocketClose), opcode, "frame %v", frame)
+		assert.Equal(t, []byte{0x03, 0xea}, payload, "frame %v", frame)
+		conn.Close()
+	}
+}
+
+func TestIsWebsocketRequest(t *testing.T) {
+	r := makeHTTPRequest("http://localhost:8080/api/v1.3/events", t)
+	assert.False(t, isWebsocketRequest(r))
+	r.Header.Set("Upgrade", "WebSocket")
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	assert.True(t, isWebsocketRequest(r))
+}
@@ -17,7 +17,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"path"
 	"strconv"
 
 	"github.com/golang/glog"
@@ -40,6 +39,8 @@ const (
 	versionApi       = "version"
 	psApi            = "ps"
 	customMetricsApi = "appmetrics"
+	alertsApi        = "alerts"
+	eventCountsApi   = "eventcounts"
 )
 
 // Interface for a cAdvisor API version
@@ -275,7 +276,7 @@ func handleEventRequest(request []string, m manager.Manager, w http.ResponseWrit
 	if err != nil {
 		return err
 	}
-	query.ContainerName = path.Join("/", getContainerName(request))
+	query.ContainerName = getContainerName(request)
 	glog.V(4).Infof("Api - Events(%v)", query)
 	if !stream {
 		pastEvents, err := m.GetPastEvents(query)
@@ -284,12 +285,21 @@ func handleEventRequest(request []string, m manager.Manager, w http.ResponseWrit
 		}
 		return writeResult(pastEvents, w)
 	}
-	eventChannel, err := m.WatchForEvents(query)
+	return streamEvents(query, m, w, r)
+}
+
+func handleEventCountsRequest(request []string, m manager.Manager, w http.ResponseWriter, r *http.Request) error {
+	query, aggregation, err := getEventCountsRequest(r)
 	if err != nil {
 		return err
 	}
-	return streamResults(eventChannel, w, r, m)
-
+	query.ContainerName = getContainerName(request)
+	glog.V(4).Infof("Api - Event counts(%v, %+v)", query, aggregation)
+	counts, err := m.GetEventCounts(query, aggregation)
+	if err != nil {
+		return err
+	}
+	return writeResult(counts, w)
 }
 
 // API v2.0
@@ -306,7 +316,7 @@ func (self *version2_0) Version() string {
 }
 
 func (self *version2_0) SupportedRequestTypes() []string {
-	return []string{versionApi, attributesApi, eventsApi, machineApi, summaryApi, statsApi, specApi, storageApi, psApi, customMetricsApi}
+	return []string{versionApi, attributesApi, eventsApi, machineApi, summaryApi, statsApi, specApi, storageApi, psApi, customMetricsApi, alertsApi, eventCountsApi}
 }
 
 func (self *version2_0) HandleRequest(requestType string, request []string, m manager.Manager, w http.ResponseWriter, r *http.Request) error {
@@ -356,6 +366,9 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 	case statsApi:
 		name := getContainerName(request)
 		glog.V(4).Infof("Api - Stats: Looking for stats for container %q, options %+v", name, opt)
+		if r.URL.Query().Get("stream") == "true" {
+			return streamStats(name, opt, m, w, r)
+		}
 		conts, err := m.GetRequestedContainersInfo(name, opt)
 		if err != nil {
 			return err
@@ -433,6 +446,8 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 		return writeResult(fi, w)
 	case eventsApi:
 		return handleEventRequest(request, m, w, r)
+	case eventCountsApi:
+		return handleEventCountsRequest(request, m, w, r)
 	case psApi:
 		// reuse container type from request.
 		// ignore recursive.
@@ -444,6 +459,14 @@ func (self *version2_0) HandleRequest(requestType string, request []string, m ma
 			return fmt.Errorf("process listing failed: %v", err)
 		}
 		return writeResult(ps, w)
+	case alertsApi:
+		name := getContainerName(request)
+		glog.V(4).Infof("Api - Alerts for container %q, options %+v", name, opt)
+		alerts, err := m.GetAlerts(name, opt)
+		if err != nil {
+			return err
+		}
+		return writeResult(alerts, w)
 	default:
 		return fmt.Errorf("unknown request type %q", requestType)
 	}
@@ -459,6 +482,8 @@ func convertStats(cont *info.ContainerInfo) []v2.ContainerStats {
 			HasNetwork:       cont.Spec.HasNetwork,
 			HasFilesystem:    cont.Spec.HasFilesystem,
 			HasDiskIo:        cont.Spec.HasDiskIo,
+			HasProcesses:     cont.Spec.HasProcesses,
+			HasPressure:      cont.Spec.HasPressure,
 			HasCustomMetrics: cont.Spec.HasCustomMetrics,
 		}
 		if stat.HasCpu {
@@ -466,9 +491,15 @@ func convertStats(cont *info.ContainerInfo) []v2.ContainerStats {
 		}
 		if stat.HasMemory {
 			stat.Memory = val.Memory
+			stat.Hugetlb = val.Hugetlb
 		}
 		if stat.HasNetwork {
 			stat.Network.Interfaces = val.Network.Interfaces
+			stat.Network.Tcp = val.Network.Tcp
+			stat.Network.Tcp6 = val.Network.Tcp6
+			stat.Network.Udp = val.Network.Udp
+			stat.Network.Udp6 = val.Network.Udp6
+			stat.Network.Protocol = val.Network.Protocol
 		}
 		if stat.HasFilesystem {
 			stat.Filesystem = val.Filesystem
@@ -476,10 +507,20 @@ func convertStats(cont *info.ContainerInfo) []v2.ContainerStats {
 		if stat.HasDiskIo {
 			stat.DiskIo = val.DiskIo
 		}
+		if stat.HasProcesses {
+			stat.Processes = val.Processes
+		}
 		if stat.HasCustomMetrics {
 			stat.CustomMetrics = val.CustomMetrics
 		}
-		// TODO(rjnagal): Handle load stats.
+		if stat.HasPressure {
+			stat.Pressure = val.Pressure
+		}
+		// Load stats are only available when the load reader is enabled.
+		if val.Cpu.LoadAverages != nil {
+			stat.HasLoad = true
+			stat.Load = val.TaskStats
+		}
 		stats = append(stats, stat)
 	}
 	return stats
@@ -19,6 +19,7 @@ import (
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
@@ -78,3 +79,70 @@ func TestGetEventRequestDoubleArgument(t *testing.T) {
 	assert.True(t, stream)
 	assert.Nil(t, err)
 }
+
+func TestGetEventRequestLifecycleEvents(t *testing.T) {
+	r := makeHTTPRequest("http://localhost:8080/api/v1.3/events?exit_events=true&restart_events=true&health_events=false", t)
+	expectedQuery := events.NewRequest()
+	expectedQuery.EventType = map[info.EventType]bool{
+		info.EventContainerExit:         true,
+		info.EventContainerRestart:      true,
+		info.EventContainerHealthStatus: false,
+	}
+
+	receivedQuery, stream, err := getEventRequest(r)
+
+	if !reflect.DeepEqual(expectedQuery, receivedQuery) {
+		t.Errorf("expected %#v but received %#v", expectedQuery, receivedQuery)
+	}
+	assert.False(t, stream)
+	assert.Nil(t, err)
+}
+
+func TestGetEventRequestContainerFilters(t *testing.T) {
+	r := makeHTTPRequest("http://localhost:8080/api/v1.3/events?oom_kill_events=true&labels=app%3Dweb,tier!%3Ddb&alias=web-*&namespace=docker", t)
+	expectedQuery := events.NewRequest()
+	expectedQuery.EventType = map[info.EventType]bool{
+		info.EventOomKill: true,
+	}
+	expectedQuery.LabelSelector = events.LabelSelector{
+		{Key: "app", Operator: events.LabelEquals, Value: "web"},
+		{Key: "tier", Operator: events.LabelNotEquals, Value: "db"},
+	}
+	expectedQuery.AliasPattern = "web-*"
+	expectedQuery.Namespace = "docker"
+
+	receivedQuery, _, err := getEventRequest(r)
+
+	if !reflect.DeepEqual(expectedQuery, receivedQuery) {
+		t.Errorf("expected %#v but received %#v", expectedQuery, receivedQuery)
+	}
+	assert.Nil(t, err)
+}
+
+func TestGetEventRequestInvalidContainerFilters(t *testing.T) {
+	_, _, err := getEventRequest(makeHTTPRequest("http://localhost:8080/api/v1.3/events?labels=%3Dweb", t))
+	assert.NotNil(t, err)
+	_, _, err = getEventRequest(makeHTTPRequest("http://localhost:8080/api/v1.3/events?alias=web-[", t))
+	assert.NotNil(t, err)
+}
+
+func TestGetEventCountsRequest(t *testing.T) {
+	r := makeHTTPRequest("http://localhost:8080/api/v2.0/eventcounts?oom_kill_events=true&subcontainers=true&window=24h&bucket=1h&group_by=container,label:app", t)
+	before := time.Now()
+	query, aggregation, err := getEventCountsRequest(r)
+	assert.Nil(t, err)
+	assert.True(t, query.EventType[info.EventOomKill])
+	assert.True(t, query.IncludeSubcontainers)
+	assert.False(t, query.StartTime.Before(before.Add(-24*time.Hour)))
+	assert.False(t, query.StartTime.After(time.Now().Add(-24*time.Hour)))
+	assert.Equal(t, events.Aggregation{ByContainer: true, ByLabel: "app", BucketSize: time.Hour}, aggregation)
+
+	_, aggregation, err = getEventCountsRequest(makeHTTPRequest("http://localhost:8080/api/v2.0/eventcounts?all_events=true", t))
+	assert.Nil(t, err)
+	assert.Equal(t, events.Aggregation{ByType: true, ByContainer: true}, aggregation)
+
+	for _, invalid := range []string{"window=soon", "bucket=-1h", "group_by=pod", "labels=%3Dweb", "window=1h&start_time=2015-01-01T00:00:00Z"} {
+		_, _, err := getEventCountsRequest(makeHTTPRequest("http://localhost:8080/api/v2.0/eventcounts?"+invalid, t))
+		assert.NotNil(t, err, invalid)
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server side of a WebSocket (RFC 6455) that only sends text messages.
+// Messages sent by the client are discarded, but pings and closes are
+// answered, and fragmented messages close the connection. Only the pages
+// served by cAdvisor and clients that are not browsers may connect.
+
+// Appended to the key of the client to compute the accept key.
+const websocketGuid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes.
+const (
+	websocketContinuation = 0x0
+	websocketText         = 0x1
+	websocketClose        = 0x8
+	websocketPing         = 0x9
+	websocketPong         = 0xa
+)
+
+// Close status codes.
+const (
+	websocketNormalClosure = 1000
+	websocketProtocolError = 1002
+)
+
+// A frame of the client that violates the protocol, or that is fragmented,
+// which is not supported. The connection is then closed with a protocol error.
+type websocketFrameError string
+
+func (self websocketFrameError) Error() string {
+	return string(self)
+}
+
+const (
+	errUnmaskedFrame   = websocketFrameError("websocket frame is not masked")
+	errFrameLength     = websocketFrameError("websocket frame length has its most significant bit set")
+	errFragmentedFrame = websocketFrameError("fragmented websocket frames are not supported")
+	errReservedBits    = websocketFrameError("websocket frame has reserved bits set")
+	errControlFrame    = websocketFrameError("websocket control frame is longer than 125 bytes")
+)
+
+// Maximum size of the frames read from the client.
+const websocketMaxFrameSize = 1 << 16
+
+// Time allowed to write a frame.
+const websocketWriteTimeout = 10 * time.Second
+
+type websocketConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	writer    *bufio.Writer
+	writeLock sync.Mutex
+	closeOnce sync.Once
+	// Closed when the connection is closed by either side.
+	closed chan bool
+}
+
+// Returns whether the request asks to upgrade the connection to a WebSocket.
+func isWebsocketRequest(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, token := range strings.Split(r.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the value of the Sec-WebSocket-Accept header for a key.
+func websocketAcceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+websocketGuid)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Returns whether the origin of the request may open a WebSocket. Browsers
+// send the origin of the page, which has to be cAdvisor itself so that other
+// sites can not read the stats of the containers. Other clients send none.
+func checkWebsocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// Completes the handshake of a WebSocket request and takes over its connection.
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request) (*websocketConn, error) {
+	if r.Method != "GET" {
+		return nil, &httpError{http.StatusMethodNotAllowed, fmt.Errorf("websocket request with method %q", r.Method)}
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, &httpError{426, fmt.Errorf("unsupported websocket version %q", r.Header.Get("Sec-WebSocket-Version"))}
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, &httpError{http.StatusBadRequest, errors.New("websocket request without key")}
+	}
+	if !checkWebsocketOrigin(r) {
+		return nil, &httpError{http.StatusForbidden, fmt.Errorf("websocket request from origin %q", r.Header.Get("Origin"))}
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("could not access http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take over the connection: %v", err)
+	}
+	self := &websocketConn{
+		conn:   conn,
+		reader: rw.Reader,
+		writer: rw.Writer,
+		closed: make(chan bool),
+	}
+	fmt.Fprintf(self.writer, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", websocketAcceptKey(key))
+	if err := self.writer.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go self.readFrames()
+	return self, nil
+}
+
+// Sends a frame that is not fragmented.
+func (self *websocketConn) writeFrame(opcode byte, payload []byte) error {
+	self.writeLock.Lock()
+	defer self.writeLock.Unlock()
+	header := []byte{0x80 | opcode, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	n := 2
+	switch {
+	case len(payload) < 126:
+		header[1] = byte(len(payload))
+	case len(payload) <= 0xffff:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+		n += 2
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+		n += 8
+	}
+	self.conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+	if _, err := self.writer.Write(header[:n]); err != nil {
+		return err
+	}
+	if _, err := self.writer.Write(payload); err != nil {
+		return err
+	}
+	return self.writer.Flush()
+}
+
+func (self *websocketConn) writeText(payload []byte) error {
+	return self.writeFrame(websocketText, payload)
+}
+
+func (self *websocketConn) ping() error {
+	return self.writeFrame(websocketPing, nil)
+}
+
+// Reads the frames of the client until the connection is closed.
+func (self *websocketConn) readFrames() {
+	for {
+		opcode, payload, err := self.readFrame()
+		if _, ok := err.(websocketFrameError); ok {
+			self.closeWithStatus(websocketProtocolError)
+			return
+		}
+		if err != nil {
+			self.close()
+			return
+		}
+		switch opcode {
+		case websocketPing:
+			if err := self.writeFrame(websocketPong, payload); err != nil {
+				self.close()
+				return
+			}
+		case websocketClose:
+			// The close frame is answered when the connection is closed.
+			self.close()
+			return
+		}
+	}
+}
+
+// Reads a frame, unmasking its payload. Fails with a websocketFrameError when
+// the frame is not masked, is fragmented or is otherwise invalid.
+func (self *websocketConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(self.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		// No extension is negotiated.
+		return 0, nil, errReservedBits
+	}
+	if !fin || opcode == websocketContinuation {
+		return 0, nil, errFragmentedFrame
+	}
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(self.reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(self.reader, extended[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+		if length>>63 != 0 {
+			return 0, nil, errFrameLength
+		}
+	}
+	if !masked {
+		return 0, nil, errUnmaskedFrame
+	}
+	if opcode&0x8 != 0 && length > 125 {
+		return 0, nil, errControlFrame
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(self.reader, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	if length > websocketMaxFrameSize {
+		// Only control frames are of interest, and they are small.
+		if _, err := io.CopyN(ioutil.Discard, self.reader, int64(length)); err != nil {
+			return 0, nil, err
+		}
+		return opcode, nil, nil
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(self.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// Closes the connection normally, telling the client first when it is still
+// open.
+func (self *websocketConn) close() {
+	self.closeWithStatus(websocketNormalClosure)
+}
+
+func (self *websocketConn) closeWithStatus(status uint16) {
+	self.closeOnce.Do(func() {
+		payload := make([]byte, 2)
+		binary.BigEndian.PutUint16(payload, status)
+		self.writeFrame(websocketClose, payload)
+		self.conn.Close()
+		close(self.closed)
+	})
+}
@@ -111,6 +111,14 @@ func (self *InMemoryCache) RecentStats(name string, start, end time.Time, maxSta
 	return cstore.RecentStats(start, end, maxStats)
 }
 
+// Returns the backend storage driver when it can store events, or nil.
+func (self *InMemoryCache) EventBackend() storage.EventStorageDriver {
+	if backend, ok := self.backend.(storage.EventStorageDriver); ok {
+		return backend
+	}
+	return nil
+}
+
 func (self *InMemoryCache) Close() error {
 	self.lock.Lock()
 	self.containerCacheMap = make(map[string]*containerCache, 32)
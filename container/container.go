@@ -27,12 +27,14 @@ const (
 	ListRecursive
 )
 
-// SubcontainerEventType indicates an addition or deletion event.
+// SubcontainerEventType indicates an addition, deletion or update event.
 type SubcontainerEventType int
 
 const (
 	SubcontainerAdd SubcontainerEventType = iota
 	SubcontainerDelete
+	// The container's aliases or labels changed (e.g.: it was renamed).
+	SubcontainerUpdate
 )
 
 // SubcontainerEvent represents a
@@ -78,4 +80,7 @@ type ContainerHandler interface {
 
 	// Returns whether the container still exists.
 	Exists() bool
+
+	// Frees the resources held for the container once it is destroyed.
+	Cleanup()
 }
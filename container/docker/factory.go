@@ -90,6 +90,17 @@ type dockerFactory struct {
 
 	// Information about mounted filesystems.
 	fsInfo fs.FsInfo
+
+	// Live handlers keyed by Docker ID, refreshed when their container is renamed.
+	// Forgotten when their container dies or is destroyed.
+	handlers     map[string]*dockerContainerHandler
+	handlersLock sync.Mutex
+
+	// Signal for the Docker event watcher thread to stop, nil when not watching.
+	stopWatcher chan error
+
+	// Channel on which lifecycle events of containers are sent, nil if not wanted.
+	lifecycleEvents chan *info.Event
 }
 
 func (self *dockerFactory) String() string {
@@ -97,6 +108,14 @@ func (self *dockerFactory) String() string {
 }
 
 func (self *dockerFactory) NewContainerHandler(name string) (handler container.ContainerHandler, err error) {
+	self.handlersLock.Lock()
+	defer self.handlersLock.Unlock()
+
+	// Share the handler of a container that is already known so that it sees renames.
+	if h, ok := self.handlers[ContainerNameToDockerId(name)]; ok {
+		return h, nil
+	}
+
 	client, err := docker.NewClient(*ArgDockerEndpoint)
 	if err != nil {
 		return
@@ -109,9 +128,27 @@ func (self *dockerFactory) NewContainerHandler(name string) (handler container.C
 		self.usesAufsDriver,
 		&self.cgroupSubsystems,
 	)
+	if err != nil {
+		return
+	}
+	if h, ok := handler.(*dockerContainerHandler); ok {
+		h.onCleanup = func() {
+			self.forgetHandler(h)
+		}
+		self.handlers[h.id] = h
+	}
 	return
 }
 
+// Forgets the specified handler, unless its container was replaced meanwhile.
+func (self *dockerFactory) forgetHandler(handler *dockerContainerHandler) {
+	self.handlersLock.Lock()
+	defer self.handlersLock.Unlock()
+	if self.handlers[handler.id] == handler {
+		delete(self.handlers, handler.id)
+	}
+}
+
 // Returns the Docker ID from the full container name.
 func ContainerNameToDockerId(name string) string {
 	id := path.Base(name)
@@ -238,6 +275,7 @@ func Register(factory info.MachineInfoFactory, fsInfo fs.FsInfo) error {
 		usesAufsDriver:     usesAufsDriver,
 		cgroupSubsystems:   cgroupSubsystems,
 		fsInfo:             fsInfo,
+		handlers:           make(map[string]*dockerContainerHandler),
 	}
 	container.RegisterContainerHandlerFactory(f)
 	return nil
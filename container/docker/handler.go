@@ -20,12 +20,14 @@ import (
 	"math"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/libcontainer/cgroups"
 	cgroup_fs "github.com/docker/libcontainer/cgroups/fs"
 	libcontainerConfigs "github.com/docker/libcontainer/configs"
 	"github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
 	"github.com/google/cadvisor/container"
 	containerLibcontainer "github.com/google/cadvisor/container/libcontainer"
 	"github.com/google/cadvisor/fs"
@@ -62,6 +64,9 @@ type dockerContainerHandler struct {
 	// Manager of this container's cgroups.
 	cgroupManager cgroups.Manager
 
+	// Whether the cgroups of this container are in the unified hierarchy.
+	unified bool
+
 	usesAufsDriver bool
 	fsInfo         fs.FsInfo
 	storageDirs    []string
@@ -69,8 +74,14 @@ type dockerContainerHandler struct {
 	// Time at which this container was created.
 	creationTime time.Time
 
+	// Lock guarding aliases and labels, which change when the container is renamed.
+	lock sync.RWMutex
+
 	// Metadata labels associated with the container.
 	labels map[string]string
+
+	// Called when the container is destroyed, nil if nothing needs to be freed.
+	onCleanup func()
 }
 
 func newDockerContainerHandler(
@@ -103,6 +114,7 @@ func newDockerContainerHandler(
 		machineInfoFactory: machineInfoFactory,
 		cgroupPaths:        cgroupPaths,
 		cgroupManager:      cgroupManager,
+		unified:            cgroupSubsystems.Unified,
 		usesAufsDriver:     usesAufsDriver,
 		fsInfo:             fsInfo,
 	}
@@ -114,16 +126,34 @@ func newDockerContainerHandler(
 		return nil, fmt.Errorf("failed to inspect container %q: %v", id, err)
 	}
 	handler.creationTime = ctnr.Created
+	handler.setNameAndLabels(ctnr)
+
+	return handler, nil
+}
+
+// Sets the aliases and labels of the container from its Docker inspect output.
+func (self *dockerContainerHandler) setNameAndLabels(ctnr *docker.Container) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
 
 	// Add the name and bare ID as aliases of the container.
-	handler.aliases = append(handler.aliases, strings.TrimPrefix(ctnr.Name, "/"))
-	handler.aliases = append(handler.aliases, id)
-	handler.labels = ctnr.Config.Labels
+	self.aliases = []string{strings.TrimPrefix(ctnr.Name, "/"), self.id}
+	self.labels = ctnr.Config.Labels
+}
 
-	return handler, nil
+// Re-reads the name and labels of the container from Docker.
+func (self *dockerContainerHandler) refresh() error {
+	ctnr, err := self.client.InspectContainer(self.id)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %q: %v", self.id, err)
+	}
+	self.setNameAndLabels(ctnr)
+	return nil
 }
 
 func (self *dockerContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return info.ContainerReference{
 		Name:      self.name,
 		Aliases:   self.aliases,
@@ -185,10 +215,26 @@ func (self *dockerContainerHandler) GetSpec() (info.ContainerSpec, error) {
 
 	spec := libcontainerConfigToContainerSpec(libcontainerConfig, mi)
 	spec.CreationTime = self.creationTime
+	spec.HasProcesses = true
+	if pidsPath, err := self.GetCgroupPath("pids"); err == nil {
+		if limit, err := containerLibcontainer.ReadCgroupLimit(pidsPath, "pids.max"); err == nil {
+			spec.Processes.Limit = limit
+		}
+	}
+	if self.unified {
+		spec.HasPressure = containerLibcontainer.HasUnifiedPressure(self.cgroupPaths[containerLibcontainer.UnifiedHierarchy])
+	}
+	if hugetlbPath, err := self.GetCgroupPath("hugetlb"); err == nil {
+		limits, err := containerLibcontainer.GetHugetlbLimits(hugetlbPath, self.unified)
+		if err != nil {
+			glog.V(4).Infof("Failed to get huge page limits of %q: %v", self.name, err)
+		}
+		spec.Memory.HugetlbLimits = limits
+	}
 	if self.usesAufsDriver {
 		spec.HasFilesystem = true
 	}
-	spec.Labels = self.labels
+	spec.Labels = self.GetContainerLabels()
 
 	return spec, err
 }
@@ -255,7 +301,7 @@ func (self *dockerContainerHandler) GetStats() (*info.ContainerStats, error) {
 			}
 		}
 	}
-	stats, err := containerLibcontainer.GetStats(self.cgroupManager, networkInterfaces)
+	stats, err := containerLibcontainer.GetCgroupStats(self.cgroupManager, self.unified, networkInterfaces)
 	if err != nil {
 		return stats, err
 	}
@@ -266,6 +312,20 @@ func (self *dockerContainerHandler) GetStats() (*info.ContainerStats, error) {
 		convertInterfaceStats(&stats.Network.Interfaces[i])
 	}
 
+	// Get socket and protocol stats from the network namespace of the container's processes.
+	if len(config.Networks) > 0 {
+		pids, err := self.ListProcesses(container.ListSelf)
+		if err == nil && len(pids) > 0 {
+			err = containerLibcontainer.GetProcSocketStats(pids[0], container.MetricEnabled(container.TcpMetrics), container.MetricEnabled(container.UdpMetrics), &stats.Network)
+			if err == nil {
+				err = containerLibcontainer.GetProcProtocolStats(pids[0], &stats.Network)
+			}
+			if err != nil {
+				glog.V(4).Infof("Failed to get socket stats of %q: %v", self.name, err)
+			}
+		}
+	}
+
 	// Get filesystem stats.
 	err = self.getFsStats(stats)
 	if err != nil {
@@ -320,6 +380,10 @@ func (self *dockerContainerHandler) ListContainers(listType container.ListType)
 }
 
 func (self *dockerContainerHandler) GetCgroupPath(resource string) (string, error) {
+	// All resources share the unified hierarchy.
+	if self.unified {
+		resource = containerLibcontainer.UnifiedHierarchy
+	}
 	path, ok := self.cgroupPaths[resource]
 	if !ok {
 		return "", fmt.Errorf("could not find path for resource %q for container %q\n", resource, self.name)
@@ -328,16 +392,24 @@ func (self *dockerContainerHandler) GetCgroupPath(resource string) (string, erro
 }
 
 func (self *dockerContainerHandler) ListThreads(listType container.ListType) ([]int, error) {
-	// TODO(vmarmol): Implement.
-	return nil, nil
+	if self.unified {
+		return containerLibcontainer.GetUnifiedThreads(self.cgroupPaths[containerLibcontainer.UnifiedHierarchy])
+	}
+	cpuPath, ok := self.cgroupPaths["cpu"]
+	if !ok {
+		return nil, nil
+	}
+	return containerLibcontainer.GetThreads(cpuPath)
 }
 
 func (self *dockerContainerHandler) GetContainerLabels() map[string]string {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.labels
 }
 
 func (self *dockerContainerHandler) ListProcesses(listType container.ListType) ([]int, error) {
-	return containerLibcontainer.GetProcesses(self.cgroupManager)
+	return containerLibcontainer.GetCgroupProcesses(self.cgroupManager, self.unified)
 }
 
 func (self *dockerContainerHandler) WatchSubcontainers(events chan container.SubcontainerEvent) error {
@@ -349,6 +421,12 @@ func (self *dockerContainerHandler) StopWatchingSubcontainers() error {
 	return nil
 }
 
+func (self *dockerContainerHandler) Cleanup() {
+	if self.onCleanup != nil {
+		self.onCleanup()
+	}
+}
+
 func (self *dockerContainerHandler) Exists() bool {
 	return containerLibcontainer.Exists(*dockerRootDir, *dockerRunDir, self.id)
 }
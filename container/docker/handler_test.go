@@ -0,0 +1,128 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/cadvisor/container"
+	containerLibcontainer "github.com/google/cadvisor/container/libcontainer"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMachineInfoFactory struct{}
+
+func (self *fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &info.MachineInfo{NumCores: 4}, nil
+}
+
+func (self *fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+// Writes the specified files, relative to a new temporary directory.
+func writeFakeFiles(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "docker")
+	require.NoError(t, err)
+	for name, content := range files {
+		file := path.Join(root, name)
+		require.NoError(t, os.MkdirAll(path.Dir(file), 0755))
+		require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	return root
+}
+
+func TestUnifiedStats(t *testing.T) {
+	fake := newFakeDocker(t)
+	defer fake.Stop()
+
+	name := path.Join("/docker", renamedId)
+	cgroupRoot := writeFakeFiles(t, map[string]string{
+		name + "/cgroup.procs":   "10\n",
+		name + "/cgroup.threads": "10\n11\n",
+		name + "/cpu.stat":       "usage_usec 3000\nuser_usec 2000\nsystem_usec 1000\n",
+		name + "/memory.current": "10000\n",
+		name + "/memory.stat":    "anon 4000\nfile 5000\n",
+		name + "/pids.current":   "2\n",
+		name + "/pids.max":       "100\n",
+	})
+	defer os.RemoveAll(cgroupRoot)
+	runDir := writeFakeFiles(t, map[string]string{
+		path.Join("execdriver/native", renamedId, "state.json"): "{}",
+	})
+	defer os.RemoveAll(runDir)
+	oldRunDir := *dockerRunDir
+	*dockerRunDir = runDir
+	defer func() {
+		*dockerRunDir = oldRunDir
+	}()
+
+	subsystems := &containerLibcontainer.CgroupSubsystems{
+		MountPoints: map[string]string{
+			containerLibcontainer.UnifiedHierarchy: cgroupRoot,
+		},
+		Unified: true,
+	}
+	handler, err := newDockerContainerHandler(fake.client, name, &fakeMachineInfoFactory{}, nil, false, subsystems)
+	require.NoError(t, err)
+
+	stats, err := handler.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3000000), stats.Cpu.Usage.Total)
+	assert.Equal(t, uint64(10000), stats.Memory.Usage)
+	assert.Equal(t, uint64(2), stats.Processes.Pids)
+
+	spec, err := handler.GetSpec()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), spec.Processes.Limit)
+
+	processes, err := handler.ListProcesses(container.ListSelf)
+	require.NoError(t, err)
+	assert.Equal(t, []int{10}, processes)
+	threads, err := handler.ListThreads(container.ListSelf)
+	require.NoError(t, err)
+	assert.Equal(t, []int{10, 11}, threads)
+
+	// All resources are in the unified hierarchy.
+	cgroupPath, err := handler.GetCgroupPath("memory")
+	require.NoError(t, err)
+	assert.Equal(t, path.Join(cgroupRoot, name), cgroupPath)
+}
+
+func TestCleanupForgetsHandler(t *testing.T) {
+	fake := newFakeDocker(t)
+	defer fake.Stop()
+	oldEndpoint := *ArgDockerEndpoint
+	*ArgDockerEndpoint = fake.server.URL
+	defer func() {
+		*ArgDockerEndpoint = oldEndpoint
+	}()
+
+	factory := &dockerFactory{
+		machineInfoFactory: &fakeMachineInfoFactory{},
+		handlers:           make(map[string]*dockerContainerHandler),
+	}
+	handler, err := factory.NewContainerHandler(path.Join("/docker", renamedId))
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(factory.handlers))
+
+	handler.Cleanup()
+	assert.Empty(t, factory.handlers)
+}
@@ -0,0 +1,263 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Statuses of Docker events that change the set of running containers, their
+// names or their state.
+const (
+	dockerEventStart   = "start"
+	dockerEventStop    = "stop"
+	dockerEventDie     = "die"
+	dockerEventDestroy = "destroy"
+	dockerEventRename  = "rename"
+	dockerEventRestart = "restart"
+	dockerEventPause   = "pause"
+	dockerEventUnpause = "unpause"
+
+	// Followed by the new health status, e.g.: "health_status: healthy".
+	dockerEventHealthStatusPrefix = "health_status: "
+)
+
+// Delays between attempts to reconnect to the Docker event stream. The delay
+// doubles after every failed attempt, up to the max.
+var (
+	initialReconnectDelay = 1 * time.Second
+	maxReconnectDelay     = 1 * time.Minute
+)
+
+// Size of the buffer for events received from the Docker client.
+const dockerEventBufferSize = 16
+
+// Watches the Docker event stream for containers being started, stopped and
+// renamed. While the stream is disconnected containers are still discovered
+// through cgroup watches and global housekeeping.
+func (self *dockerFactory) WatchSubcontainers(events chan container.SubcontainerEvent) error {
+	listener := make(chan *docker.APIEvents, dockerEventBufferSize)
+	err := self.client.AddEventListener(listener)
+	if err != nil {
+		return fmt.Errorf("failed to listen for Docker events: %v", err)
+	}
+	self.stopWatcher = make(chan error)
+	go self.watchEvents(listener, events)
+	return nil
+}
+
+func (self *dockerFactory) StopWatchingSubcontainers() error {
+	if self.stopWatcher == nil {
+		// Not watching.
+		return nil
+	}
+
+	// Rendezvous with the watcher thread.
+	self.stopWatcher <- nil
+	err := <-self.stopWatcher
+	self.stopWatcher = nil
+	return err
+}
+
+// Registers the channel on which lifecycle events of Docker containers are sent.
+func (self *dockerFactory) WatchLifecycleEvents(events chan *info.Event) {
+	self.lifecycleEvents = events
+}
+
+func (self *dockerFactory) watchEvents(listener chan *docker.APIEvents, events chan container.SubcontainerEvent) {
+	// Only set while disconnected from the event stream.
+	var reconnect <-chan time.Time
+	reconnectDelay := initialReconnectDelay
+	for {
+		select {
+		case event, ok := <-listener:
+			if !ok {
+				// The Docker client closes its listeners when the event stream ends.
+				glog.Warningf("Lost connection to the Docker event stream, relying on cgroup watches until it is restored")
+				listener = nil
+				reconnect = time.After(reconnectDelay)
+				continue
+			}
+			glog.V(3).Infof("Docker event %q for container %q", event.Status, event.ID)
+
+			// Don't block on the receivers if asked to stop meanwhile.
+			if self.lifecycleEvents != nil {
+				if lifecycleEvent := self.toLifecycleEvent(event); lifecycleEvent != nil {
+					select {
+					case self.lifecycleEvents <- lifecycleEvent:
+					case <-self.stopWatcher:
+						self.stop(listener)
+						return
+					}
+				}
+			}
+			if subcontainerEvent := self.toSubcontainerEvent(event); subcontainerEvent != nil {
+				select {
+				case events <- *subcontainerEvent:
+				case <-self.stopWatcher:
+					self.stop(listener)
+					return
+				}
+			}
+		case <-reconnect:
+			newListener := make(chan *docker.APIEvents, dockerEventBufferSize)
+			err := self.client.AddEventListener(newListener)
+			if err != nil {
+				reconnectDelay *= 2
+				if reconnectDelay > maxReconnectDelay {
+					reconnectDelay = maxReconnectDelay
+				}
+				glog.V(2).Infof("Failed to reconnect to the Docker event stream, retrying in %v: %v", reconnectDelay, err)
+				reconnect = time.After(reconnectDelay)
+				continue
+			}
+			glog.Infof("Reconnected to the Docker event stream")
+			listener = newListener
+			reconnect = nil
+			reconnectDelay = initialReconnectDelay
+
+			// Events may have been missed while disconnected.
+			self.pruneHandlers()
+		case <-self.stopWatcher:
+			self.stop(listener)
+			return
+		}
+	}
+}
+
+// Stops listening to the event stream and replies to the pending stop request.
+func (self *dockerFactory) stop(listener chan *docker.APIEvents) {
+	var err error
+	if listener != nil {
+		err = self.removeListener(listener)
+	}
+	self.stopWatcher <- err
+	glog.Infof("Exiting thread watching Docker events")
+}
+
+// Removes the listener from the Docker client. The client may be blocked
+// delivering an event to it, so the listener is drained until it is removed.
+func (self *dockerFactory) removeListener(listener chan *docker.APIEvents) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-listener:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return self.client.RemoveEventListener(listener)
+}
+
+// Translates a Docker event into a subcontainer event, if it is one we care about.
+func (self *dockerFactory) toSubcontainerEvent(event *docker.APIEvents) *container.SubcontainerEvent {
+	var eventType container.SubcontainerEventType
+	switch event.Status {
+	case dockerEventStart:
+		eventType = container.SubcontainerAdd
+	case dockerEventDie, dockerEventDestroy:
+		eventType = container.SubcontainerDelete
+		self.handlersLock.Lock()
+		delete(self.handlers, event.ID)
+		self.handlersLock.Unlock()
+	case dockerEventRename:
+		self.handlersLock.Lock()
+		handler, ok := self.handlers[event.ID]
+		self.handlersLock.Unlock()
+		if !ok {
+			// Not a container we are tracking.
+			return nil
+		}
+		err := handler.refresh()
+		if err != nil {
+			glog.Warningf("Failed to refresh renamed Docker container %q: %v", event.ID, err)
+			return nil
+		}
+		eventType = container.SubcontainerUpdate
+	default:
+		// Ignore other events.
+		return nil
+	}
+
+	return &container.SubcontainerEvent{
+		EventType: eventType,
+		Name:      FullContainerName(event.ID),
+	}
+}
+
+// Translates a Docker event into a lifecycle event of the container, if it is one.
+// Docker's own "oom" events are not recorded since the kernel log already
+// surfaces them, exits of OOM killed containers are marked as such instead.
+func (self *dockerFactory) toLifecycleEvent(event *docker.APIEvents) *info.Event {
+	newEvent := &info.Event{
+		ContainerName: FullContainerName(event.ID),
+		Timestamp:     time.Unix(event.Time, 0),
+	}
+	switch {
+	case event.Status == dockerEventStart:
+		newEvent.EventType = info.EventContainerStart
+	case event.Status == dockerEventStop:
+		newEvent.EventType = info.EventContainerStop
+	case event.Status == dockerEventDie:
+		newEvent.EventType = info.EventContainerExit
+
+		// The exit code is only available by inspecting the container.
+		ctnr, err := self.client.InspectContainer(event.ID)
+		if err != nil {
+			glog.V(2).Infof("Failed to inspect exited Docker container %q: %v", event.ID, err)
+			break
+		}
+		newEvent.EventData.ContainerExit = &info.ContainerExitEventData{
+			ExitCode:  ctnr.State.ExitCode,
+			OomKilled: ctnr.State.OOMKilled,
+			Error:     ctnr.State.Error,
+		}
+	case event.Status == dockerEventRestart:
+		newEvent.EventType = info.EventContainerRestart
+	case event.Status == dockerEventPause:
+		newEvent.EventType = info.EventContainerPause
+	case event.Status == dockerEventUnpause:
+		newEvent.EventType = info.EventContainerUnpause
+	case strings.HasPrefix(event.Status, dockerEventHealthStatusPrefix):
+		newEvent.EventType = info.EventContainerHealthStatus
+		newEvent.EventData.ContainerHealth = &info.ContainerHealthEventData{
+			Status: strings.TrimPrefix(event.Status, dockerEventHealthStatusPrefix),
+		}
+	default:
+		return nil
+	}
+	return newEvent
+}
+
+// Forgets handlers of containers that no longer exist.
+func (self *dockerFactory) pruneHandlers() {
+	self.handlersLock.Lock()
+	defer self.handlersLock.Unlock()
+	for id, handler := range self.handlers {
+		if !handler.Exists() {
+			delete(self.handlers, id)
+		}
+	}
+}
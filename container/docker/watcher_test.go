@@ -0,0 +1,279 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	renamedId   = "renamed"
+	renamedName = "new-name"
+)
+
+// Fake Docker daemon whose event stream serves the given batches of events,
+// one batch per connection. The last connection is held open until stopped.
+type fakeDocker struct {
+	server *httptest.Server
+	client *docker.Client
+
+	lock        sync.Mutex
+	batches     [][]docker.APIEvents
+	connections int
+	done        chan struct{}
+
+	// Containers returned when inspected, keyed by ID.
+	containers map[string]*docker.Container
+}
+
+func newFakeDocker(t *testing.T, batches ...[]docker.APIEvents) *fakeDocker {
+	self := &fakeDocker{
+		batches: batches,
+		done:    make(chan struct{}),
+		containers: map[string]*docker.Container{
+			renamedId: {
+				ID:   renamedId,
+				Name: "/" + renamedName,
+				Config: &docker.Config{
+					Labels: map[string]string{"renamed": "true"},
+				},
+			},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", self.serveEvents)
+	mux.HandleFunc("/containers/", self.serveInspect)
+	self.server = httptest.NewServer(mux)
+	client, err := docker.NewClient(self.server.URL)
+	require.NoError(t, err)
+	self.client = client
+	return self
+}
+
+func (self *fakeDocker) serveInspect(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/json") {
+		http.NotFound(w, r)
+		return
+	}
+	id := path.Base(path.Dir(r.URL.Path))
+	ctnr, ok := self.containers[id]
+	if !ok {
+		http.Error(w, "no such container", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ctnr)
+}
+
+func (self *fakeDocker) serveEvents(w http.ResponseWriter, r *http.Request) {
+	self.lock.Lock()
+	batch := self.batches[self.connections]
+	self.connections++
+	last := self.connections == len(self.batches)
+	self.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	for _, event := range batch {
+		encoder.Encode(event)
+	}
+	w.(http.Flusher).Flush()
+
+	// Ending the response closes the event stream.
+	if last {
+		<-self.done
+	}
+}
+
+// Returns the number of connections to the event stream so far.
+func (self *fakeDocker) numConnections() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.connections
+}
+
+func (self *fakeDocker) Stop() {
+	close(self.done)
+	self.server.Close()
+}
+
+func newEvent(status, id string) docker.APIEvents {
+	return docker.APIEvents{
+		Status: status,
+		ID:     id,
+		From:   "busybox",
+		Time:   time.Now().Unix(),
+	}
+}
+
+// Reads the expected number of events, in any order since the Docker client
+// does not preserve it.
+func readEvents(t *testing.T, events chan container.SubcontainerEvent, num int) map[string]container.SubcontainerEventType {
+	received := make(map[string]container.SubcontainerEventType, num)
+	for i := 0; i < num; i++ {
+		select {
+		case event := <-events:
+			received[event.Name] = event.EventType
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for events, received %v", received)
+		}
+	}
+	return received
+}
+
+func TestWatchSubcontainers(t *testing.T) {
+	fake := newFakeDocker(t, []docker.APIEvents{
+		newEvent("create", "created"),
+		newEvent("start", "started"),
+		newEvent("die", "died"),
+		newEvent("rename", renamedId),
+		newEvent("rename", "untracked"),
+	})
+	defer fake.Stop()
+
+	renamed := &dockerContainerHandler{
+		client:  fake.client,
+		id:      renamedId,
+		aliases: []string{"old-name", renamedId},
+	}
+	factory := &dockerFactory{
+		client: fake.client,
+		handlers: map[string]*dockerContainerHandler{
+			"died":    {id: "died"},
+			renamedId: renamed,
+		},
+	}
+
+	events := make(chan container.SubcontainerEvent, 16)
+	require.NoError(t, factory.WatchSubcontainers(events))
+	received := readEvents(t, events, 3)
+	require.NoError(t, factory.StopWatchingSubcontainers())
+
+	assert.Equal(t, map[string]container.SubcontainerEventType{
+		FullContainerName("started"): container.SubcontainerAdd,
+		FullContainerName("died"):    container.SubcontainerDelete,
+		FullContainerName(renamedId): container.SubcontainerUpdate,
+	}, received)
+	assert.Len(t, events, 0)
+
+	// Deleted containers are forgotten and renamed ones are refreshed.
+	_, ok := factory.handlers["died"]
+	assert.False(t, ok)
+	ref, err := renamed.ContainerReference()
+	require.NoError(t, err)
+	assert.Equal(t, []string{renamedName, renamedId}, ref.Aliases)
+	assert.Equal(t, map[string]string{"renamed": "true"}, renamed.GetContainerLabels())
+}
+
+func TestWatchSubcontainersReconnects(t *testing.T) {
+	oldDelay := initialReconnectDelay
+	initialReconnectDelay = 10 * time.Millisecond
+	defer func() {
+		initialReconnectDelay = oldDelay
+	}()
+
+	// The first connection is closed without sending any events.
+	fake := newFakeDocker(t,
+		[]docker.APIEvents{},
+		[]docker.APIEvents{newEvent("start", "after")},
+	)
+	defer fake.Stop()
+
+	factory := &dockerFactory{
+		client:   fake.client,
+		handlers: make(map[string]*dockerContainerHandler),
+	}
+
+	events := make(chan container.SubcontainerEvent, 16)
+	require.NoError(t, factory.WatchSubcontainers(events))
+	received := readEvents(t, events, 1)
+	require.NoError(t, factory.StopWatchingSubcontainers())
+
+	assert.Equal(t, map[string]container.SubcontainerEventType{
+		FullContainerName("after"): container.SubcontainerAdd,
+	}, received)
+	assert.Equal(t, 2, fake.numConnections())
+}
+
+func TestStopWatchingSubcontainersWhenNotWatching(t *testing.T) {
+	factory := &dockerFactory{}
+	assert.NoError(t, factory.StopWatchingSubcontainers())
+}
+
+func TestWatchLifecycleEvents(t *testing.T) {
+	started := newEvent("start", "crashing")
+	fake := newFakeDocker(t, []docker.APIEvents{
+		started,
+		newEvent("die", "crashing"),
+		newEvent("restart", "crashing"),
+		newEvent("pause", "paused"),
+		newEvent("health_status: unhealthy", "sick"),
+		newEvent("create", "created"),
+	})
+	defer fake.Stop()
+	fake.containers["crashing"] = &docker.Container{
+		ID: "crashing",
+		State: docker.State{
+			ExitCode:  137,
+			OOMKilled: true,
+		},
+	}
+
+	factory := &dockerFactory{
+		client:   fake.client,
+		handlers: make(map[string]*dockerContainerHandler),
+	}
+	lifecycleEvents := make(chan *info.Event, 16)
+	factory.WatchLifecycleEvents(lifecycleEvents)
+	events := make(chan container.SubcontainerEvent, 16)
+	require.NoError(t, factory.WatchSubcontainers(events))
+
+	received := make(map[info.EventType]*info.Event)
+	for i := 0; i < 5; i++ {
+		select {
+		case event := <-lifecycleEvents:
+			received[event.EventType] = event
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for lifecycle events, received %v", received)
+		}
+	}
+	require.NoError(t, factory.StopWatchingSubcontainers())
+
+	require.Equal(t, 5, len(received))
+	assert.Equal(t, FullContainerName("crashing"), received[info.EventContainerStart].ContainerName)
+	assert.Equal(t, time.Unix(started.Time, 0), received[info.EventContainerStart].Timestamp)
+	assert.Equal(t, &info.ContainerExitEventData{
+		ExitCode:  137,
+		OomKilled: true,
+	}, received[info.EventContainerExit].EventData.ContainerExit)
+	assert.Equal(t, FullContainerName("crashing"), received[info.EventContainerRestart].ContainerName)
+	assert.Equal(t, FullContainerName("paused"), received[info.EventContainerPause].ContainerName)
+	assert.Equal(t, &info.ContainerHealthEventData{
+		Status: "unhealthy",
+	}, received[info.EventContainerHealthStatus].EventData.ContainerHealth)
+}
@@ -19,6 +19,7 @@ import (
 	"sync"
 
 	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
 )
 
 type ContainerHandlerFactory interface {
@@ -35,6 +36,25 @@ type ContainerHandlerFactory interface {
 	DebugInfo() map[string][]string
 }
 
+// Optionally implemented by a ContainerHandlerFactory that can learn about the
+// creation and deletion of its containers without relying on cgroup watches
+// (e.g.: from the container runtime's event stream).
+type SubcontainerWatcher interface {
+	// Registers a channel to listen for events affecting containers of this factory.
+	WatchSubcontainers(events chan SubcontainerEvent) error
+
+	// Stops watching for container changes.
+	StopWatchingSubcontainers() error
+}
+
+// Optionally implemented by a SubcontainerWatcher that also reports lifecycle
+// events of its containers (e.g.: exits, restarts and health changes).
+type LifecycleEventWatcher interface {
+	// Registers a channel to receive lifecycle events on. Must be called
+	// before WatchSubcontainers().
+	WatchLifecycleEvents(events chan *info.Event)
+}
+
 // TODO(vmarmol): Consider not making this global.
 // Global list of factories.
 var (
@@ -94,6 +114,49 @@ func ClearContainerHandlerFactories() {
 	factories = make([]ContainerHandlerFactory, 0, 4)
 }
 
+// Starts watching for container events on all the factories that support it.
+// Factories that fail to start watching are skipped and logged, their containers
+// are still discovered through cgroup watches. Lifecycle events are sent on
+// lifecycleEvents by the factories that report them.
+func WatchFactories(events chan SubcontainerEvent, lifecycleEvents chan *info.Event) {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	for _, factory := range factories {
+		watcher, ok := factory.(SubcontainerWatcher)
+		if !ok {
+			continue
+		}
+		if lifecycleWatcher, ok := factory.(LifecycleEventWatcher); ok {
+			lifecycleWatcher.WatchLifecycleEvents(lifecycleEvents)
+		}
+		err := watcher.WatchSubcontainers(events)
+		if err != nil {
+			glog.Warningf("Factory %q failed to watch for containers, relying on cgroup watches: %v", factory, err)
+			continue
+		}
+		glog.Infof("Factory %q is watching for containers", factory)
+	}
+}
+
+// Stops watching for container events on all the factories that support it.
+func StopWatchingFactories() error {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	for _, factory := range factories {
+		watcher, ok := factory.(SubcontainerWatcher)
+		if !ok {
+			continue
+		}
+		err := watcher.StopWatchingSubcontainers()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func DebugInfo() map[string][]string {
 	factoriesLock.RLock()
 	defer factoriesLock.RUnlock()
@@ -0,0 +1,292 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Stats of containers in the cgroup v2 unified hierarchy.
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/mount"
+	"github.com/docker/libcontainer/cgroups"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Key of the unified hierarchy in CgroupSubsystems.MountPoints.
+const UnifiedHierarchy = "unified"
+
+// Controllers of the unified hierarchy we get stats from.
+var supportedUnifiedControllers = map[string]struct{}{
+	"cpu":     {},
+	"cpuset":  {},
+	"memory":  {},
+	"io":      {},
+	"pids":    {},
+	"hugetlb": {},
+}
+
+// Get the mount point of the cgroup v2 unified hierarchy, or "" if it is not mounted.
+func getUnifiedMountPoint() (string, error) {
+	mounts, err := mount.GetMounts()
+	if err != nil {
+		return "", err
+	}
+	for _, m := range mounts {
+		if m.Fstype == "cgroup2" {
+			return m.Mountpoint, nil
+		}
+	}
+	return "", nil
+}
+
+// Get information about the unified hierarchy mounted at the specified mount
+// point. Only the controllers enabled in the hierarchy are listed.
+func getUnifiedSubsystems(mountPoint string) (CgroupSubsystems, error) {
+	out, err := ioutil.ReadFile(path.Join(mountPoint, "cgroup.controllers"))
+	if err != nil {
+		return CgroupSubsystems{}, err
+	}
+
+	controllers := []string{}
+	for _, controller := range strings.Fields(string(out)) {
+		if _, ok := supportedUnifiedControllers[controller]; ok {
+			controllers = append(controllers, controller)
+		}
+	}
+	return CgroupSubsystems{
+		Mounts: []cgroups.Mount{
+			{
+				Mountpoint: mountPoint,
+				Subsystems: controllers,
+			},
+		},
+		MountPoints: map[string]string{
+			UnifiedHierarchy: mountPoint,
+		},
+		Unified: true,
+	}, nil
+}
+
+// Reads a flat keyed file (e.g.: cpu.stat or memory.stat) of the specified cgroup.
+func readUnifiedKeyedFile(cgroupPath string, file string) (map[string]uint64, error) {
+	f, err := os.Open(path.Join(cgroupPath, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line %q in %q", scanner.Text(), file)
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q in %q: %v", fields[0], file, err)
+		}
+		values[fields[0]] = value
+	}
+	return values, scanner.Err()
+}
+
+// Reads a file of the specified cgroup holding a single value.
+func readUnifiedValue(cgroupPath string, file string) (uint64, error) {
+	out, err := ioutil.ReadFile(path.Join(cgroupPath, file))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// Files of the unified hierarchy are only present if the controller is
+// enabled for the cgroup, so missing files are not errors.
+func ignoreNotExist(err error) error {
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func getUnifiedCpuStats(cgroupPath string, ret *info.ContainerStats) error {
+	values, err := readUnifiedKeyedFile(cgroupPath, "cpu.stat")
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	// Times are reported in microseconds.
+	ret.Cpu.Usage.Total = values["usage_usec"] * uint64(time.Microsecond)
+	ret.Cpu.Usage.User = values["user_usec"] * uint64(time.Microsecond)
+	ret.Cpu.Usage.System = values["system_usec"] * uint64(time.Microsecond)
+	ret.Cpu.CFS.Periods = values["nr_periods"]
+	ret.Cpu.CFS.ThrottledPeriods = values["nr_throttled"]
+	ret.Cpu.CFS.ThrottledTime = values["throttled_usec"] * uint64(time.Microsecond)
+	return nil
+}
+
+func getUnifiedMemoryStats(cgroupPath string, ret *info.ContainerStats) error {
+	usage, err := readUnifiedValue(cgroupPath, "memory.current")
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	ret.Memory.Usage = usage
+
+	// Only reported by kernels 5.19 and newer.
+	if maxUsage, err := readUnifiedValue(cgroupPath, "memory.peak"); err == nil {
+		ret.Memory.MaxUsage = maxUsage
+	}
+	if swap, err := readUnifiedValue(cgroupPath, "memory.swap.current"); err == nil {
+		ret.Memory.Swap = swap
+	}
+
+	values, err := readUnifiedKeyedFile(cgroupPath, "memory.stat")
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	// The unified hierarchy always reports hierarchical stats.
+	ret.Memory.ContainerData.Pgfault = values["pgfault"]
+	ret.Memory.ContainerData.Pgmajfault = values["pgmajfault"]
+	ret.Memory.HierarchicalData = ret.Memory.ContainerData
+	ret.Memory.Cache = values["file"]
+	ret.Memory.RSS = values["anon"]
+	ret.Memory.RSSHuge = values["anon_thp"]
+	ret.Memory.MappedFile = values["file_mapped"]
+	ret.Memory.Writeback = values["file_writeback"]
+	ret.Memory.ActiveFile = values["active_file"]
+	ret.Memory.InactiveFile = values["inactive_file"]
+	// Only reported as a total by kernels 5.18 and newer.
+	if kernel, ok := values["kernel"]; ok {
+		ret.Memory.KernelUsage = kernel
+	} else {
+		ret.Memory.KernelUsage = values["kernel_stack"] + values["pagetables"] + values["percpu"] + values["slab"]
+	}
+
+	// Same as for cgroup v1: usage without the inactive memory.
+	workingSet := ret.Memory.Usage
+	for _, inactive := range []uint64{values["inactive_anon"], values["inactive_file"]} {
+		if workingSet < inactive {
+			workingSet = 0
+		} else {
+			workingSet -= inactive
+		}
+	}
+	ret.Memory.WorkingSet = workingSet
+
+	events, err := readUnifiedKeyedFile(cgroupPath, "memory.events")
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	// Number of times the usage was about to go over the limit.
+	ret.Memory.Failcnt = events["max"]
+	return nil
+}
+
+// Reads io.stat, which has a line per device, e.g.:
+// 8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0
+func getUnifiedDiskIoStats(cgroupPath string, ret *info.ContainerStats) error {
+	f, err := os.Open(path.Join(cgroupPath, "io.stat"))
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var major, minor uint64
+		if _, err := fmt.Sscanf(fields[0], "%d:%d", &major, &minor); err != nil {
+			return fmt.Errorf("invalid device %q in io.stat: %v", fields[0], err)
+		}
+		values := make(map[string]uint64, len(fields)-1)
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse %q in io.stat: %v", field, err)
+			}
+			values[kv[0]] = value
+		}
+
+		serviceBytes := DiskStatsCopy0(major, minor)
+		serviceBytes.Stats["Read"] = values["rbytes"]
+		serviceBytes.Stats["Write"] = values["wbytes"]
+		serviceBytes.Stats["Total"] = values["rbytes"] + values["wbytes"]
+		ret.DiskIo.IoServiceBytes = append(ret.DiskIo.IoServiceBytes, *serviceBytes)
+
+		serviced := DiskStatsCopy0(major, minor)
+		serviced.Stats["Read"] = values["rios"]
+		serviced.Stats["Write"] = values["wios"]
+		serviced.Stats["Total"] = values["rios"] + values["wios"]
+		ret.DiskIo.IoServiced = append(ret.DiskIo.IoServiced, *serviced)
+	}
+	return scanner.Err()
+}
+
+func getUnifiedHugetlbStats(cgroupPath string, ret *info.ContainerStats) error {
+	return getHugetlbStats(cgroupPath, true, ret)
+}
+
+func getUnifiedNumaStats(cgroupPath string, ret *info.ContainerStats) error {
+	return getNumaStats(cgroupPath, true, ret)
+}
+
+// Get cgroup and networking stats of the container at the specified path of the unified hierarchy.
+func GetUnifiedStats(cgroupPath string, networkInterfaces []string) (*info.ContainerStats, error) {
+	stats := new(info.ContainerStats)
+	stats.Timestamp = time.Now()
+
+	for _, getStats := range []func(string, *info.ContainerStats) error{
+		getUnifiedCpuStats,
+		getUnifiedMemoryStats,
+		getUnifiedDiskIoStats,
+		getUnifiedPressureStats,
+	} {
+		if err := getStats(cgroupPath, stats); err != nil {
+			return stats, fmt.Errorf("failed to get stats of cgroup %q: %v", cgroupPath, err)
+		}
+	}
+	for _, getStats := range []func(string, *info.ContainerStats) error{
+		getPidsStats,
+		getUnifiedHugetlbStats,
+		getUnifiedNumaStats,
+	} {
+		logOptionalStatsError(cgroupPath, getStats(cgroupPath, stats))
+	}
+
+	err := getNetworkInterfaceStats(networkInterfaces, stats)
+	return stats, err
+}
+
+// Get the processes of the container at the specified path of the unified hierarchy.
+func GetUnifiedProcesses(cgroupPath string) ([]int, error) {
+	return cgroups.ReadProcsFile(cgroupPath)
+}
+
+// Get the threads of the container at the specified path of the unified hierarchy.
+func GetUnifiedThreads(cgroupPath string) ([]int, error) {
+	return readTasksFile(cgroupPath, "cgroup.threads")
+}
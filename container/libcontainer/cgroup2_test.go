@@ -0,0 +1,185 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a fake cgroupfs tree with the specified files, relative to its root.
+func newFakeCgroupfs(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "cgroupfs")
+	require.NoError(t, err)
+	for name, content := range files {
+		file := path.Join(root, name)
+		require.NoError(t, os.MkdirAll(path.Dir(file), 0755))
+		require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	return root
+}
+
+func TestGetUnifiedSubsystems(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"cgroup.controllers": "cpuset cpu io memory hugetlb pids rdma\n",
+	})
+	defer os.RemoveAll(root)
+
+	subsystems, err := getUnifiedSubsystems(root)
+	require.NoError(t, err)
+	assert.True(t, subsystems.Unified)
+	assert.Equal(t, map[string]string{UnifiedHierarchy: root}, subsystems.MountPoints)
+	require.Equal(t, 1, len(subsystems.Mounts))
+	assert.Equal(t, root, subsystems.Mounts[0].Mountpoint)
+	assert.Equal(t, []string{"cpuset", "cpu", "io", "memory", "hugetlb", "pids"}, subsystems.Mounts[0].Subsystems)
+}
+
+func TestGetUnifiedStats(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"test/cpu.stat": `usage_usec 3000
+user_usec 2000
+system_usec 1000
+nr_periods 100
+nr_throttled 10
+throttled_usec 500
+`,
+		"test/memory.current":      "10000\n",
+		"test/memory.swap.current": "300\n",
+		"test/memory.stat": `anon 4000
+file 5000
+file_mapped 600
+file_writeback 20
+anon_thp 2048
+inactive_anon 1000
+active_anon 3000
+inactive_file 2000
+active_file 3000
+kernel 1500
+pgfault 70
+pgmajfault 7
+`,
+		"test/memory.events": `low 0
+high 0
+max 4
+oom 1
+oom_kill 1
+`,
+		"test/io.stat":      "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0\n",
+		"test/pids.current": "12\n",
+	})
+	defer os.RemoveAll(root)
+
+	stats, err := GetUnifiedStats(path.Join(root, "test"), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, info.CpuUsage{
+		Total:  3000000,
+		User:   2000000,
+		System: 1000000,
+	}, stats.Cpu.Usage)
+	assert.Equal(t, info.CpuCFS{
+		Periods:          100,
+		ThrottledPeriods: 10,
+		ThrottledTime:    500000,
+	}, stats.Cpu.CFS)
+
+	assert.Equal(t, uint64(10000), stats.Memory.Usage)
+	assert.Equal(t, uint64(7000), stats.Memory.WorkingSet)
+	assert.Equal(t, uint64(4), stats.Memory.Failcnt)
+	assert.Equal(t, uint64(5000), stats.Memory.Cache)
+	assert.Equal(t, uint64(4000), stats.Memory.RSS)
+	assert.Equal(t, uint64(2048), stats.Memory.RSSHuge)
+	assert.Equal(t, uint64(600), stats.Memory.MappedFile)
+	assert.Equal(t, uint64(300), stats.Memory.Swap)
+	assert.Equal(t, uint64(20), stats.Memory.Writeback)
+	assert.Equal(t, uint64(3000), stats.Memory.ActiveFile)
+	assert.Equal(t, uint64(1500), stats.Memory.KernelUsage)
+	assert.Equal(t, uint64(2000), stats.Memory.InactiveFile)
+	assert.Equal(t, info.MemoryStatsMemoryData{Pgfault: 70, Pgmajfault: 7}, stats.Memory.ContainerData)
+	assert.Equal(t, info.MemoryStatsMemoryData{Pgfault: 70, Pgmajfault: 7}, stats.Memory.HierarchicalData)
+
+	assert.Equal(t, []info.PerDiskStats{
+		{Major: 8, Minor: 0, Stats: map[string]uint64{"Read": 1024, "Write": 2048, "Total": 3072}},
+	}, stats.DiskIo.IoServiceBytes)
+	assert.Equal(t, []info.PerDiskStats{
+		{Major: 8, Minor: 0, Stats: map[string]uint64{"Read": 1, "Write": 2, "Total": 3}},
+	}, stats.DiskIo.IoServiced)
+
+	assert.Equal(t, uint64(12), stats.Processes.Pids)
+}
+
+// Controllers that are not enabled for a cgroup have no files.
+func TestGetUnifiedStatsMissingControllers(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"test/cpu.stat": "usage_usec 1\nuser_usec 1\nsystem_usec 0\n",
+	})
+	defer os.RemoveAll(root)
+
+	stats, err := GetUnifiedStats(path.Join(root, "test"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), stats.Cpu.Usage.Total)
+	assert.Equal(t, info.MemoryStats{}, stats.Memory)
+	assert.Equal(t, info.DiskIoStats{}, stats.DiskIo)
+}
+
+// Stats that not all kernels have do not fail the others.
+func TestGetUnifiedStatsInvalidOptionalStats(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"test/cpu.stat":         "usage_usec 1\nuser_usec 1\nsystem_usec 0\n",
+		"test/pids.current":     "many\n",
+		"test/memory.numa_stat": "anon N0\n",
+	})
+	defer os.RemoveAll(root)
+
+	stats, err := GetUnifiedStats(path.Join(root, "test"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), stats.Cpu.Usage.Total)
+	assert.Equal(t, uint64(0), stats.Processes.Pids)
+}
+
+func TestGetUnifiedStatsInvalid(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"test/cpu.stat": "usage_usec one\n",
+	})
+	defer os.RemoveAll(root)
+
+	_, err := GetUnifiedStats(path.Join(root, "test"), nil)
+	assert.Error(t, err)
+}
+
+func TestReadCgroupLimit(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"memory.max":      "max\n",
+		"memory.swap.max": "1048576\n",
+	})
+	defer os.RemoveAll(root)
+
+	limit, err := ReadCgroupLimit(root, "memory.max")
+	require.NoError(t, err)
+	assert.Equal(t, ^uint64(0), limit)
+
+	limit, err = ReadCgroupLimit(root, "memory.swap.max")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1048576), limit)
+
+	_, err = ReadCgroupLimit(root, "memory.high")
+	assert.Error(t, err)
+}
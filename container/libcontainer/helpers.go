@@ -15,12 +15,19 @@
 package libcontainer
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/cgroups"
+	"github.com/golang/glog"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/utils/sysinfo"
 )
@@ -32,19 +39,21 @@ type CgroupSubsystems struct {
 
 	// Cgroup subsystem to their mount location.
 	// e.g.: "cpu" -> "/sys/fs/cgroup/cpu"
+	// In the unified hierarchy, only UnifiedHierarchy is mapped to its mount location.
 	MountPoints map[string]string
+
+	// Whether the subsystems are in the cgroup v2 unified hierarchy.
+	Unified bool
 }
 
-// Get information about the cgroup subsystems.
+// Get information about the cgroup subsystems. The unified hierarchy is only
+// used if no supported subsystem is mounted in a cgroup v1 hierarchy.
 func GetCgroupSubsystems() (CgroupSubsystems, error) {
 	// Get all cgroup mounts.
 	allCgroups, err := cgroups.GetCgroupMounts()
 	if err != nil {
 		return CgroupSubsystems{}, err
 	}
-	if len(allCgroups) == 0 {
-		return CgroupSubsystems{}, fmt.Errorf("failed to find cgroup mounts")
-	}
 
 	// Trim the mounts to only the subsystems we care about.
 	supportedCgroups := make([]cgroups.Mount, 0, len(allCgroups))
@@ -58,6 +67,19 @@ func GetCgroupSubsystems() (CgroupSubsystems, error) {
 		}
 	}
 
+	if len(supportedCgroups) == 0 {
+		unifiedMountPoint, err := getUnifiedMountPoint()
+		if err != nil {
+			return CgroupSubsystems{}, err
+		}
+		if unifiedMountPoint != "" {
+			return getUnifiedSubsystems(unifiedMountPoint)
+		}
+	}
+	if len(allCgroups) == 0 {
+		return CgroupSubsystems{}, fmt.Errorf("failed to find cgroup mounts")
+	}
+
 	return CgroupSubsystems{
 		Mounts:      supportedCgroups,
 		MountPoints: mountPoints,
@@ -71,6 +93,8 @@ var supportedSubsystems map[string]struct{} = map[string]struct{}{
 	"memory":  {},
 	"cpuset":  {},
 	"blkio":   {},
+	"pids":    {},
+	"hugetlb": {},
 }
 
 // Get cgroup and networking stats of the specified container
@@ -84,12 +108,49 @@ func GetStats(cgroupManager cgroups.Manager, networkInterfaces []string) (*info.
 	}
 	stats := toContainerStats(libcontainerStats)
 
+	// The pids and hugetlb cgroups, NUMA and kernel memory stats are not
+	// supported by libcontainer.
+	paths := cgroupManager.GetPaths()
+	if pidsPath, ok := paths["pids"]; ok {
+		logOptionalStatsError(pidsPath, getPidsStats(pidsPath, stats))
+	}
+	if hugetlbPath, ok := paths["hugetlb"]; ok {
+		logOptionalStatsError(hugetlbPath, getHugetlbStats(hugetlbPath, false, stats))
+	}
+	if memoryPath, ok := paths["memory"]; ok {
+		logOptionalStatsError(memoryPath, getNumaStats(memoryPath, false, stats))
+		logOptionalStatsError(memoryPath, getKernelMemoryStats(memoryPath, stats))
+	}
+
 	// TODO(rjnagal): Use networking stats directly from libcontainer.
+	err = getNetworkInterfaceStats(networkInterfaces, stats)
+	return stats, err
+}
+
+// Stats that not all kernels have do not fail the other stats of the
+// container when they can not be read.
+func logOptionalStatsError(cgroupPath string, err error) {
+	if err != nil {
+		glog.V(4).Infof("Failed to get optional stats of cgroup %q: %v", cgroupPath, err)
+	}
+}
+
+// Get cgroup and networking stats of the container managed by the specified
+// cgroup manager, whose paths are in the unified hierarchy if it is used.
+func GetCgroupStats(cgroupManager cgroups.Manager, unified bool, networkInterfaces []string) (*info.ContainerStats, error) {
+	if unified {
+		return GetUnifiedStats(cgroupManager.GetPaths()[UnifiedHierarchy], networkInterfaces)
+	}
+	return GetStats(cgroupManager, networkInterfaces)
+}
+
+// Get the stats of the specified network interfaces of the host.
+func getNetworkInterfaceStats(networkInterfaces []string, stats *info.ContainerStats) error {
 	stats.Network.Interfaces = make([]info.InterfaceStats, len(networkInterfaces))
 	for i := range networkInterfaces {
 		interfaceStats, err := sysinfo.GetNetworkStats(networkInterfaces[i])
 		if err != nil {
-			return stats, err
+			return err
 		}
 		stats.Network.Interfaces[i] = interfaceStats
 	}
@@ -97,8 +158,141 @@ func GetStats(cgroupManager cgroups.Manager, networkInterfaces []string) (*info.
 	if len(networkInterfaces) > 0 {
 		stats.Network.InterfaceStats = stats.Network.Interfaces[0]
 	}
+	return nil
+}
 
-	return stats, nil
+// Get the stats of the network interfaces in the network namespace of the
+// specified process, from /proc/<pid>/net/dev. Loopback is ignored.
+func GetProcNetworkStats(pid int) ([]info.InterfaceStats, error) {
+	netDev := path.Join("/proc", strconv.Itoa(pid), "net/dev")
+	file, err := os.Open(netDev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q: %v", netDev, err)
+	}
+	defer file.Close()
+
+	return scanInterfaceStats(file)
+}
+
+// Get the network namespace of the specified process (e.g.: "net:[4026531956]").
+func GetNetworkNamespace(pid int) (string, error) {
+	return os.Readlink(path.Join("/proc", strconv.Itoa(pid), "ns/net"))
+}
+
+// Parses interface stats in the format of /proc/net/dev.
+func scanInterfaceStats(reader io.Reader) ([]info.InterfaceStats, error) {
+	var stats []info.InterfaceStats
+	scanner := bufio.NewScanner(reader)
+
+	// The first two lines are headers.
+	for i := 0; i < 2; i++ {
+		scanner.Scan()
+	}
+	for scanner.Scan() {
+		line := strings.Replace(scanner.Text(), ":", " ", 1)
+		fields := strings.Fields(line)
+		if len(fields) != 17 {
+			return nil, fmt.Errorf("invalid interface stats line: %q", scanner.Text())
+		}
+		if fields[0] == "lo" {
+			continue
+		}
+
+		// Receive and transmit fields are: bytes, packets, errs, drop, fifo, ...
+		values := make([]uint64, 16)
+		for i := range values {
+			value, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid interface stats line %q: %v", scanner.Text(), err)
+			}
+			values[i] = value
+		}
+		stats = append(stats, info.InterfaceStats{
+			Name:      fields[0],
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		})
+	}
+	return stats, scanner.Err()
+}
+
+// Reads a limit of the specified cgroup in the format of the pids controller
+// and the unified hierarchy. Unlimited ("max") is reported as the largest
+// possible value.
+func ReadCgroupLimit(cgroupPath string, file string) (uint64, error) {
+	out, err := ioutil.ReadFile(path.Join(cgroupPath, file))
+	if err != nil {
+		return 0, err
+	}
+	value := strings.Fields(string(out))
+	if len(value) == 0 {
+		return 0, fmt.Errorf("empty limit in %q", file)
+	}
+	if value[0] == "max" {
+		return ^uint64(0), nil
+	}
+	return strconv.ParseUint(value[0], 10, 64)
+}
+
+// Reads a file listing a task per line (e.g.: tasks or cgroup.threads) of the specified cgroup.
+func readTasksFile(cgroupPath string, file string) ([]int, error) {
+	f, err := os.Open(path.Join(cgroupPath, file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tasks := []int{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			task, err := strconv.Atoi(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid task %q in %q: %v", line, file, err)
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, scanner.Err()
+}
+
+// Get the threads of the cgroup v1 hierarchy at the specified path.
+func GetThreads(cgroupPath string) ([]int, error) {
+	return readTasksFile(cgroupPath, "tasks")
+}
+
+// Get the number of tasks of the pids cgroup at the specified path. The
+// pids.current file is the same in cgroup v1 and the unified hierarchy, but
+// it is absent in the root cgroup.
+func getPidsStats(cgroupPath string, ret *info.ContainerStats) error {
+	out, err := ioutil.ReadFile(path.Join(cgroupPath, "pids.current"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	pids, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse pids.current: %v", err)
+	}
+	ret.Processes.Pids = pids
+	return nil
+}
+
+// Get the processes of the container managed by the specified cgroup manager,
+// whose paths are in the unified hierarchy if it is used.
+func GetCgroupProcesses(cgroupManager cgroups.Manager, unified bool) ([]int, error) {
+	if unified {
+		return GetUnifiedProcesses(cgroupManager.GetPaths()[UnifiedHierarchy])
+	}
+	return GetProcesses(cgroupManager)
 }
 
 func GetProcesses(cgroupManager cgroups.Manager) ([]int, error) {
@@ -175,6 +369,10 @@ func toContainerStats0(s *cgroups.Stats, ret *info.ContainerStats) {
 		ret.Cpu.Usage.PerCpu[i] = s.CpuStats.CpuUsage.PercpuUsage[i]
 		ret.Cpu.Usage.Total += s.CpuStats.CpuUsage.PercpuUsage[i]
 	}
+
+	ret.Cpu.CFS.Periods = s.CpuStats.ThrottlingData.Periods
+	ret.Cpu.CFS.ThrottledPeriods = s.CpuStats.ThrottlingData.ThrottledPeriods
+	ret.Cpu.CFS.ThrottledTime = s.CpuStats.ThrottlingData.ThrottledTime
 }
 
 func toContainerStats1(s *cgroups.Stats, ret *info.ContainerStats) {
@@ -190,6 +388,17 @@ func toContainerStats1(s *cgroups.Stats, ret *info.ContainerStats) {
 
 func toContainerStats2(s *cgroups.Stats, ret *info.ContainerStats) {
 	ret.Memory.Usage = s.MemoryStats.Usage
+	ret.Memory.MaxUsage = s.MemoryStats.MaxUsage
+	ret.Memory.Failcnt = s.MemoryStats.Failcnt
+	// The hierarchical totals are used to be consistent with the usage.
+	ret.Memory.Cache = s.MemoryStats.Stats["total_cache"]
+	ret.Memory.RSS = s.MemoryStats.Stats["total_rss"]
+	ret.Memory.RSSHuge = s.MemoryStats.Stats["total_rss_huge"]
+	ret.Memory.MappedFile = s.MemoryStats.Stats["total_mapped_file"]
+	ret.Memory.Swap = s.MemoryStats.Stats["total_swap"]
+	ret.Memory.Writeback = s.MemoryStats.Stats["total_writeback"]
+	ret.Memory.ActiveFile = s.MemoryStats.Stats["total_active_file"]
+	ret.Memory.InactiveFile = s.MemoryStats.Stats["total_inactive_file"]
 	if v, ok := s.MemoryStats.Stats["pgfault"]; ok {
 		ret.Memory.ContainerData.Pgfault = v
 		ret.Memory.HierarchicalData.Pgfault = v
@@ -0,0 +1,112 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/libcontainer/cgroups"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  123456     100    0    0    0     0          0         0   123456     100    0    0    0     0       0          0
+  eth0: 1000000    2000    3    4    0     0          0         5   500000    1000    6    7    0     0       0          0
+`
+
+func TestScanInterfaceStats(t *testing.T) {
+	stats, err := scanInterfaceStats(strings.NewReader(netDev))
+	assert.NoError(t, err)
+	assert.Equal(t, []info.InterfaceStats{
+		{
+			Name:      "eth0",
+			RxBytes:   1000000,
+			RxPackets: 2000,
+			RxErrors:  3,
+			RxDropped: 4,
+			TxBytes:   500000,
+			TxPackets: 1000,
+			TxErrors:  6,
+			TxDropped: 7,
+		},
+	}, stats)
+}
+
+func TestScanInterfaceStatsInvalid(t *testing.T) {
+	_, err := scanInterfaceStats(strings.NewReader(netDev + "  eth1: 1 2 3\n"))
+	assert.Error(t, err)
+}
+
+func TestGetNetworkNamespace(t *testing.T) {
+	netns, err := GetNetworkNamespace(os.Getpid())
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(netns, "net:["), "unexpected network namespace %q", netns)
+
+	_, err = GetNetworkNamespace(-1)
+	assert.Error(t, err)
+}
+
+func TestCpuThrottlingStats(t *testing.T) {
+	s := &cgroups.Stats{}
+	s.CpuStats.CpuUsage.PercpuUsage = []uint64{1, 2}
+	s.CpuStats.ThrottlingData = cgroups.ThrottlingData{
+		Periods:          100,
+		ThrottledPeriods: 10,
+		ThrottledTime:    5000,
+	}
+	ret := &info.ContainerStats{}
+	toContainerStats0(s, ret)
+	assert.Equal(t, info.CpuCFS{
+		Periods:          100,
+		ThrottledPeriods: 10,
+		ThrottledTime:    5000,
+	}, ret.Cpu.CFS)
+}
+
+func TestMemoryBreakdownStats(t *testing.T) {
+	s := &cgroups.Stats{}
+	s.MemoryStats = cgroups.MemoryStats{
+		Usage:    1000,
+		MaxUsage: 2000,
+		Failcnt:  3,
+		Stats: map[string]uint64{
+			"cache":               1,
+			"total_cache":         100,
+			"total_rss":           200,
+			"total_rss_huge":      50,
+			"total_mapped_file":   30,
+			"total_swap":          10,
+			"total_writeback":     5,
+			"total_active_file":   60,
+			"total_inactive_file": 40,
+		},
+	}
+	ret := &info.ContainerStats{}
+	toContainerStats2(s, ret)
+	assert.Equal(t, uint64(2000), ret.Memory.MaxUsage)
+	assert.Equal(t, uint64(3), ret.Memory.Failcnt)
+	assert.Equal(t, uint64(100), ret.Memory.Cache)
+	assert.Equal(t, uint64(200), ret.Memory.RSS)
+	assert.Equal(t, uint64(50), ret.Memory.RSSHuge)
+	assert.Equal(t, uint64(30), ret.Memory.MappedFile)
+	assert.Equal(t, uint64(10), ret.Memory.Swap)
+	assert.Equal(t, uint64(5), ret.Memory.Writeback)
+	assert.Equal(t, uint64(60), ret.Memory.ActiveFile)
+	assert.Equal(t, uint64(40), ret.Memory.InactiveFile)
+}
@@ -0,0 +1,238 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Hugetlb and NUMA memory stats, which libcontainer does not report.
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Names of the hugetlb files, which have the page size (e.g.: "2MB") in the middle.
+type hugetlbFiles struct {
+	usage    string
+	maxUsage string
+	limit    string
+}
+
+var (
+	hugetlbFilesV1 = hugetlbFiles{
+		usage:    "usage_in_bytes",
+		maxUsage: "max_usage_in_bytes",
+		limit:    "limit_in_bytes",
+	}
+	hugetlbFilesUnified = hugetlbFiles{
+		usage: "current",
+		limit: "max",
+	}
+)
+
+// Get the huge page sizes (e.g.: "2MB") the hugetlb cgroup at the specified path accounts.
+func getHugePageSizes(cgroupPath string, files hugetlbFiles) ([]string, error) {
+	entries, err := ioutil.ReadDir(cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+	pageSizes := []string{}
+	suffix := "." + files.usage
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "hugetlb.") || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		// The reservation files of Linux 5.7+ (e.g.: "hugetlb.2MB.rsvd.current")
+		// have the same suffix, but page sizes have no dots.
+		pageSize := strings.TrimSuffix(strings.TrimPrefix(name, "hugetlb."), suffix)
+		if strings.Contains(pageSize, ".") {
+			continue
+		}
+		pageSizes = append(pageSizes, pageSize)
+	}
+	sort.Strings(pageSizes)
+	return pageSizes, nil
+}
+
+// Get the kernel memory usage of the memory cgroup v1 at the specified path.
+// Kernels without kernel memory accounting have no memory.kmem files.
+func getKernelMemoryStats(cgroupPath string, ret *info.ContainerStats) error {
+	usage, err := readUnifiedValue(cgroupPath, "memory.kmem.usage_in_bytes")
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	ret.Memory.KernelUsage = usage
+	return nil
+}
+
+func readHugetlbValue(cgroupPath string, pageSize string, file string) (uint64, error) {
+	return ReadCgroupLimit(cgroupPath, fmt.Sprintf("hugetlb.%s.%s", pageSize, file))
+}
+
+// Get the usage of huge pages of the hugetlb cgroup at the specified path, per page size.
+func getHugetlbStats(cgroupPath string, unified bool, ret *info.ContainerStats) error {
+	files := hugetlbFilesV1
+	if unified {
+		files = hugetlbFilesUnified
+	}
+	pageSizes, err := getHugePageSizes(cgroupPath, files)
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	if len(pageSizes) == 0 {
+		return nil
+	}
+
+	ret.Hugetlb = make(map[string]info.HugetlbStats, len(pageSizes))
+	for _, pageSize := range pageSizes {
+		var stats info.HugetlbStats
+		stats.Usage, err = readHugetlbValue(cgroupPath, pageSize, files.usage)
+		if err != nil {
+			return err
+		}
+		if unified {
+			// Number of times an allocation failed because of the limit.
+			events, err := readUnifiedKeyedFile(cgroupPath, fmt.Sprintf("hugetlb.%s.events", pageSize))
+			if err == nil {
+				stats.Failcnt = events["max"]
+			}
+		} else {
+			stats.MaxUsage, err = readHugetlbValue(cgroupPath, pageSize, files.maxUsage)
+			if err != nil {
+				return err
+			}
+			stats.Failcnt, err = readHugetlbValue(cgroupPath, pageSize, "failcnt")
+			if err != nil {
+				return err
+			}
+		}
+		ret.Hugetlb[pageSize] = stats
+	}
+	return nil
+}
+
+// Get the limits of huge pages of the hugetlb cgroup at the specified path, per page size.
+func GetHugetlbLimits(cgroupPath string, unified bool) (map[string]uint64, error) {
+	files := hugetlbFilesV1
+	if unified {
+		files = hugetlbFilesUnified
+	}
+	pageSizes, err := getHugePageSizes(cgroupPath, files)
+	if err != nil || len(pageSizes) == 0 {
+		return nil, err
+	}
+
+	limits := make(map[string]uint64, len(pageSizes))
+	for _, pageSize := range pageSizes {
+		limit, err := readHugetlbValue(cgroupPath, pageSize, files.limit)
+		if err != nil {
+			return nil, err
+		}
+		limits[pageSize] = limit
+	}
+	return limits, nil
+}
+
+// Parses memory.numa_stat into the value of each stat per node. Lines look like:
+// cgroup v1: "file=44428 N0=32614 N1=7335" (in pages, with the total first)
+// unified hierarchy: "file N0=32614 N1=7335" (in bytes)
+func parseNumaStat(reader io.Reader) (map[string]map[int]uint64, error) {
+	stats := make(map[string]map[int]uint64)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.SplitN(fields[0], "=", 2)[0]
+		nodes := make(map[int]uint64, len(fields)-1)
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "N") {
+				return nil, fmt.Errorf("invalid field %q in memory.numa_stat", field)
+			}
+			kv := strings.SplitN(field[1:], "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid field %q in memory.numa_stat", field)
+			}
+			node, err := strconv.Atoi(kv[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid node in %q of memory.numa_stat: %v", field, err)
+			}
+			value, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in %q of memory.numa_stat: %v", field, err)
+			}
+			nodes[node] = value
+		}
+		stats[name] = nodes
+	}
+	return stats, scanner.Err()
+}
+
+// Get the memory usage per NUMA node of the memory cgroup at the specified path.
+func getNumaStats(cgroupPath string, unified bool, ret *info.ContainerStats) error {
+	f, err := os.Open(path.Join(cgroupPath, "memory.numa_stat"))
+	if err != nil {
+		return ignoreNotExist(err)
+	}
+	defer f.Close()
+
+	stats, err := parseNumaStat(f)
+	if err != nil {
+		return err
+	}
+
+	// Stats of cgroup v1 are in pages and also have hierarchical versions,
+	// which are preferred to be consistent with the usage.
+	multiplier := uint64(1)
+	prefix := ""
+	if !unified {
+		multiplier = uint64(os.Getpagesize())
+		if _, ok := stats["hierarchical_total"]; ok {
+			prefix = "hierarchical_"
+		}
+	}
+
+	nodeIds := []int{}
+	for node := range stats[prefix+"file"] {
+		nodeIds = append(nodeIds, node)
+	}
+	sort.Ints(nodeIds)
+
+	ret.Memory.NumaNodes = make([]info.NodeMemoryStats, 0, len(nodeIds))
+	for _, node := range nodeIds {
+		nodeStats := info.NodeMemoryStats{
+			Node:        node,
+			File:        stats[prefix+"file"][node] * multiplier,
+			Anon:        stats[prefix+"anon"][node] * multiplier,
+			Unevictable: stats[prefix+"unevictable"][node] * multiplier,
+		}
+		if total, ok := stats[prefix+"total"]; ok {
+			nodeStats.Total = total[node] * multiplier
+		} else {
+			// The unified hierarchy has no total, which is the sum of the LRU lists.
+			nodeStats.Total = nodeStats.File + nodeStats.Anon + nodeStats.Unevictable
+		}
+		ret.Memory.NumaNodes = append(ret.Memory.NumaNodes, nodeStats)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHugetlbStatsV1(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"hugetlb.2MB.usage_in_bytes":     "4194304\n",
+		"hugetlb.2MB.max_usage_in_bytes": "8388608\n",
+		"hugetlb.2MB.limit_in_bytes":     "16777216\n",
+		"hugetlb.2MB.failcnt":            "2\n",
+		"hugetlb.1GB.usage_in_bytes":     "0\n",
+		"hugetlb.1GB.max_usage_in_bytes": "0\n",
+		"hugetlb.1GB.limit_in_bytes":     "18446744073709551615\n",
+		"hugetlb.1GB.failcnt":            "0\n",
+		// Reservations are not reported.
+		"hugetlb.2MB.rsvd.usage_in_bytes":     "6291456\n",
+		"hugetlb.2MB.rsvd.max_usage_in_bytes": "6291456\n",
+		"hugetlb.2MB.rsvd.limit_in_bytes":     "16777216\n",
+	})
+	defer os.RemoveAll(root)
+
+	stats := &info.ContainerStats{}
+	require.NoError(t, getHugetlbStats(root, false, stats))
+	assert.Equal(t, map[string]info.HugetlbStats{
+		"1GB": {},
+		"2MB": {Usage: 4194304, MaxUsage: 8388608, Failcnt: 2},
+	}, stats.Hugetlb)
+
+	limits, err := GetHugetlbLimits(root, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{
+		"1GB": ^uint64(0),
+		"2MB": 16777216,
+	}, limits)
+}
+
+func TestHugetlbStatsUnified(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"hugetlb.2MB.current": "2097152\n",
+		"hugetlb.2MB.max":     "max\n",
+		"hugetlb.2MB.events":  "max 3\n",
+		// Reservations are not reported.
+		"hugetlb.2MB.rsvd.current": "4194304\n",
+		"hugetlb.2MB.rsvd.max":     "max\n",
+	})
+	defer os.RemoveAll(root)
+
+	stats := &info.ContainerStats{}
+	require.NoError(t, getHugetlbStats(root, true, stats))
+	assert.Equal(t, map[string]info.HugetlbStats{
+		"2MB": {Usage: 2097152, Failcnt: 3},
+	}, stats.Hugetlb)
+
+	limits, err := GetHugetlbLimits(root, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"2MB": ^uint64(0)}, limits)
+}
+
+func TestNumaStatsV1(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"memory.numa_stat": `total=30 N0=20 N1=10
+file=15 N0=10 N1=5
+anon=12 N0=9 N1=3
+unevictable=3 N0=1 N1=2
+hierarchical_total=60 N0=40 N1=20
+hierarchical_file=30 N0=20 N1=10
+hierarchical_anon=24 N0=18 N1=6
+hierarchical_unevictable=6 N0=2 N1=4
+`,
+	})
+	defer os.RemoveAll(root)
+
+	stats := &info.ContainerStats{}
+	require.NoError(t, getNumaStats(root, false, stats))
+	page := uint64(os.Getpagesize())
+	assert.Equal(t, []info.NodeMemoryStats{
+		{Node: 0, Total: 40 * page, File: 20 * page, Anon: 18 * page, Unevictable: 2 * page},
+		{Node: 1, Total: 20 * page, File: 10 * page, Anon: 6 * page, Unevictable: 4 * page},
+	}, stats.Memory.NumaNodes)
+}
+
+func TestNumaStatsUnified(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"memory.numa_stat": `anon N0=4096 N1=0
+file N0=8192 N1=4096
+kernel_stack N0=16384 N1=0
+unevictable N0=0 N1=4096
+`,
+	})
+	defer os.RemoveAll(root)
+
+	stats := &info.ContainerStats{}
+	require.NoError(t, getNumaStats(root, true, stats))
+	assert.Equal(t, []info.NodeMemoryStats{
+		{Node: 0, Total: 12288, File: 8192, Anon: 4096},
+		{Node: 1, Total: 8192, File: 4096, Unevictable: 4096},
+	}, stats.Memory.NumaNodes)
+}
+
+func TestParseNumaStatInvalid(t *testing.T) {
+	_, err := parseNumaStat(strings.NewReader("total=1 X0=1\n"))
+	assert.Error(t, err)
+}
+
+// Hosts without the hugetlb cgroup or NUMA support have no stats.
+func TestMissingMemoryFiles(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{})
+	defer os.RemoveAll(root)
+
+	stats := &info.ContainerStats{}
+	require.NoError(t, getHugetlbStats(root, false, stats))
+	require.NoError(t, getNumaStats(root, false, stats))
+	assert.Nil(t, stats.Hugetlb)
+	assert.Nil(t, stats.Memory.NumaNodes)
+}
+
+func TestKernelMemoryStatsV1(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"memory.kmem.usage_in_bytes": "2048\n",
+	})
+	defer os.RemoveAll(root)
+
+	stats := &info.ContainerStats{}
+	require.NoError(t, getKernelMemoryStats(root, stats))
+	assert.Equal(t, uint64(2048), stats.Memory.KernelUsage)
+
+	// Kernels without kernel memory accounting have no usage.
+	require.NoError(t, os.Remove(path.Join(root, "memory.kmem.usage_in_bytes")))
+	stats = &info.ContainerStats{}
+	require.NoError(t, getKernelMemoryStats(root, stats))
+	assert.Equal(t, uint64(0), stats.Memory.KernelUsage)
+}
@@ -0,0 +1,142 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Pressure stall information (PSI) of cgroups and the host.
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/utils"
+)
+
+// Directory of the pressure files of the host.
+const hostPressureDir = "/proc/pressure"
+
+// Parses a pressure file, which has a line for some and full stalls, e.g.:
+//
+//	some avg10=0.12 avg60=0.40 avg300=0.23 total=12345678
+//	full avg10=0.00 avg60=0.10 avg300=0.05 total=2345678
+func parsePressure(r io.Reader) (info.PSIStats, error) {
+	var stats info.PSIStats
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var data *info.PSIData
+		switch fields[0] {
+		case "some":
+			data = &stats.Some
+		case "full":
+			data = &stats.Full
+		default:
+			return stats, fmt.Errorf("invalid pressure line %q", scanner.Text())
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return stats, fmt.Errorf("invalid pressure field %q", field)
+			}
+			var err error
+			switch kv[0] {
+			case "avg10":
+				data.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				data.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				data.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				data.Total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return stats, fmt.Errorf("invalid pressure field %q: %v", field, err)
+			}
+		}
+	}
+	return stats, scanner.Err()
+}
+
+// Reads a pressure file. Kernels without PSI have no pressure files.
+func readPressureFile(file string) (info.PSIStats, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return info.PSIStats{}, nil
+		}
+		return info.PSIStats{}, err
+	}
+	defer f.Close()
+
+	stats, err := parsePressure(f)
+	if err != nil {
+		return stats, fmt.Errorf("failed to parse %q: %v", file, err)
+	}
+	return stats, nil
+}
+
+// Reads the cpu, memory and io pressure files of a directory, whose names
+// have the specified suffix.
+func readPressureStats(dir string, suffix string) (info.PressureStats, error) {
+	var stats info.PressureStats
+	var err error
+	if stats.Cpu, err = readPressureFile(path.Join(dir, "cpu"+suffix)); err != nil {
+		return stats, err
+	}
+	if stats.Memory, err = readPressureFile(path.Join(dir, "memory"+suffix)); err != nil {
+		return stats, err
+	}
+	if stats.Io, err = readPressureFile(path.Join(dir, "io"+suffix)); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+func getUnifiedPressureStats(cgroupPath string, ret *info.ContainerStats) error {
+	stats, err := readPressureStats(cgroupPath, ".pressure")
+	if err != nil {
+		return err
+	}
+	ret.Pressure = stats
+	return nil
+}
+
+// Returns whether the cgroup at the specified path of the unified hierarchy has
+// pressure stall information.
+func HasUnifiedPressure(cgroupPath string) bool {
+	return utils.FileExists(path.Join(cgroupPath, "cpu.pressure"))
+}
+
+// Returns whether the kernel of the host has pressure stall information.
+func HasHostPressure() bool {
+	return utils.FileExists(path.Join(hostPressureDir, "cpu"))
+}
+
+// Get the pressure stall information of the whole host, from /proc/pressure.
+func GetHostPressureStats(ret *info.ContainerStats) error {
+	stats, err := readPressureStats(hostPressureDir, "")
+	if err != nil {
+		return err
+	}
+	ret.Pressure = stats
+	return nil
+}
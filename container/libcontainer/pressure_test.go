@@ -0,0 +1,86 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePressure(t *testing.T) {
+	stats, err := parsePressure(strings.NewReader(`some avg10=1.25 avg60=0.40 avg300=0.10 total=12345678
+full avg10=0.50 avg60=0.20 avg300=0.05 total=2345678
+`))
+	require.NoError(t, err)
+	assert.Equal(t, info.PSIStats{
+		Some: info.PSIData{Avg10: 1.25, Avg60: 0.40, Avg300: 0.10, Total: 12345678},
+		Full: info.PSIData{Avg10: 0.50, Avg60: 0.20, Avg300: 0.05, Total: 2345678},
+	}, stats)
+}
+
+func TestParsePressureInvalid(t *testing.T) {
+	for _, input := range []string{
+		"partial avg10=0.00 total=0\n",
+		"some avg10\n",
+		"some avg10=x\n",
+		"some total=-1\n",
+	} {
+		_, err := parsePressure(strings.NewReader(input))
+		assert.Error(t, err, "input %q", input)
+	}
+}
+
+func TestGetUnifiedPressureStats(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"test/cpu.stat":        "usage_usec 0\n",
+		"test/cpu.pressure":    "some avg10=2.00 avg60=1.00 avg300=0.50 total=1000\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		"test/memory.pressure": "some avg10=0.00 avg60=0.00 avg300=0.00 total=300\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=200\n",
+	})
+	defer os.RemoveAll(root)
+
+	stats, err := GetUnifiedStats(path.Join(root, "test"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, info.PressureStats{
+		Cpu: info.PSIStats{
+			Some: info.PSIData{Avg10: 2, Avg60: 1, Avg300: 0.5, Total: 1000},
+		},
+		Memory: info.PSIStats{
+			Some: info.PSIData{Total: 300},
+			Full: info.PSIData{Total: 200},
+		},
+	}, stats.Pressure)
+}
+
+func TestReadHostPressureStats(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"io": "some avg10=0.00 avg60=0.00 avg300=0.00 total=42\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=21\n",
+	})
+	defer os.RemoveAll(root)
+
+	stats, err := readPressureStats(root, "")
+	require.NoError(t, err)
+	assert.Equal(t, info.PressureStats{
+		Io: info.PSIStats{
+			Some: info.PSIData{Total: 42},
+			Full: info.PSIData{Total: 21},
+		},
+	}, stats)
+}
@@ -0,0 +1,163 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Protocol counters of network namespaces.
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Get the TCP and UDP counters of the network namespace of the specified
+// process, from /proc/<pid>/net/snmp, /proc/<pid>/net/netstat and
+// /proc/<pid>/net/snmp6.
+func GetProcProtocolStats(pid int, stats *info.NetworkStats) error {
+	netDir := path.Join("/proc", strconv.Itoa(pid), "net")
+	snmp, err := readProcNetCounters(path.Join(netDir, "snmp"))
+	if err != nil {
+		return err
+	}
+	netstat, err := readProcNetCounters(path.Join(netDir, "netstat"))
+	if err != nil {
+		return err
+	}
+	snmp6, err := readProcNetSnmp6(path.Join(netDir, "snmp6"))
+	if err != nil {
+		return err
+	}
+
+	stats.Protocol = info.ProtocolStat{
+		TcpRetransSegs:     snmp["Tcp"]["RetransSegs"],
+		TcpOutRsts:         snmp["Tcp"]["OutRsts"],
+		TcpEstabResets:     snmp["Tcp"]["EstabResets"],
+		TcpAttemptFails:    snmp["Tcp"]["AttemptFails"],
+		TcpTimeouts:        netstat["TcpExt"]["TCPTimeouts"],
+		TcpListenOverflows: netstat["TcpExt"]["ListenOverflows"],
+		TcpListenDrops:     netstat["TcpExt"]["ListenDrops"],
+		UdpInErrors:        snmp["Udp"]["InErrors"],
+		UdpRcvbufErrors:    snmp["Udp"]["RcvbufErrors"],
+		UdpSndbufErrors:    snmp["Udp"]["SndbufErrors"],
+		Udp6InErrors:       snmp6["Udp6InErrors"],
+		Udp6RcvbufErrors:   snmp6["Udp6RcvbufErrors"],
+		Udp6SndbufErrors:   snmp6["Udp6SndbufErrors"],
+	}
+	return nil
+}
+
+// Opens a file of /proc/<pid>/net, which is nil if it does not exist.
+func openProcNetFile(file string) (*os.File, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return f, err
+}
+
+// Reads the counters of a file like /proc/net/snmp. Missing files have no counters.
+func readProcNetCounters(file string) (map[string]map[string]uint64, error) {
+	f, err := openProcNetFile(file)
+	if f == nil || err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counters, err := scanProcNetCounters(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", file, err)
+	}
+	return counters, nil
+}
+
+// Reads the counters of /proc/net/snmp6, which is missing when IPv6 is
+// disabled.
+func readProcNetSnmp6(file string) (map[string]uint64, error) {
+	f, err := openProcNetFile(file)
+	if f == nil || err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	counters, err := scanProcNetSnmp6(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %v", file, err)
+	}
+	return counters, nil
+}
+
+// Parses /proc/net/snmp6, which has a line per counter with its name and value:
+//
+//	Udp6InErrors                    	5
+func scanProcNetSnmp6(r io.Reader) (map[string]uint64, error) {
+	counters := make(map[string]uint64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid counter line %q", scanner.Text())
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %v", fields[0], err)
+		}
+		counters[fields[0]] = value
+	}
+	return counters, scanner.Err()
+}
+
+// Parses the counters of each protocol of /proc/net/{snmp,netstat}, which have
+// a line with the names of the counters followed by a line with their values:
+//
+//	Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens ...
+//	Tcp: 1 200 120000 -1 1234 ...
+//
+// Counters with negative values (e.g.: MaxConn) are ignored.
+func scanProcNetCounters(r io.Reader) (map[string]map[string]uint64, error) {
+	counters := make(map[string]map[string]uint64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		names := strings.Fields(scanner.Text())
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("missing values of %q", names)
+		}
+		values := strings.Fields(scanner.Text())
+		if len(names) == 0 || len(names) != len(values) || names[0] != values[0] {
+			return nil, fmt.Errorf("mismatched names and values of %q", names)
+		}
+
+		protocol := strings.TrimSuffix(names[0], ":")
+		counters[protocol] = make(map[string]uint64, len(names)-1)
+		for i := 1; i < len(names); i++ {
+			if strings.HasPrefix(values[i], "-") {
+				continue
+			}
+			value, err := strconv.ParseUint(values[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %s %s: %v", protocol, names[i], err)
+			}
+			counters[protocol][names[i]] = value
+		}
+	}
+	return counters, scanner.Err()
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const procNetSnmp = `Ip: Forwarding DefaultTTL InReceives InHdrErrors
+Ip: 1 64 123456 0
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 1000 200 7 3 12 50000 48000 42 0 9 0
+Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors InCsumErrors
+Udp: 3000 10 5 2900 4 1 0
+`
+
+const procNetNetstat = `TcpExt: SyncookiesSent SyncookiesRecv ListenOverflows ListenDrops TCPTimeouts
+TcpExt: 0 0 2 6 11
+IpExt: InNoRoutes InTruncatedPkts
+IpExt: 0 0
+`
+
+const procNetSnmp6 = `Ip6InReceives                   	1234
+Udp6InDatagrams                 	300
+Udp6InErrors                    	3
+Udp6RcvbufErrors                	2
+Udp6SndbufErrors                	1
+`
+
+func TestScanProcNetCounters(t *testing.T) {
+	counters, err := scanProcNetCounters(strings.NewReader(procNetSnmp))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), counters["Tcp"]["RetransSegs"])
+	assert.Equal(t, uint64(4), counters["Udp"]["RcvbufErrors"])
+	assert.Equal(t, uint64(123456), counters["Ip"]["InReceives"])
+	_, ok := counters["Tcp"]["MaxConn"]
+	assert.False(t, ok)
+}
+
+func TestScanProcNetCountersInvalid(t *testing.T) {
+	for _, input := range []string{
+		"Tcp: RtoAlgorithm RtoMin\n",
+		"Tcp: RtoAlgorithm RtoMin\nTcp: 1\n",
+		"Tcp: RtoAlgorithm RtoMin\nUdp: 1 200\n",
+		"Tcp: RtoAlgorithm RtoMin\nTcp: 1 x\n",
+	} {
+		_, err := scanProcNetCounters(strings.NewReader(input))
+		assert.Error(t, err, "input %q", input)
+	}
+}
+
+func TestScanProcNetSnmp6(t *testing.T) {
+	counters, err := scanProcNetSnmp6(strings.NewReader(procNetSnmp6))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), counters["Udp6InErrors"])
+	assert.Equal(t, uint64(2), counters["Udp6RcvbufErrors"])
+	assert.Equal(t, uint64(1234), counters["Ip6InReceives"])
+
+	for _, input := range []string{"Udp6InErrors\n", "Udp6InErrors x\n", "Udp6InErrors 1 2\n"} {
+		_, err := scanProcNetSnmp6(strings.NewReader(input))
+		assert.Error(t, err, "input %q", input)
+	}
+}
+
+func TestReadProcNetCounters(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"net/snmp":    procNetSnmp,
+		"net/netstat": procNetNetstat,
+	})
+	defer os.RemoveAll(root)
+
+	netstat, err := readProcNetCounters(path.Join(root, "net/netstat"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]uint64{
+		"SyncookiesSent":  0,
+		"SyncookiesRecv":  0,
+		"ListenOverflows": 2,
+		"ListenDrops":     6,
+		"TCPTimeouts":     11,
+	}, netstat["TcpExt"])
+
+	// Kernels without the file have no counters.
+	missing, err := readProcNetCounters(path.Join(root, "net/snmp6"))
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -0,0 +1,197 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Socket stats of network namespaces.
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// TCP socket states, as in include/net/tcp_states.h.
+const (
+	tcpEstablished = iota + 1
+	tcpSynSent
+	tcpSynRecv
+	tcpFinWait1
+	tcpFinWait2
+	tcpTimeWait
+	tcpClose
+	tcpCloseWait
+	tcpLastAck
+	tcpListen
+	tcpClosing
+)
+
+// Get the TCP and UDP socket stats of the network namespace of the specified
+// process, from /proc/<pid>/net/{tcp,tcp6,udp,udp6}, of the protocols that
+// are enabled. Files of protocols that are not available (e.g.: IPv6
+// disabled) are ignored.
+func GetProcSocketStats(pid int, tcp, udp bool, stats *info.NetworkStats) error {
+	netDir := path.Join("/proc", strconv.Itoa(pid), "net")
+	var err error
+	if tcp {
+		if stats.Tcp, err = readTcpStats(path.Join(netDir, "tcp")); err != nil {
+			return err
+		}
+		if stats.Tcp6, err = readTcpStats(path.Join(netDir, "tcp6")); err != nil {
+			return err
+		}
+	}
+	if udp {
+		if stats.Udp, err = readUdpStats(path.Join(netDir, "udp")); err != nil {
+			return err
+		}
+		if stats.Udp6, err = readUdpStats(path.Join(netDir, "udp6")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTcpStats(file string) (info.TcpStat, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return info.TcpStat{}, nil
+		}
+		return info.TcpStat{}, err
+	}
+	defer f.Close()
+
+	stats, err := scanTcpStats(f)
+	if err != nil {
+		return stats, fmt.Errorf("failed to parse %q: %v", file, err)
+	}
+	return stats, nil
+}
+
+func readUdpStats(file string) (info.UdpStat, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return info.UdpStat{}, nil
+		}
+		return info.UdpStat{}, err
+	}
+	defer f.Close()
+
+	stats, err := scanUdpStats(f)
+	if err != nil {
+		return stats, fmt.Errorf("failed to parse %q: %v", file, err)
+	}
+	return stats, nil
+}
+
+// A socket of /proc/net/{tcp,udp}, which have a header line and then a line per socket, e.g.:
+//
+//	sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ...
+//	 0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12345 ...
+type procNetSocket struct {
+	state    uint64
+	txQueued uint64
+	rxQueued uint64
+	// Only the lines of UDP sockets end with the number of drops.
+	fields []string
+}
+
+func scanProcNetSockets(r io.Reader, handle func(socket procNetSocket)) error {
+	scanner := bufio.NewScanner(r)
+	// Skip the header.
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			return fmt.Errorf("invalid socket line %q", scanner.Text())
+		}
+		state, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return fmt.Errorf("invalid socket state %q: %v", fields[3], err)
+		}
+		queues := strings.SplitN(fields[4], ":", 2)
+		if len(queues) != 2 {
+			return fmt.Errorf("invalid socket queues %q", fields[4])
+		}
+		txQueued, err := strconv.ParseUint(queues[0], 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid socket queues %q: %v", fields[4], err)
+		}
+		rxQueued, err := strconv.ParseUint(queues[1], 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid socket queues %q: %v", fields[4], err)
+		}
+		handle(procNetSocket{
+			state:    state,
+			txQueued: txQueued,
+			rxQueued: rxQueued,
+			fields:   fields,
+		})
+	}
+	return scanner.Err()
+}
+
+func scanTcpStats(r io.Reader) (info.TcpStat, error) {
+	var stats info.TcpStat
+	err := scanProcNetSockets(r, func(socket procNetSocket) {
+		switch socket.state {
+		case tcpEstablished:
+			stats.Established++
+		case tcpSynSent:
+			stats.SynSent++
+		case tcpSynRecv:
+			stats.SynRecv++
+		case tcpFinWait1:
+			stats.FinWait1++
+		case tcpFinWait2:
+			stats.FinWait2++
+		case tcpTimeWait:
+			stats.TimeWait++
+		case tcpClose:
+			stats.Close++
+		case tcpCloseWait:
+			stats.CloseWait++
+		case tcpLastAck:
+			stats.LastAck++
+		case tcpListen:
+			stats.Listen++
+		case tcpClosing:
+			stats.Closing++
+		}
+	})
+	return stats, err
+}
+
+func scanUdpStats(r io.Reader) (info.UdpStat, error) {
+	var stats info.UdpStat
+	err := scanProcNetSockets(r, func(socket procNetSocket) {
+		// UDP sockets that are not connected are reported as closed.
+		if socket.state == tcpClose {
+			stats.Listen++
+		}
+		stats.TxQueued += socket.txQueued
+		stats.RxQueued += socket.rxQueued
+		if drops, err := strconv.ParseUint(socket.fields[len(socket.fields)-1], 10, 64); err == nil {
+			stats.Dropped += drops
+		}
+	})
+	return stats, err
+}
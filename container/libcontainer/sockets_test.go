@@ -0,0 +1,96 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const procNetTcp = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12345 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12346 1 0000000000000000 100 0 0 10 0
+   2: 0F02000A:0016 0202000A:D4C2 01 00000024:00000000 01:00000019 00000000     0        0 12347 4 0000000000000000 20 4 30 10 -1
+   3: 0F02000A:A1B2 0302000A:01BB 06 00000000:00000000 03:00000A8C 00000000     0        0 0 3 0000000000000000
+`
+
+const procNetUdp = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 00000000:0044 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 13579 2 0000000000000000 0
+  200: 3500007F:0035 00000000:0000 07 00000000:00000300 00:00000000 00000000   101        0 13580 2 0000000000000000 5
+  300: 0F02000A:9C40 0202000A:0035 01 00000010:00000000 00:00000000 00000000  1000        0 13581 2 0000000000000000 0
+`
+
+func TestScanTcpStats(t *testing.T) {
+	stats, err := scanTcpStats(strings.NewReader(procNetTcp))
+	require.NoError(t, err)
+	assert.Equal(t, info.TcpStat{
+		Established: 1,
+		TimeWait:    1,
+		Listen:      2,
+	}, stats)
+}
+
+func TestScanUdpStats(t *testing.T) {
+	stats, err := scanUdpStats(strings.NewReader(procNetUdp))
+	require.NoError(t, err)
+	assert.Equal(t, info.UdpStat{
+		Listen:   2,
+		Dropped:  5,
+		RxQueued: 0x300,
+		TxQueued: 0x10,
+	}, stats)
+}
+
+func TestScanSocketsInvalid(t *testing.T) {
+	for _, input := range []string{
+		"header\n   0: 0100007F:0CEA 00000000:0000\n",
+		"header\n   0: 0100007F:0CEA 00000000:0000 XX 00000000:00000000\n",
+		"header\n   0: 0100007F:0CEA 00000000:0000 0A 00000000\n",
+		"header\n   0: 0100007F:0CEA 00000000:0000 0A 0000000G:00000000\n",
+	} {
+		_, err := scanTcpStats(strings.NewReader(input))
+		assert.Error(t, err, "input %q", input)
+	}
+}
+
+func TestReadSocketStats(t *testing.T) {
+	root := newFakeCgroupfs(t, map[string]string{
+		"net/tcp": procNetTcp,
+		"net/udp": procNetUdp,
+	})
+	defer os.RemoveAll(root)
+
+	tcp, err := readTcpStats(path.Join(root, "net/tcp"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), tcp.Established)
+
+	udp, err := readUdpStats(path.Join(root, "net/udp"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), udp.Listen)
+
+	// IPv6 may be disabled.
+	tcp6, err := readTcpStats(path.Join(root, "net/tcp6"))
+	require.NoError(t, err)
+	assert.Equal(t, info.TcpStat{}, tcp6)
+	udp6, err := readUdpStats(path.Join(root, "net/udp6"))
+	require.NoError(t, err)
+	assert.Equal(t, info.UdpStat{}, udp6)
+}
@@ -0,0 +1,245 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machined
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/fs"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// The namespace under which machine names are unique.
+const MachinedNamespace = "machined"
+
+// Slice under which systemd-machined places the scopes of its machines.
+const machineSlice = "/machine.slice"
+
+// A machine registered with systemd-machined (e.g.: a systemd-nspawn container or a rkt pod).
+type machine struct {
+	// Name of the machine.
+	Name string
+
+	// Class of the machine: "container" or "vm".
+	Class string
+
+	// Service that registered the machine (e.g.: "nspawn" or "rkt").
+	Service string
+
+	// Scope unit of the machine (e.g.: "machine-foo.scope").
+	Unit string
+
+	// PID of the leader process of the machine.
+	Leader int
+
+	// Root directory of the machine on the host, if any.
+	RootDirectory string
+}
+
+// Source of the machines registered with systemd-machined.
+type machineRegistry interface {
+	// Returns the machine whose scope is the specified unit, or nil if there is none.
+	GetMachineByUnit(unit string) (*machine, error)
+}
+
+// Registry of machines backed by the systemd-machined D-Bus API.
+type dbusMachineRegistry struct {
+	conn *dbus.Conn
+}
+
+// Checks that systemd-machined can be reached.
+func (self *dbusMachineRegistry) ping() error {
+	manager := self.conn.Object("org.freedesktop.machine1", "/org/freedesktop/machine1")
+	var machines []struct {
+		Name    string
+		Class   string
+		Service string
+		Path    dbus.ObjectPath
+	}
+	return manager.Call("org.freedesktop.machine1.Manager.ListMachines", 0).Store(&machines)
+}
+
+// Only the machine named after the unit is looked up, rather than all of them.
+func (self *dbusMachineRegistry) GetMachineByUnit(unit string) (*machine, error) {
+	name, ok := machineName(unit)
+	if !ok {
+		return nil, nil
+	}
+	manager := self.conn.Object("org.freedesktop.machine1", "/org/freedesktop/machine1")
+	var machinePath dbus.ObjectPath
+	err := manager.Call("org.freedesktop.machine1.Manager.GetMachine", 0, name).Store(&machinePath)
+	if dbusErr, ok := err.(dbus.Error); ok && dbusErr.Name == "org.freedesktop.machine1.NoSuchMachine" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get machine %q: %v", name, err)
+	}
+
+	var props map[string]dbus.Variant
+	err = self.conn.Object("org.freedesktop.machine1", machinePath).Call("org.freedesktop.DBus.Properties.GetAll", 0, "org.freedesktop.machine1.Machine").Store(&props)
+	if err != nil {
+		// The machine may have terminated meanwhile.
+		glog.V(4).Infof("Failed to get properties of machine %q: %v", name, err)
+		return nil, nil
+	}
+	// A machine of the same name may have been registered with another scope.
+	if machineUnit, _ := props["Unit"].Value().(string); machineUnit != unit {
+		return nil, nil
+	}
+
+	class, _ := props["Class"].Value().(string)
+	service, _ := props["Service"].Value().(string)
+	leader, _ := props["Leader"].Value().(uint32)
+	rootDirectory, _ := props["RootDirectory"].Value().(string)
+	return &machine{
+		Name:          name,
+		Class:         class,
+		Service:       service,
+		Unit:          unit,
+		Leader:        int(leader),
+		RootDirectory: rootDirectory,
+	}, nil
+}
+
+// Returns the name of the machine whose scope is the specified unit. The
+// scopes are named "machine-<name>.scope", with the name escaped as in unit
+// names (e.g.: "machine-my\x2dvm.scope" for "my-vm").
+func machineName(unit string) (string, bool) {
+	if !strings.HasPrefix(unit, "machine-") || !strings.HasSuffix(unit, ".scope") {
+		return "", false
+	}
+	escaped := strings.TrimSuffix(strings.TrimPrefix(unit, "machine-"), ".scope")
+	name := make([]byte, 0, len(escaped))
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] == '\\' && i+3 < len(escaped) && escaped[i+1] == 'x' {
+			if b, err := strconv.ParseUint(escaped[i+2:i+4], 16, 8); err == nil {
+				name = append(name, byte(b))
+				i += 3
+				continue
+			}
+		}
+		name = append(name, escaped[i])
+	}
+	return string(name), len(name) > 0
+}
+
+type machinedFactory struct {
+	// Factory for the raw containers that machines build on.
+	rawFactory container.ContainerHandlerFactory
+
+	// Machines registered with systemd-machined.
+	registry machineRegistry
+
+	// Information about mounted filesystems.
+	fsInfo fs.FsInfo
+
+	// Factory for machine information.
+	machineInfoFactory info.MachineInfoFactory
+}
+
+func (self *machinedFactory) String() string {
+	return MachinedNamespace
+}
+
+func (self *machinedFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	m, err := self.registry.GetMachineByUnit(path.Base(name))
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("no machine is registered for container %q", name)
+	}
+
+	rawHandler, err := self.rawFactory.NewContainerHandler(name)
+	if err != nil {
+		return nil, err
+	}
+	return newMachinedContainerHandler(name, m, rawHandler, self.fsInfo, self.machineInfoFactory), nil
+}
+
+// Returns whether the container may be the scope of a machine.
+func isMachineScope(name string) bool {
+	_, ok := machineName(path.Base(name))
+	return path.Dir(name) == machineSlice && ok
+}
+
+// The scope of a machine may be created before the machine is registered, so
+// a scope with no machine is looked up again a few times before it is left
+// to the other factories.
+var (
+	machineLookupAttempts = 5
+	machineLookupDelay    = 100 * time.Millisecond
+)
+
+// The machined factory handles the scopes of machines registered with
+// systemd-machined. It accepts the same containers as the raw factory.
+func (self *machinedFactory) CanHandleAndAccept(name string) (bool, bool, error) {
+	if !isMachineScope(name) {
+		return false, false, nil
+	}
+	var m *machine
+	var err error
+	for attempt := 1; ; attempt++ {
+		m, err = self.registry.GetMachineByUnit(path.Base(name))
+		if err != nil || m != nil || attempt == machineLookupAttempts {
+			break
+		}
+		time.Sleep(machineLookupDelay)
+	}
+	if err != nil || m == nil {
+		return false, false, err
+	}
+	_, accept, err := self.rawFactory.CanHandleAndAccept(name)
+	return true, accept, err
+}
+
+func (self *machinedFactory) DebugInfo() map[string][]string {
+	return map[string][]string{}
+}
+
+// Register the machined factory. Must be registered before the systemd and raw factories.
+func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("unable to communicate with systemd-machined: %v", err)
+	}
+	registry := &dbusMachineRegistry{conn: conn}
+	if err := registry.ping(); err != nil {
+		return fmt.Errorf("unable to communicate with systemd-machined: %v", err)
+	}
+
+	rawFactory, err := raw.SharedFactory(machineInfoFactory, fsInfo)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Registering machined factory")
+	factory := &machinedFactory{
+		rawFactory:         rawFactory,
+		registry:           registry,
+		fsInfo:             fsInfo,
+		machineInfoFactory: machineInfoFactory,
+	}
+	container.RegisterContainerHandlerFactory(factory)
+	return nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Handler for machines registered with systemd-machined.
+package machined
+
+import (
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/fs"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Labels exposing the registration of the machine.
+const (
+	classLabel   = "machined.class"
+	serviceLabel = "machined.service"
+)
+
+// The raw handler provides the cgroup stats of the machine's scope, network
+// and filesystem stats are added from its leader process and root directory.
+type machinedContainerHandler struct {
+	container.ContainerHandler
+
+	// Name of the container for this handler.
+	name string
+
+	// The machine as registered with systemd-machined.
+	machine *machine
+
+	fsInfo             fs.FsInfo
+	machineInfoFactory info.MachineInfoFactory
+
+	// Reads the network stats of the process' network namespace.
+	getNetworkStats func(pid int) ([]info.InterfaceStats, error)
+
+	// Reads the socket stats of the process' network namespace that are
+	// enabled.
+	getSocketStats func(pid int, stats *info.NetworkStats) error
+
+	// Reads the protocol counters of the process' network namespace.
+	getProtocolStats func(pid int, stats *info.NetworkStats) error
+}
+
+func newMachinedContainerHandler(name string, m *machine, rawHandler container.ContainerHandler, fsInfo fs.FsInfo, machineInfoFactory info.MachineInfoFactory) *machinedContainerHandler {
+	return &machinedContainerHandler{
+		ContainerHandler:   rawHandler,
+		name:               name,
+		machine:            m,
+		fsInfo:             fsInfo,
+		machineInfoFactory: machineInfoFactory,
+		getNetworkStats:    libcontainer.GetProcNetworkStats,
+		getSocketStats:     getSocketStats,
+		getProtocolStats:   libcontainer.GetProcProtocolStats,
+	}
+}
+
+func getSocketStats(pid int, stats *info.NetworkStats) error {
+	return libcontainer.GetProcSocketStats(pid, container.MetricEnabled(container.TcpMetrics), container.MetricEnabled(container.UdpMetrics), stats)
+}
+
+func (self *machinedContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   []string{self.machine.Name},
+		Namespace: MachinedNamespace,
+	}, nil
+}
+
+func (self *machinedContainerHandler) GetContainerLabels() map[string]string {
+	return map[string]string{
+		classLabel:   self.machine.Class,
+		serviceLabel: self.machine.Service,
+	}
+}
+
+func (self *machinedContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	spec, err := self.ContainerHandler.GetSpec()
+	spec.HasNetwork = self.machine.Leader > 0
+	spec.HasFilesystem = self.machine.RootDirectory != ""
+	spec.Labels = self.GetContainerLabels()
+	return spec, err
+}
+
+func (self *machinedContainerHandler) getFsStats(stats *info.ContainerStats) error {
+	deviceInfo, err := self.fsInfo.GetDirFsDevice(self.machine.RootDirectory)
+	if err != nil {
+		return err
+	}
+
+	mi, err := self.machineInfoFactory.GetMachineInfo()
+	if err != nil {
+		return err
+	}
+	var limit uint64 = 0
+	// Machines share the filesystem of their root directory, so use its capacity as limit.
+	for _, fs := range mi.Filesystems {
+		if fs.Device == deviceInfo.Device {
+			limit = fs.Capacity
+			break
+		}
+	}
+
+	usage, err := self.fsInfo.GetDirUsage(self.machine.RootDirectory)
+	if err != nil {
+		return err
+	}
+	stats.Filesystem = append(stats.Filesystem, info.FsStats{
+		Device: deviceInfo.Device,
+		Limit:  limit,
+		Usage:  usage,
+	})
+	return nil
+}
+
+func (self *machinedContainerHandler) GetStats() (*info.ContainerStats, error) {
+	stats, err := self.ContainerHandler.GetStats()
+	if err != nil {
+		return stats, err
+	}
+
+	// Get network stats from the network namespace of the leader.
+	if self.machine.Leader > 0 {
+		interfaces, err := self.getNetworkStats(self.machine.Leader)
+		if err != nil {
+			return stats, err
+		}
+		stats.Network.Interfaces = interfaces
+		// For backwards compatibility.
+		if len(interfaces) > 0 {
+			stats.Network.InterfaceStats = interfaces[0]
+		}
+		err = self.getSocketStats(self.machine.Leader, &stats.Network)
+		if err != nil {
+			return stats, err
+		}
+		err = self.getProtocolStats(self.machine.Leader, &stats.Network)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	// Get filesystem stats.
+	if self.machine.RootDirectory != "" {
+		err = self.getFsStats(stats)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
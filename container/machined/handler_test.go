@@ -0,0 +1,236 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package machined
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/fs"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testMachineName = "/machine.slice/machine-debian.scope"
+	testRootDir     = "/var/lib/machines/debian"
+)
+
+// Machines keyed by unit.
+type fakeRegistry map[string]*machine
+
+func (self fakeRegistry) GetMachineByUnit(unit string) (*machine, error) {
+	return self[unit], nil
+}
+
+// Raw factory that accepts all containers and creates mock handlers.
+type fakeRawFactory struct{}
+
+func (self *fakeRawFactory) String() string {
+	return "raw"
+}
+
+func (self *fakeRawFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	handler := container.NewMockContainerHandler(name)
+	handler.On("GetSpec").Return(info.ContainerSpec{HasCpu: true}, nil)
+	handler.On("GetStats").Return(&info.ContainerStats{}, nil)
+	return handler, nil
+}
+
+func (self *fakeRawFactory) CanHandleAndAccept(name string) (bool, bool, error) {
+	return true, true, nil
+}
+
+func (self *fakeRawFactory) DebugInfo() map[string][]string {
+	return map[string][]string{}
+}
+
+// Filesystem information for the root directory of the test machine.
+type fakeFsInfo struct {
+	fs.FsInfo
+}
+
+func (self *fakeFsInfo) GetDirFsDevice(dir string) (*fs.DeviceInfo, error) {
+	return &fs.DeviceInfo{Device: "/dev/sda1"}, nil
+}
+
+func (self *fakeFsInfo) GetDirUsage(dir string) (uint64, error) {
+	return 1024, nil
+}
+
+type fakeMachineInfoFactory struct{}
+
+func (self *fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &info.MachineInfo{
+		Filesystems: []info.FsInfo{
+			{Device: "/dev/sda1", Capacity: 4096},
+		},
+	}, nil
+}
+
+func (self *fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+func newTestFactory() *machinedFactory {
+	return &machinedFactory{
+		rawFactory: &fakeRawFactory{},
+		registry: fakeRegistry{
+			"machine-debian.scope": {
+				Name:          "debian",
+				Class:         "container",
+				Service:       "nspawn",
+				Unit:          "machine-debian.scope",
+				Leader:        1234,
+				RootDirectory: testRootDir,
+			},
+		},
+		fsInfo:             &fakeFsInfo{},
+		machineInfoFactory: &fakeMachineInfoFactory{},
+	}
+}
+
+func TestCanHandleAndAccept(t *testing.T) {
+	factory := newTestFactory()
+	for name, expected := range map[string]bool{
+		testMachineName:                         true,
+		"/machine.slice/machine-unknown.scope":  false,
+		"/machine.slice":                        false,
+		"/system.slice/machine-debian.scope":    false,
+		"/machine.slice/machine-debian.scope/a": false,
+	} {
+		handle, accept, err := factory.CanHandleAndAccept(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, handle, "handle %q", name)
+		assert.Equal(t, expected, accept, "accept %q", name)
+	}
+}
+
+func TestMachineReferenceAndSpec(t *testing.T) {
+	handler, err := newTestFactory().NewContainerHandler(testMachineName)
+	require.NoError(t, err)
+
+	ref, err := handler.ContainerReference()
+	require.NoError(t, err)
+	assert.Equal(t, info.ContainerReference{
+		Name:      testMachineName,
+		Aliases:   []string{"debian"},
+		Namespace: MachinedNamespace,
+	}, ref)
+
+	spec, err := handler.GetSpec()
+	require.NoError(t, err)
+	assert.True(t, spec.HasCpu)
+	assert.True(t, spec.HasNetwork)
+	assert.True(t, spec.HasFilesystem)
+	assert.Equal(t, map[string]string{
+		classLabel:   "container",
+		serviceLabel: "nspawn",
+	}, spec.Labels)
+}
+
+func TestMachineStats(t *testing.T) {
+	handler, err := newTestFactory().NewContainerHandler(testMachineName)
+	require.NoError(t, err)
+	eth0 := info.InterfaceStats{Name: "eth0", RxBytes: 10, TxBytes: 20}
+	handler.(*machinedContainerHandler).getNetworkStats = func(pid int) ([]info.InterfaceStats, error) {
+		assert.Equal(t, 1234, pid)
+		return []info.InterfaceStats{eth0}, nil
+	}
+	handler.(*machinedContainerHandler).getSocketStats = func(pid int, stats *info.NetworkStats) error {
+		assert.Equal(t, 1234, pid)
+		stats.Tcp.Established = 3
+		return nil
+	}
+	handler.(*machinedContainerHandler).getProtocolStats = func(pid int, stats *info.NetworkStats) error {
+		assert.Equal(t, 1234, pid)
+		stats.Protocol.TcpRetransSegs = 5
+		return nil
+	}
+
+	stats, err := handler.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, eth0, stats.Network.InterfaceStats)
+	assert.Equal(t, []info.InterfaceStats{eth0}, stats.Network.Interfaces)
+	assert.Equal(t, uint64(3), stats.Network.Tcp.Established)
+	assert.Equal(t, uint64(5), stats.Network.Protocol.TcpRetransSegs)
+	assert.Equal(t, []info.FsStats{
+		{Device: "/dev/sda1", Limit: 4096, Usage: 1024},
+	}, stats.Filesystem)
+}
+
+func TestUnregisteredMachine(t *testing.T) {
+	_, err := newTestFactory().NewContainerHandler("/machine.slice/machine-unknown.scope")
+	assert.Error(t, err)
+}
+
+// Registry that only knows the machine after it was looked up a number of times.
+type lateRegistry struct {
+	fakeRegistry
+	lookups      int
+	registeredAt int
+}
+
+func (self *lateRegistry) GetMachineByUnit(unit string) (*machine, error) {
+	self.lookups++
+	if self.lookups < self.registeredAt {
+		return nil, nil
+	}
+	return self.fakeRegistry.GetMachineByUnit(unit)
+}
+
+func TestCanHandleMachineRegisteredAfterItsScope(t *testing.T) {
+	oldDelay := machineLookupDelay
+	machineLookupDelay = time.Millisecond
+	defer func() {
+		machineLookupDelay = oldDelay
+	}()
+
+	factory := newTestFactory()
+	registry := &lateRegistry{
+		fakeRegistry: factory.registry.(fakeRegistry),
+		registeredAt: 3,
+	}
+	factory.registry = registry
+	handle, accept, err := factory.CanHandleAndAccept(testMachineName)
+	require.NoError(t, err)
+	assert.True(t, handle)
+	assert.True(t, accept)
+	assert.Equal(t, 3, registry.lookups)
+
+	// Scopes of machines that are never registered are left to other factories.
+	registry.lookups = 0
+	handle, _, err = factory.CanHandleAndAccept("/machine.slice/machine-unknown.scope")
+	require.NoError(t, err)
+	assert.False(t, handle)
+	assert.Equal(t, machineLookupAttempts, registry.lookups)
+}
+
+func TestMachineName(t *testing.T) {
+	for unit, expected := range map[string]string{
+		"machine-debian.scope":   "debian",
+		`machine-my\x2dvm.scope`: "my-vm",
+		`machine-bad\x2.scope`:   `bad\x2`,
+		"machine-.scope":         "",
+		"debian.scope":           "",
+		"machine-debian.service": "",
+	} {
+		name, ok := machineName(unit)
+		assert.Equal(t, expected, name, "unit %q", unit)
+		assert.Equal(t, expected != "", ok, "unit %q", unit)
+	}
+}
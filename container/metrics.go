@@ -0,0 +1,49 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"flag"
+	"strings"
+)
+
+// Kind of metrics that can be disabled because they are costly to collect.
+type MetricKind string
+
+const (
+	// Socket counts per TCP state, from /proc/<pid>/net/tcp{,6}.
+	TcpMetrics MetricKind = "tcp"
+	// UDP socket counts and queues, from /proc/<pid>/net/udp{,6}.
+	UdpMetrics MetricKind = "udp"
+	// Open file descriptor counts, from /proc/<pid>/fd of every process.
+	FdMetrics MetricKind = "fd"
+	// Thread counts, from the thread list of every container.
+	ProcessMetrics MetricKind = "process"
+	// Scheduler stats, from /proc/<tid>/schedstat of every thread.
+	SchedstatMetrics MetricKind = "schedstat"
+)
+
+// Reading these is linear in the number of sockets, processes or threads.
+var disableMetrics = flag.String("disable_metrics", "tcp,udp", "comma-separated list of metrics not to collect, among 'tcp', 'udp', 'fd', 'process' and 'schedstat'")
+
+// Returns whether the metrics of the specified kind are collected.
+func MetricEnabled(kind MetricKind) bool {
+	for _, disabled := range strings.Split(*disableMetrics, ",") {
+		if MetricKind(strings.TrimSpace(disabled)) == kind {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricEnabled(t *testing.T) {
+	oldDisableMetrics := *disableMetrics
+	defer func() {
+		*disableMetrics = oldDisableMetrics
+	}()
+
+	// Socket metrics are disabled by default.
+	assert.False(t, MetricEnabled(TcpMetrics))
+	assert.False(t, MetricEnabled(UdpMetrics))
+	assert.True(t, MetricEnabled(FdMetrics))
+
+	*disableMetrics = "udp, fd"
+	assert.False(t, MetricEnabled(FdMetrics))
+	assert.True(t, MetricEnabled(ProcessMetrics))
+
+	*disableMetrics = "udp"
+	assert.True(t, MetricEnabled(TcpMetrics))
+	assert.False(t, MetricEnabled(UdpMetrics))
+
+	*disableMetrics = ""
+	assert.True(t, MetricEnabled(TcpMetrics))
+	assert.True(t, MetricEnabled(UdpMetrics))
+}
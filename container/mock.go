@@ -90,6 +90,10 @@ func (self *MockContainerHandler) Exists() bool {
 	return args.Get(0).(bool)
 }
 
+func (self *MockContainerHandler) Cleanup() {
+	self.Called()
+}
+
 func (self *MockContainerHandler) GetCgroupPath(path string) (string, error) {
 	args := self.Called(path)
 	return args.Get(0).(string), args.Error(1)
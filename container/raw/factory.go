@@ -17,6 +17,7 @@ package raw
 import (
 	"flag"
 	"fmt"
+	"sync"
 
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/container"
@@ -73,26 +74,56 @@ func (self *rawFactory) DebugInfo() map[string][]string {
 }
 
 func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) error {
+	factory, err := SharedFactory(machineInfoFactory, fsInfo)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Registering Raw factory")
+	container.RegisterContainerHandlerFactory(factory)
+	return nil
+}
+
+var (
+	sharedFactory     *rawFactory
+	sharedFactoryLock sync.Mutex
+)
+
+// Returns the raw factory, creating it on first use, without registering it.
+// Factories that build on raw containers share it so that there is a single
+// inotify watcher.
+func SharedFactory(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) (container.ContainerHandlerFactory, error) {
+	sharedFactoryLock.Lock()
+	defer sharedFactoryLock.Unlock()
+	if sharedFactory != nil {
+		return sharedFactory, nil
+	}
+	factory, err := newFactory(machineInfoFactory, fsInfo)
+	if err != nil {
+		return nil, err
+	}
+	sharedFactory = factory
+	return factory, nil
+}
+
+func newFactory(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) (*rawFactory, error) {
 	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
 	if err != nil {
-		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+		return nil, fmt.Errorf("failed to get cgroup subsystems: %v", err)
 	}
 	if len(cgroupSubsystems.Mounts) == 0 {
-		return fmt.Errorf("failed to find supported cgroup mounts for the raw factory")
+		return nil, fmt.Errorf("failed to find supported cgroup mounts for the raw factory")
 	}
 
 	watcher, err := NewInotifyWatcher()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	glog.Infof("Registering Raw factory")
-	factory := &rawFactory{
+	return &rawFactory{
 		machineInfoFactory: machineInfoFactory,
 		fsInfo:             fsInfo,
 		cgroupSubsystems:   &cgroupSubsystems,
 		watcher:            watcher,
-	}
-	container.RegisterContainerHandlerFactory(factory)
-	return nil
+	}, nil
 }
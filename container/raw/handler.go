@@ -22,6 +22,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/libcontainer/cgroups"
@@ -59,6 +60,15 @@ type rawContainerHandler struct {
 	// Whether this container has network isolation enabled.
 	hasNetwork bool
 
+	// Network namespace of the host, containers in it don't have network stats of their own.
+	hostNetworkNamespace string
+
+	// Process of the container in its own network namespace, as of the last
+	// housekeeping, so that cgroup.procs is not read again by GetSpec.
+	networkPidLock    sync.Mutex
+	networkPid        int
+	networkPidChecked bool
+
 	fsInfo         fs.FsInfo
 	externalMounts []mount
 }
@@ -97,17 +107,25 @@ func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSu
 		}
 	}
 
+	// cAdvisor may run in a network namespace of its own, so the host's is
+	// the one of init.
+	hostNetworkNamespace, err := libcontainer.GetNetworkNamespace(hostPid)
+	if err != nil {
+		glog.V(4).Infof("Failed to get the host network namespace: %v", err)
+	}
+
 	return &rawContainerHandler{
-		name:               name,
-		cgroupSubsystems:   cgroupSubsystems,
-		machineInfoFactory: machineInfoFactory,
-		stopWatcher:        make(chan error),
-		cgroupPaths:        cgroupPaths,
-		cgroupManager:      cgroupManager,
-		fsInfo:             fsInfo,
-		hasNetwork:         hasNetwork,
-		externalMounts:     externalMounts,
-		watcher:            watcher,
+		name:                 name,
+		cgroupSubsystems:     cgroupSubsystems,
+		machineInfoFactory:   machineInfoFactory,
+		stopWatcher:          make(chan error),
+		cgroupPaths:          cgroupPaths,
+		cgroupManager:        cgroupManager,
+		fsInfo:               fsInfo,
+		hasNetwork:           hasNetwork,
+		hostNetworkNamespace: hostNetworkNamespace,
+		externalMounts:       externalMounts,
+		watcher:              watcher,
 	}, nil
 }
 
@@ -174,8 +192,12 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 		// The modified time of the cgroup directory changes whenever a subcontainer is created.
 		// eg. /docker will have creation time matching the creation of latest docker container.
 		// Use clone_children as a workaround as it isn't usually modified. It is only likely changed
-		// immediately after creating a container.
-		cgroupPath = path.Join(cgroupPath, "cgroup.clone_children")
+		// immediately after creating a container. The cgroup v2 equivalent is never modified.
+		if self.cgroupSubsystems.Unified {
+			cgroupPath = path.Join(cgroupPath, "cgroup.controllers")
+		} else {
+			cgroupPath = path.Join(cgroupPath, "cgroup.clone_children")
+		}
 		fi, err := os.Stat(cgroupPath)
 		if err == nil && fi.ModTime().Before(lowestTime) {
 			lowestTime = fi.ModTime()
@@ -191,6 +213,10 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 		return spec, err
 	}
 
+	if self.cgroupSubsystems.Unified {
+		self.getUnifiedSpec(&spec, mi)
+	}
+
 	// CPU.
 	cpuRoot, ok := self.cgroupPaths["cpu"]
 	if ok {
@@ -241,19 +267,43 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 		}
 	}
 
+	// Huge pages.
+	if hugetlbRoot, err := self.GetCgroupPath("hugetlb"); err == nil {
+		limits, err := libcontainer.GetHugetlbLimits(hugetlbRoot, self.cgroupSubsystems.Unified)
+		if err != nil {
+			glog.V(4).Infof("Failed to get huge page limits of %q: %v", self.name, err)
+		}
+		spec.Memory.HugetlbLimits = limits
+	}
+
 	// Fs.
 	if self.name == "/" || self.externalMounts != nil {
 		spec.HasFilesystem = true
 	}
 
 	//Network
-	spec.HasNetwork = self.hasNetwork
+	spec.HasNetwork = self.hasNetwork || self.cachedNetworkNamespacePid() != 0
 
 	// DiskIo.
 	if blkioRoot, ok := self.cgroupPaths["blkio"]; ok && utils.FileExists(blkioRoot) {
 		spec.HasDiskIo = true
 	}
 
+	// Processes.
+	spec.HasProcesses = true
+	if pidsRoot, err := self.GetCgroupPath("pids"); err == nil {
+		if limit, err := libcontainer.ReadCgroupLimit(pidsRoot, "pids.max"); err == nil {
+			spec.Processes.Limit = limit
+		}
+	}
+
+	// Pressure, which is the one of the whole host for the root container.
+	if self.name == "/" {
+		spec.HasPressure = libcontainer.HasHostPressure()
+	} else if self.cgroupSubsystems.Unified {
+		spec.HasPressure = libcontainer.HasUnifiedPressure(self.cgroupPaths[libcontainer.UnifiedHierarchy])
+	}
+
 	// Check physical network devices for root container.
 	nd, err := self.GetRootNetworkDevices()
 	if err != nil {
@@ -265,6 +315,46 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 	return spec, nil
 }
 
+// Converts a cgroup v2 CPU weight (1-10000) to cgroup v1 CPU shares (2-262144).
+func cpuWeightToShares(weight uint64) uint64 {
+	if weight == 0 {
+		return 0
+	}
+	return 2 + ((weight-1)*262142)/9999
+}
+
+// Gets the resources of a container in the unified hierarchy. Its files are
+// only present if the controllers are enabled for the cgroup.
+func (self *rawContainerHandler) getUnifiedSpec(spec *info.ContainerSpec, mi *info.MachineInfo) {
+	cgroupPath := self.cgroupPaths[libcontainer.UnifiedHierarchy]
+
+	// CPU.
+	if utils.FileExists(path.Join(cgroupPath, "cpu.stat")) {
+		spec.HasCpu = true
+		spec.Cpu.Limit = cpuWeightToShares(readInt64(cgroupPath, "cpu.weight"))
+	}
+	if mask := readString(cgroupPath, "cpuset.cpus.effective"); mask != "" {
+		spec.HasCpu = true
+		spec.Cpu.Mask = utils.FixCpuMask(mask, mi.NumCores)
+	}
+
+	// Memory. Limits of the root container are set from the machine.
+	if self.name != "/" && utils.FileExists(path.Join(cgroupPath, "memory.current")) {
+		spec.HasMemory = true
+		if limit, err := libcontainer.ReadCgroupLimit(cgroupPath, "memory.max"); err == nil {
+			spec.Memory.Limit = limit
+		}
+		if limit, err := libcontainer.ReadCgroupLimit(cgroupPath, "memory.swap.max"); err == nil {
+			spec.Memory.SwapLimit = limit
+		}
+	}
+
+	// DiskIo.
+	if utils.FileExists(path.Join(cgroupPath, "io.stat")) {
+		spec.HasDiskIo = true
+	}
+}
+
 func (self *rawContainerHandler) getFsStats(stats *info.ContainerStats) error {
 	// Get Filesystem information only for the root cgroup.
 	if self.name == "/" {
@@ -325,6 +415,91 @@ func (self *rawContainerHandler) getFsStats(stats *info.ContainerStats) error {
 	return nil
 }
 
+// Process in the network namespace of the host.
+const hostPid = 1
+
+// Returns the process of the container in its own network namespace found by
+// the last housekeeping, or looks it up if there was none yet.
+func (self *rawContainerHandler) cachedNetworkNamespacePid() int {
+	self.networkPidLock.Lock()
+	pid, checked := self.networkPid, self.networkPidChecked
+	self.networkPidLock.Unlock()
+	if checked {
+		return pid
+	}
+	return self.updateNetworkNamespacePid()
+}
+
+// Looks up the process of the container in its own network namespace and
+// caches it.
+func (self *rawContainerHandler) updateNetworkNamespacePid() int {
+	pid := self.networkNamespacePid()
+	self.networkPidLock.Lock()
+	defer self.networkPidLock.Unlock()
+	self.networkPid = pid
+	self.networkPidChecked = true
+	return pid
+}
+
+// Returns a process of the container in a network namespace other than the
+// host's, or 0 if the container has no network namespace of its own.
+func (self *rawContainerHandler) networkNamespacePid() int {
+	if self.name == "/" || self.hostNetworkNamespace == "" {
+		return 0
+	}
+
+	// All the processes of a container are expected to share its network namespace.
+	pids, err := self.ListProcesses(container.ListSelf)
+	if err != nil || len(pids) == 0 {
+		return 0
+	}
+	netns, err := libcontainer.GetNetworkNamespace(pids[0])
+	if err != nil || netns == self.hostNetworkNamespace {
+		return 0
+	}
+	return pids[0]
+}
+
+// Reads the socket stats of the process' network namespace that are enabled.
+func getSocketStats(pid int, stats *info.NetworkStats) error {
+	return libcontainer.GetProcSocketStats(pid, container.MetricEnabled(container.TcpMetrics), container.MetricEnabled(container.UdpMetrics), stats)
+}
+
+func (self *rawContainerHandler) getNetworkStats(stats *info.ContainerStats) error {
+	pid := self.updateNetworkNamespacePid()
+	if pid == 0 {
+		// The sockets of the host's network namespace are reported by the root container.
+		if self.name == "/" {
+			err := getSocketStats(hostPid, &stats.Network)
+			if err != nil {
+				return err
+			}
+			return libcontainer.GetProcProtocolStats(hostPid, &stats.Network)
+		}
+		return nil
+	}
+	interfaces, err := libcontainer.GetProcNetworkStats(pid)
+	if err == nil {
+		err = getSocketStats(pid, &stats.Network)
+	}
+	if err == nil {
+		err = libcontainer.GetProcProtocolStats(pid, &stats.Network)
+	}
+	if err != nil {
+		// The process may have exited meanwhile.
+		if !utils.FileExists(path.Join("/proc", strconv.Itoa(pid))) {
+			return nil
+		}
+		return err
+	}
+	stats.Network.Interfaces = interfaces
+	// For backwards compatibility.
+	if len(interfaces) > 0 {
+		stats.Network.InterfaceStats = interfaces[0]
+	}
+	return nil
+}
+
 func (self *rawContainerHandler) GetStats() (*info.ContainerStats, error) {
 	nd, err := self.GetRootNetworkDevices()
 	if err != nil {
@@ -334,7 +509,21 @@ func (self *rawContainerHandler) GetStats() (*info.ContainerStats, error) {
 	for i := range nd {
 		networkInterfaces[i] = nd[i].Name
 	}
-	stats, err := libcontainer.GetStats(self.cgroupManager, networkInterfaces)
+	stats, err := libcontainer.GetCgroupStats(self.cgroupManager, self.cgroupSubsystems.Unified, networkInterfaces)
+	if err != nil {
+		return stats, err
+	}
+
+	// The pressure of the root container is the one of the whole host.
+	if self.name == "/" {
+		err = libcontainer.GetHostPressureStats(stats)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	// Get network stats of containers in their own network namespace.
+	err = self.getNetworkStats(stats)
 	if err != nil {
 		return stats, err
 	}
@@ -349,6 +538,10 @@ func (self *rawContainerHandler) GetStats() (*info.ContainerStats, error) {
 }
 
 func (self *rawContainerHandler) GetCgroupPath(resource string) (string, error) {
+	// All resources share the unified hierarchy.
+	if self.cgroupSubsystems.Unified {
+		resource = libcontainer.UnifiedHierarchy
+	}
 	path, ok := self.cgroupPaths[resource]
 	if !ok {
 		return "", fmt.Errorf("could not find path for resource %q for container %q\n", resource, self.name)
@@ -409,13 +602,26 @@ func (self *rawContainerHandler) ListContainers(listType container.ListType) ([]
 	return ret, nil
 }
 
+// The cgroup v1 hierarchies the threads of a container are read from, by
+// preference.
+var threadSubsystems = []string{"cpu", "cpuacct", "memory", "pids"}
+
 func (self *rawContainerHandler) ListThreads(listType container.ListType) ([]int, error) {
-	// TODO(vmarmol): Implement
+	if self.cgroupSubsystems.Unified {
+		return libcontainer.GetUnifiedThreads(self.cgroupPaths[libcontainer.UnifiedHierarchy])
+	}
+	// The threads are read from the first of these hierarchies the container
+	// is in, so that they are the same from one call to the next.
+	for _, subsystem := range threadSubsystems {
+		if cgroupPath, ok := self.cgroupPaths[subsystem]; ok && utils.FileExists(cgroupPath) {
+			return libcontainer.GetThreads(cgroupPath)
+		}
+	}
 	return nil, nil
 }
 
 func (self *rawContainerHandler) ListProcesses(listType container.ListType) ([]int, error) {
-	return libcontainer.GetProcesses(self.cgroupManager)
+	return libcontainer.GetCgroupProcesses(self.cgroupManager, self.cgroupSubsystems.Unified)
 }
 
 // Watches the specified directory and all subdirectories. Returns whether the path was
@@ -557,6 +763,10 @@ func (self *rawContainerHandler) WatchSubcontainers(events chan container.Subcon
 	return nil
 }
 
+func (self *rawContainerHandler) Cleanup() {
+	// Nothing to free, the watches are removed with the cgroup.
+}
+
 func (self *rawContainerHandler) StopWatchingSubcontainers() error {
 	// Rendezvous with the watcher thread.
 	self.stopWatcher <- nil
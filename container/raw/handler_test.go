@@ -0,0 +1,167 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raw
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/docker/libcontainer/cgroups"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMachineInfoFactory struct{}
+
+func (self *fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &info.MachineInfo{NumCores: 4}, nil
+}
+
+func (self *fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+// Creates a fake unified hierarchy with the specified files, relative to its root.
+func newFakeUnifiedHierarchy(t *testing.T, files map[string]string) (string, *libcontainer.CgroupSubsystems) {
+	root, err := ioutil.TempDir("", "cgroupfs")
+	require.NoError(t, err)
+	for name, content := range files {
+		file := path.Join(root, name)
+		require.NoError(t, os.MkdirAll(path.Dir(file), 0755))
+		require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	return root, &libcontainer.CgroupSubsystems{
+		Mounts: []cgroups.Mount{
+			{Mountpoint: root, Subsystems: []string{"cpu", "memory"}},
+		},
+		MountPoints: map[string]string{
+			libcontainer.UnifiedHierarchy: root,
+		},
+		Unified: true,
+	}
+}
+
+func TestUnifiedSpec(t *testing.T) {
+	root, subsystems := newFakeUnifiedHierarchy(t, map[string]string{
+		"test/cgroup.controllers":       "cpu memory\n",
+		"test/cgroup.procs":             "",
+		"test/cgroup.threads":           "10\n11\n",
+		"test/cpu.stat":                 "usage_usec 0\n",
+		"test/cpu.weight":               "100\n",
+		"test/cpu.pressure":             "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		"test/cpuset.cpus.effective":    "0-1\n",
+		"test/memory.current":           "0\n",
+		"test/memory.max":               "1073741824\n",
+		"test/memory.swap.max":          "max\n",
+		"test/pids.max":                 "100\n",
+		"test/child/cgroup.controllers": "",
+	})
+	defer os.RemoveAll(root)
+
+	handler, err := newRawContainerHandler("/test", subsystems, &fakeMachineInfoFactory{}, nil, nil)
+	require.NoError(t, err)
+
+	spec, err := handler.GetSpec()
+	require.NoError(t, err)
+	assert.False(t, spec.CreationTime.IsZero())
+	assert.True(t, spec.HasCpu)
+	assert.Equal(t, uint64(2597), spec.Cpu.Limit)
+	assert.Equal(t, "0-1", spec.Cpu.Mask)
+	assert.True(t, spec.HasMemory)
+	assert.Equal(t, uint64(1073741824), spec.Memory.Limit)
+	assert.Equal(t, ^uint64(0), spec.Memory.SwapLimit)
+	assert.False(t, spec.HasDiskIo)
+	assert.True(t, spec.HasProcesses)
+	assert.Equal(t, uint64(100), spec.Processes.Limit)
+	assert.True(t, spec.HasPressure)
+
+	threads, err := handler.ListThreads(container.ListSelf)
+	require.NoError(t, err)
+	assert.Equal(t, []int{10, 11}, threads)
+
+	containers, err := handler.ListContainers(container.ListSelf)
+	require.NoError(t, err)
+	assert.Equal(t, []info.ContainerReference{{Name: "/test/child"}}, containers)
+
+	// All resources are in the unified hierarchy.
+	cgroupPath, err := handler.GetCgroupPath("cpu")
+	require.NoError(t, err)
+	assert.Equal(t, path.Join(root, "test"), cgroupPath)
+}
+
+func TestNetworkNamespacePidIsCachedUntilHousekeeping(t *testing.T) {
+	root, subsystems := newFakeUnifiedHierarchy(t, map[string]string{
+		"test/cgroup.procs": strconv.Itoa(os.Getpid()) + "\n",
+	})
+	defer os.RemoveAll(root)
+
+	handler, err := newRawContainerHandler("/test", subsystems, &fakeMachineInfoFactory{}, nil, nil)
+	require.NoError(t, err)
+	rawHandler := handler.(*rawContainerHandler)
+	// This process is taken to be in a network namespace other than the host's.
+	rawHandler.hostNetworkNamespace = "net:[0]"
+
+	assert.Equal(t, os.Getpid(), rawHandler.cachedNetworkNamespacePid())
+	require.NoError(t, ioutil.WriteFile(path.Join(root, "test/cgroup.procs"), []byte(""), 0644))
+	assert.Equal(t, os.Getpid(), rawHandler.cachedNetworkNamespacePid())
+
+	// Housekeeping looks the process up again.
+	assert.Equal(t, 0, rawHandler.updateNetworkNamespacePid())
+	assert.Equal(t, 0, rawHandler.cachedNetworkNamespacePid())
+}
+
+func TestCpuWeightToShares(t *testing.T) {
+	for weight, shares := range map[uint64]uint64{
+		0:     0,
+		1:     2,
+		100:   2597,
+		10000: 262144,
+	} {
+		assert.Equal(t, shares, cpuWeightToShares(weight), "weight %d", weight)
+	}
+}
+
+func TestListThreadsReadsTheCpuHierarchy(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroupfs")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	cgroupPaths := make(map[string]string)
+	// The hierarchies disagree while a thread is moved between cgroups.
+	for subsystem, tasks := range map[string]string{
+		"blkio":  "10\n",
+		"cpu":    "10\n11\n",
+		"memory": "11\n",
+	} {
+		cgroupPaths[subsystem] = path.Join(root, subsystem, "test")
+		require.NoError(t, os.MkdirAll(cgroupPaths[subsystem], 0755))
+		require.NoError(t, ioutil.WriteFile(path.Join(cgroupPaths[subsystem], "tasks"), []byte(tasks), 0644))
+	}
+	handler := &rawContainerHandler{
+		cgroupSubsystems: &libcontainer.CgroupSubsystems{},
+		cgroupPaths:      cgroupPaths,
+	}
+
+	for i := 0; i < 5; i++ {
+		threads, err := handler.ListThreads(container.ListSelf)
+		require.NoError(t, err)
+		assert.Equal(t, []int{10, 11}, threads)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/coreos/go-systemd/dbus"
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/fs"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// The namespace under which systemd unit names are unique.
+const SystemdNamespace = "systemd"
+
+// Suffixes of the units whose cgroups are handled by this factory.
+var unitSuffixes = []string{".service", ".scope", ".slice"}
+
+// Source of systemd unit properties, implemented by the systemd D-Bus connection.
+type unitPropertiesGetter interface {
+	GetUnitProperties(unit string) (map[string]interface{}, error)
+}
+
+type systemdFactory struct {
+	// Factory for the raw containers that systemd units build on.
+	rawFactory container.ContainerHandlerFactory
+
+	// Connection to systemd over D-Bus.
+	conn unitPropertiesGetter
+}
+
+func (self *systemdFactory) String() string {
+	return SystemdNamespace
+}
+
+func (self *systemdFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	rawHandler, err := self.rawFactory.NewContainerHandler(name)
+	if err != nil {
+		return nil, err
+	}
+	return newSystemdContainerHandler(name, rawHandler, self.conn), nil
+}
+
+// Returns the systemd unit name of the container, if it is a systemd unit.
+func unitName(name string) (string, bool) {
+	unit := path.Base(name)
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(unit, suffix) && unit != suffix {
+			return unit, true
+		}
+	}
+	return "", false
+}
+
+// The systemd factory can handle any container that is a systemd unit. It
+// accepts the same containers as the raw factory.
+func (self *systemdFactory) CanHandleAndAccept(name string) (bool, bool, error) {
+	if _, ok := unitName(name); !ok {
+		return false, false, nil
+	}
+	_, accept, err := self.rawFactory.CanHandleAndAccept(name)
+	return true, accept, err
+}
+
+func (self *systemdFactory) DebugInfo() map[string][]string {
+	return map[string][]string{}
+}
+
+// Register the systemd factory. Must be registered before the raw factory.
+func Register(machineInfoFactory info.MachineInfoFactory, fsInfo fs.FsInfo) error {
+	conn, err := dbus.New()
+	if err != nil {
+		return fmt.Errorf("unable to communicate with systemd: %v", err)
+	}
+
+	rawFactory, err := raw.SharedFactory(machineInfoFactory, fsInfo)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Registering systemd factory")
+	factory := &systemdFactory{
+		rawFactory: rawFactory,
+		conn:       conn,
+	}
+	container.RegisterContainerHandlerFactory(factory)
+	return nil
+}
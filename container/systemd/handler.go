@@ -0,0 +1,97 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Handler for systemd units (services, scopes and slices).
+package systemd
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Labels exposing the properties of the unit.
+const (
+	unitLabel        = "systemd.unit"
+	descriptionLabel = "systemd.description"
+)
+
+// A systemd unit is a raw container with a name, so the raw handler does all
+// the work except for naming and labels.
+type systemdContainerHandler struct {
+	container.ContainerHandler
+
+	// Name of the container for this handler.
+	name string
+
+	// Name of the systemd unit (e.g.: "foo.service").
+	unit string
+
+	// Connection to systemd over D-Bus.
+	conn unitPropertiesGetter
+
+	// Labels read from systemd, nil until they were read successfully.
+	labels     map[string]string
+	labelsLock sync.Mutex
+}
+
+func newSystemdContainerHandler(name string, rawHandler container.ContainerHandler, conn unitPropertiesGetter) *systemdContainerHandler {
+	unit, _ := unitName(name)
+	return &systemdContainerHandler{
+		ContainerHandler: rawHandler,
+		name:             name,
+		unit:             unit,
+		conn:             conn,
+	}
+}
+
+func (self *systemdContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   []string{self.unit},
+		Namespace: SystemdNamespace,
+	}, nil
+}
+
+func (self *systemdContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	spec, err := self.ContainerHandler.GetSpec()
+	spec.Labels = self.GetContainerLabels()
+	return spec, err
+}
+
+// Labels are only read from systemd once per unit, since they do not change
+// during its lifetime. They are read again if systemd could not be reached.
+func (self *systemdContainerHandler) GetContainerLabels() map[string]string {
+	self.labelsLock.Lock()
+	defer self.labelsLock.Unlock()
+	if self.labels != nil {
+		return self.labels
+	}
+
+	labels := map[string]string{
+		unitLabel: self.unit,
+	}
+	props, err := self.conn.GetUnitProperties(self.unit)
+	if err != nil {
+		glog.V(4).Infof("Failed to get properties of unit %q: %v", self.unit, err)
+		return labels
+	}
+	if description, ok := props["Description"].(string); ok && description != "" {
+		labels[descriptionLabel] = description
+	}
+	self.labels = labels
+	return labels
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/cadvisor/container"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Properties of units keyed by unit name.
+type fakeSystemd map[string]map[string]interface{}
+
+func (self fakeSystemd) GetUnitProperties(unit string) (map[string]interface{}, error) {
+	props, ok := self[unit]
+	if !ok {
+		return nil, fmt.Errorf("unknown unit %q", unit)
+	}
+	return props, nil
+}
+
+// Raw factory that accepts all containers and creates mock handlers.
+type fakeRawFactory struct {
+	accept bool
+}
+
+func (self *fakeRawFactory) String() string {
+	return "raw"
+}
+
+func (self *fakeRawFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	return container.NewMockContainerHandler(name), nil
+}
+
+func (self *fakeRawFactory) CanHandleAndAccept(name string) (bool, bool, error) {
+	return true, self.accept, nil
+}
+
+func (self *fakeRawFactory) DebugInfo() map[string][]string {
+	return map[string][]string{}
+}
+
+func TestCanHandleAndAccept(t *testing.T) {
+	factory := &systemdFactory{
+		rawFactory: &fakeRawFactory{accept: true},
+		conn:       fakeSystemd{},
+	}
+	for name, expected := range map[string]bool{
+		"/":                          false,
+		"/system.slice":              true,
+		"/system.slice/sshd.service": true,
+		"/user.slice/user-1000.slice/session-2.scope": true,
+		"/system.slice/sshd.service/child":            false,
+		"/docker/abcdef":                              false,
+		"/.service":                                   false,
+	} {
+		handle, accept, err := factory.CanHandleAndAccept(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, handle, "handle %q", name)
+		assert.Equal(t, expected, accept, "accept %q", name)
+	}
+
+	// Units are ignored if raw containers are.
+	factory.rawFactory = &fakeRawFactory{accept: false}
+	handle, accept, err := factory.CanHandleAndAccept("/system.slice/sshd.service")
+	assert.NoError(t, err)
+	assert.True(t, handle)
+	assert.False(t, accept)
+}
+
+func TestServiceReferenceAndLabels(t *testing.T) {
+	factory := &systemdFactory{
+		rawFactory: &fakeRawFactory{accept: true},
+		conn: fakeSystemd{
+			"sshd.service": {
+				"Description": "OpenSSH server daemon",
+			},
+		},
+	}
+	handler, err := factory.NewContainerHandler("/system.slice/sshd.service")
+	require.NoError(t, err)
+
+	ref, err := handler.ContainerReference()
+	require.NoError(t, err)
+	assert.Equal(t, info.ContainerReference{
+		Name:      "/system.slice/sshd.service",
+		Aliases:   []string{"sshd.service"},
+		Namespace: SystemdNamespace,
+	}, ref)
+	assert.Equal(t, map[string]string{
+		unitLabel:        "sshd.service",
+		descriptionLabel: "OpenSSH server daemon",
+	}, handler.GetContainerLabels())
+}
+
+func TestSliceLabels(t *testing.T) {
+	factory := &systemdFactory{
+		rawFactory: &fakeRawFactory{accept: true},
+		conn: fakeSystemd{
+			"system.slice": {
+				"Description": "System Slice",
+			},
+		},
+	}
+	handler, err := factory.NewContainerHandler("/system.slice")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		unitLabel:        "system.slice",
+		descriptionLabel: "System Slice",
+	}, handler.GetContainerLabels())
+
+	// Units systemd does not know about only have their name.
+	handler, err = factory.NewContainerHandler("/user.slice")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		unitLabel: "user.slice",
+	}, handler.GetContainerLabels())
+}
+
+func TestLabelsAreReadOnce(t *testing.T) {
+	conn := fakeSystemd{}
+	factory := &systemdFactory{
+		rawFactory: &fakeRawFactory{accept: true},
+		conn:       conn,
+	}
+	handler, err := factory.NewContainerHandler("/system.slice/sshd.service")
+	require.NoError(t, err)
+
+	// The labels are read again as long as systemd does not know the unit.
+	assert.Equal(t, map[string]string{
+		unitLabel: "sshd.service",
+	}, handler.GetContainerLabels())
+	conn["sshd.service"] = map[string]interface{}{
+		"Description": "OpenSSH server daemon",
+	}
+	expected := map[string]string{
+		unitLabel:        "sshd.service",
+		descriptionLabel: "OpenSSH server daemon",
+	}
+	assert.Equal(t, expected, handler.GetContainerLabels())
+
+	// But not once they were read.
+	delete(conn, "sshd.service")
+	assert.Equal(t, expected, handler.GetContainerLabels())
+}
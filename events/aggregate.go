@@ -0,0 +1,216 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+)
+
+// How the events are grouped when they are counted.
+type Aggregation struct {
+	// Count the events of each type separately.
+	ByType bool
+	// Count the events of each container separately.
+	ByContainer bool
+	// Count the events separately per value of this label of their
+	// container, if set.
+	ByLabel string
+	// Duration of the time buckets the events are counted in, 0 to count all
+	// of them in one bucket.
+	BucketSize time.Duration
+}
+
+// Parses a comma-separated list of groupings, each of which is one of
+// "type", "container" or "label:<key>".
+// e.g.: "type,label:app"
+func ParseGroupBy(groupBy string) (Aggregation, error) {
+	var aggregation Aggregation
+	for _, term := range strings.Split(groupBy, ",") {
+		term = strings.TrimSpace(term)
+		switch {
+		case term == "":
+			continue
+		case term == "type":
+			aggregation.ByType = true
+		case term == "container":
+			aggregation.ByContainer = true
+		case strings.HasPrefix(term, "label:"):
+			if aggregation.ByLabel != "" {
+				return Aggregation{}, fmt.Errorf("invalid grouping %q: only one label can be grouped by", groupBy)
+			}
+			aggregation.ByLabel = strings.TrimSpace(strings.TrimPrefix(term, "label:"))
+			if aggregation.ByLabel == "" {
+				return Aggregation{}, fmt.Errorf("invalid grouping %q: %q has no label", groupBy, term)
+			}
+		default:
+			return Aggregation{}, fmt.Errorf("invalid grouping %q: unknown grouping %q", groupBy, term)
+		}
+	}
+	return aggregation, nil
+}
+
+// Identifies a group of events counted together.
+type countKey struct {
+	eventType     info.EventType
+	containerName string
+	labelValue    string
+	bucketStart   int64
+}
+
+type byCountKey []v2.EventCount
+
+func (c byCountKey) Len() int {
+	return len(c)
+}
+
+func (c byCountKey) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+func (c byCountKey) Less(i, j int) bool {
+	if !c[i].BucketStart.Equal(c[j].BucketStart) {
+		return c[i].BucketStart.Before(c[j].BucketStart)
+	}
+	if c[i].EventType != c[j].EventType {
+		return c[i].EventType < c[j].EventType
+	}
+	if c[i].ContainerName != c[j].ContainerName {
+		return c[i].ContainerName < c[j].ContainerName
+	}
+	return c[i].LabelValue < c[j].LabelValue
+}
+
+// Counts the stored events that satisfy the request, grouped as specified by
+// the aggregation. MaxEventsReturned of the request is ignored.
+func (self *events) GetEventCounts(request *Request, aggregation Aggregation) ([]v2.EventCount, error) {
+	if aggregation.BucketSize < 0 {
+		return nil, fmt.Errorf("invalid bucket size %v", aggregation.BucketSize)
+	}
+	counts := make(map[countKey]uint64)
+	self.eventsLock.RLock()
+	defer self.eventsLock.RUnlock()
+	for eventType, fetch := range request.EventType {
+		if !fetch {
+			continue
+		}
+		evs, ok := self.eventStore[eventType]
+		if !ok {
+			continue
+		}
+		for _, in := range evs.InTimeRange(request.StartTime, request.EndTime, -1) {
+			e := in.(*info.Event)
+			if !checkIfEventSatisfiesRequest(request, e) {
+				continue
+			}
+			var key countKey
+			if aggregation.ByType {
+				key.eventType = e.EventType
+			}
+			if aggregation.ByContainer {
+				key.containerName = e.ContainerName
+			}
+			if aggregation.ByLabel != "" {
+				key.labelValue = e.ContainerLabels[aggregation.ByLabel]
+			}
+			if aggregation.BucketSize > 0 {
+				key.bucketStart = e.Timestamp.Truncate(aggregation.BucketSize).UnixNano()
+			}
+			counts[key]++
+		}
+	}
+
+	result := make([]v2.EventCount, 0, len(counts))
+	for key, count := range counts {
+		eventCount := v2.EventCount{
+			EventType:     key.eventType,
+			ContainerName: key.containerName,
+			LabelValue:    key.labelValue,
+			Count:         count,
+		}
+		if aggregation.BucketSize > 0 {
+			eventCount.BucketStart = time.Unix(0, key.bucketStart)
+		}
+		result = append(result, eventCount)
+	}
+	sort.Sort(byCountKey(result))
+	return result, nil
+}
+
+// Number of events of a type added for a container since the start.
+type EventTotal struct {
+	EventType     info.EventType
+	ContainerName string
+	// First alias of the container, if it has any.
+	Alias string
+	Count uint64
+}
+
+// Identifies the events counted in an EventTotal.
+type totalKey struct {
+	eventType     info.EventType
+	containerName string
+}
+
+type eventTotal struct {
+	EventTotal
+	// Time of the last event counted.
+	lastEvent time.Time
+}
+
+// Counts an added event. eventsLock must be held.
+func (self *events) countEvent(e *info.Event) {
+	key := totalKey{e.EventType, e.ContainerName}
+	total, ok := self.totals[key]
+	if !ok {
+		total = &eventTotal{
+			EventTotal: EventTotal{
+				EventType:     e.EventType,
+				ContainerName: e.ContainerName,
+			},
+		}
+		self.totals[key] = total
+	}
+	total.Count++
+	if len(e.ContainerAliases) > 0 {
+		total.Alias = e.ContainerAliases[0]
+	}
+	if e.Timestamp.After(total.lastEvent) {
+		total.lastEvent = e.Timestamp
+	}
+}
+
+// Returns the number of events added since the start per type and container.
+// The totals of the containers whose events all expired from the store are
+// forgotten, so that they do not accumulate as containers come and go.
+func (self *events) GetEventTotals() []EventTotal {
+	now := time.Now()
+	self.eventsLock.Lock()
+	defer self.eventsLock.Unlock()
+	totals := make([]EventTotal, 0, len(self.totals))
+	for key, total := range self.totals {
+		if total.lastEvent.Before(now.Add(-self.maxAge(key.eventType))) {
+			delete(self.totals, key)
+			continue
+		}
+		totals = append(totals, total.EventTotal)
+	}
+	return totals
+}
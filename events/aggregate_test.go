@@ -0,0 +1,132 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGroupBy(t *testing.T) {
+	aggregation, err := ParseGroupBy("type, label:app")
+	require.NoError(t, err)
+	assert.Equal(t, Aggregation{ByType: true, ByLabel: "app"}, aggregation)
+
+	aggregation, err = ParseGroupBy("")
+	require.NoError(t, err)
+	assert.Equal(t, Aggregation{}, aggregation)
+
+	for _, invalid := range []string{"pod", "label:", "label:a,label:b"} {
+		_, err := ParseGroupBy(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// Adds OOM kills to /docker/web twice, 30 minutes apart, and to /docker/db
+// once, and a creation of /docker/web.
+func addCountedEvents(t *testing.T, start time.Time) *events {
+	myEventHolder := NewEventManager(DefaultStoragePolicy(), DefaultWatchPolicy())
+	web := map[string]string{"app": "web"}
+	for _, e := range []*info.Event{
+		{ContainerName: "/docker/web", Timestamp: start.Add(10 * time.Minute), EventType: info.EventOomKill, ContainerLabels: web},
+		{ContainerName: "/docker/web", Timestamp: start.Add(40 * time.Minute), EventType: info.EventOomKill, ContainerLabels: web},
+		{ContainerName: "/docker/db", Timestamp: start.Add(70 * time.Minute), EventType: info.EventOomKill},
+		{ContainerName: "/docker/web", Timestamp: start, EventType: info.EventContainerCreation, ContainerLabels: web},
+	} {
+		require.NoError(t, myEventHolder.AddEvent(e))
+	}
+	return myEventHolder
+}
+
+func newCountRequest() *Request {
+	request := NewRequest()
+	request.ContainerName = "/"
+	request.IncludeSubcontainers = true
+	request.EventType[info.EventOomKill] = true
+	request.EventType[info.EventContainerCreation] = true
+	return request
+}
+
+func TestGetEventCounts(t *testing.T) {
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+	myEventHolder := addCountedEvents(t, start)
+
+	counts, err := myEventHolder.GetEventCounts(newCountRequest(), Aggregation{ByType: true, ByContainer: true})
+	require.NoError(t, err)
+	assert.Equal(t, []v2.EventCount{
+		{EventType: info.EventContainerCreation, ContainerName: "/docker/web", Count: 1},
+		{EventType: info.EventOomKill, ContainerName: "/docker/db", Count: 1},
+		{EventType: info.EventOomKill, ContainerName: "/docker/web", Count: 2},
+	}, counts)
+
+	// MaxEventsReturned does not cap the counts.
+	request := newCountRequest()
+	request.MaxEventsReturned = 1
+	counts, err = myEventHolder.GetEventCounts(request, Aggregation{})
+	require.NoError(t, err)
+	assert.Equal(t, []v2.EventCount{{Count: 4}}, counts)
+}
+
+func TestGetEventCountsByLabelAndBucket(t *testing.T) {
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+	myEventHolder := addCountedEvents(t, start)
+
+	request := newCountRequest()
+	delete(request.EventType, info.EventContainerCreation)
+	counts, err := myEventHolder.GetEventCounts(request, Aggregation{ByLabel: "app", BucketSize: time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(counts))
+	assert.Equal(t, "web", counts[0].LabelValue)
+	assert.Equal(t, uint64(2), counts[0].Count)
+	assert.True(t, start.Equal(counts[0].BucketStart))
+	assert.Equal(t, "", counts[1].LabelValue)
+	assert.Equal(t, uint64(1), counts[1].Count)
+	assert.True(t, start.Add(time.Hour).Equal(counts[1].BucketStart))
+
+	// Only the events in the time range are counted.
+	request.StartTime = start.Add(30 * time.Minute)
+	counts, err = myEventHolder.GetEventCounts(request, Aggregation{ByContainer: true})
+	require.NoError(t, err)
+	assert.Equal(t, []v2.EventCount{
+		{ContainerName: "/docker/db", Count: 1},
+		{ContainerName: "/docker/web", Count: 1},
+	}, counts)
+}
+
+func TestGetEventTotals(t *testing.T) {
+	policy := DefaultStoragePolicy()
+	policy.PerTypeMaxAge[info.EventContainerCreation] = time.Minute
+	myEventHolder := NewEventManager(policy, DefaultWatchPolicy())
+	now := time.Now()
+	for _, e := range []*info.Event{
+		{ContainerName: "/docker/web", Timestamp: now, EventType: info.EventOomKill, ContainerAliases: []string{"web"}},
+		{ContainerName: "/docker/web", Timestamp: now, EventType: info.EventOomKill, ContainerAliases: []string{"web"}},
+		// Expired, so forgotten.
+		{ContainerName: "/docker/web", Timestamp: now.Add(-time.Hour), EventType: info.EventContainerCreation},
+	} {
+		require.NoError(t, myEventHolder.AddEvent(e))
+	}
+
+	totals := myEventHolder.GetEventTotals()
+	assert.Equal(t, []EventTotal{
+		{EventType: info.EventOomKill, ContainerName: "/docker/web", Alias: "web", Count: 2},
+	}, totals)
+	assert.Equal(t, 1, len(myEventHolder.totals))
+}
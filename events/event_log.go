@@ -0,0 +1,222 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Minimum number of events in the log before it is compacted.
+const minEventsToCompact = 1000
+
+// A write-ahead log of events, with an event per line encoded as JSON. The log
+// is only appended to, it is compacted by rewriting it with the sequence number
+// of the last event added and the events that are still stored. Events are written without syncing the file, so they
+// survive restarts of the process but not of the machine.
+type eventLog struct {
+	path string
+	// lock guarding file, numEvents, compacting and pending.
+	lock sync.Mutex
+	file *os.File
+	// Number of events in the log, including those that are not stored
+	// anymore.
+	numEvents int
+	// Whether the log is being compacted.
+	compacting bool
+	// Events appended while the log is compacted, which are appended to the
+	// compacted log too.
+	pending []*info.Event
+}
+
+// A line of the log, which is either an event or the sequence number of the
+// last event added when the log was compacted, which the events retained may
+// not have anymore.
+type logLine struct {
+	LastSequence uint64 `json:"last_sequence"`
+	*info.Event
+}
+
+// Opens the event log at the specified path, creating it if it does not
+// exist. Returns the events in the log, skipping those that are corrupted
+// like the last one written before a crash may be, and the sequence number of
+// the last event added, 0 when there is none.
+func openEventLog(path string) (*eventLog, []*info.Event, uint64, error) {
+	events, lastSequence, err := readEventLog(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return &eventLog{
+		path:      path,
+		file:      file,
+		numEvents: len(events),
+	}, events, lastSequence, nil
+}
+
+func readEventLog(path string) ([]*info.Event, uint64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	events := []*info.Event{}
+	lastSequence := uint64(0)
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return events, lastSequence, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, 0, fmt.Errorf("failed to read event log %q: %v", path, err)
+		}
+		var logged logLine
+		if err := json.Unmarshal(line, &logged); err != nil {
+			glog.Warningf("Skipping corrupted event at line %d of event log %q: %v", lineNumber, path, err)
+			continue
+		}
+		if logged.LastSequence > lastSequence {
+			lastSequence = logged.LastSequence
+		}
+		if logged.Event == nil {
+			continue
+		}
+		if logged.Sequence > lastSequence {
+			lastSequence = logged.Sequence
+		}
+		events = append(events, logged.Event)
+	}
+}
+
+// Appends an event to the log.
+func (self *eventLog) append(e *info.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if _, err := self.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to event log %q: %v", self.path, err)
+	}
+	self.numEvents++
+	if self.compacting {
+		self.pending = append(self.pending, e)
+	}
+	return nil
+}
+
+// Returns whether the log has enough events that are not stored anymore to be
+// compacted, in which case it is marked as being compacted until compact()
+// returns.
+func (self *eventLog) startCompaction(numStoredEvents int) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.compacting || self.numEvents <= minEventsToCompact || self.numEvents <= 2*numStoredEvents {
+		return false
+	}
+	self.compacting = true
+	return true
+}
+
+// Replaces the content of the log with the sequence number of the last event
+// added, the specified events, and those appended since startCompaction().
+// The events are written without holding the lock of the log, so that
+// appending is not blocked by compaction.
+func (self *eventLog) compact(events []*info.Event, lastSequence uint64) error {
+	err := self.rewrite(events, lastSequence)
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.compacting = false
+	self.pending = nil
+	return err
+}
+
+func (self *eventLog) rewrite(events []*info.Event, lastSequence uint64) error {
+	tmpPath := self.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	err = encoder.Encode(&logLine{LastSequence: lastSequence})
+	for _, e := range events {
+		if err != nil {
+			break
+		}
+		err = encoder.Encode(e)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact event log %q: %v", self.path, err)
+	}
+
+	// Events are appended to the old log until it is replaced.
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if err := os.Rename(tmpPath, self.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact event log %q: %v", self.path, err)
+	}
+	file, err := os.OpenFile(self.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	self.file.Close()
+	self.file = file
+	self.numEvents = len(events)
+	for _, e := range self.pending {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := self.file.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write to event log %q: %v", self.path, err)
+		}
+		self.numEvents++
+	}
+	return nil
+}
+
+func (self *eventLog) close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.file.Close()
+}
@@ -0,0 +1,331 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEventLogPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "events")
+	require.NoError(t, err)
+	return path.Join(dir, "events.log"), func() { os.RemoveAll(dir) }
+}
+
+func newTestPersistentEventManager(t *testing.T, storagePolicy StoragePolicy, logPath string) *events {
+	eventManager, err := NewPersistentEventManager(storagePolicy, DefaultWatchPolicy(), logPath)
+	require.NoError(t, err)
+	return eventManager
+}
+
+func getAllEvents(t *testing.T, eventManager *events, eventTypes ...info.EventType) []*info.Event {
+	request := NewRequest()
+	request.MaxEventsReturned = -1
+	for _, eventType := range eventTypes {
+		request.EventType[eventType] = true
+	}
+	returned, err := eventManager.GetEvents(request)
+	require.NoError(t, err)
+	return returned
+}
+
+func countLines(t *testing.T, file string) int {
+	content, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	return strings.Count(string(content), "\n")
+}
+
+func TestEventsAreReplayed(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	now := time.Now().Truncate(time.Second)
+
+	eventManager := newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	oomKill := &info.Event{
+		ContainerName: "/docker/abc",
+		Timestamp:     now.Add(-time.Minute),
+		EventType:     info.EventOomKill,
+		EventData: info.EventData{
+			OomKill: &info.OomKillEventData{Pid: 42, ProcessName: "stress"},
+		},
+	}
+	deletion := &info.Event{
+		ContainerName: "/docker/abc",
+		Timestamp:     now,
+		EventType:     info.EventContainerDeletion,
+	}
+	require.NoError(t, eventManager.AddEvent(oomKill))
+	require.NoError(t, eventManager.AddEvent(deletion))
+	eventManager.eventLog.close()
+
+	// A restarted manager has the events of the previous one.
+	eventManager = newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	defer eventManager.eventLog.close()
+	returned := getAllEvents(t, eventManager, info.EventOomKill, info.EventContainerDeletion)
+	require.Equal(t, 2, len(returned))
+	assert.Equal(t, oomKill.ContainerName, returned[0].ContainerName)
+	assert.True(t, oomKill.Timestamp.Equal(returned[0].Timestamp))
+	assert.Equal(t, oomKill.EventData, returned[0].EventData)
+	assert.Equal(t, info.EventContainerDeletion, returned[1].EventType)
+
+	// New events are persisted after the replayed ones.
+	creation := &info.Event{
+		ContainerName: "/docker/def",
+		Timestamp:     now.Add(time.Second),
+		EventType:     info.EventContainerCreation,
+	}
+	require.NoError(t, eventManager.AddEvent(creation))
+	// After the last sequence number written when the log was compacted.
+	assert.Equal(t, 4, countLines(t, logPath))
+}
+
+func TestReplayedEventsAreNotAddedTwice(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	creation := &info.Event{
+		ContainerName: "/docker/abc",
+		Timestamp:     time.Now().Add(-time.Hour),
+		EventType:     info.EventContainerCreation,
+	}
+
+	eventManager := newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	require.NoError(t, eventManager.AddEvent(creation))
+	eventManager.eventLog.close()
+
+	// The creation of the running container is added again after a restart.
+	eventManager = newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	defer eventManager.eventLog.close()
+	watch, err := eventManager.WatchEvents(newRequestFor(info.EventContainerCreation))
+	require.NoError(t, err)
+	require.NoError(t, eventManager.AddEvent(&info.Event{
+		ContainerName: creation.ContainerName,
+		Timestamp:     creation.Timestamp,
+		EventType:     creation.EventType,
+	}))
+	assert.Equal(t, 1, len(getAllEvents(t, eventManager, info.EventContainerCreation)))
+	assert.Equal(t, 2, countLines(t, logPath))
+	// Watchers still receive it.
+	assert.Equal(t, 1, len(watch.GetChannel()))
+
+	// Only once.
+	require.NoError(t, eventManager.AddEvent(creation))
+	assert.Equal(t, 2, len(getAllEvents(t, eventManager, info.EventContainerCreation)))
+}
+
+func TestReplayedEventsAreMatchedWithinTolerance(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	oomKill := &info.Event{
+		ContainerName: "/docker/abc",
+		Timestamp:     time.Now().Add(-time.Hour),
+		EventType:     info.EventOomKill,
+	}
+
+	eventManager := newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	require.NoError(t, eventManager.AddEvent(oomKill))
+	eventManager.eventLog.close()
+
+	// The kernel log timestamps of the OOM kill shift with the boot time
+	// between restarts.
+	eventManager = newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	defer eventManager.eventLog.close()
+	again := &info.Event{
+		ContainerName: oomKill.ContainerName,
+		Timestamp:     oomKill.Timestamp.Add(1300 * time.Millisecond),
+		EventType:     oomKill.EventType,
+	}
+	require.NoError(t, eventManager.AddEvent(again))
+	// It keeps its sequence number, so that streaming clients recognize it.
+	assert.Equal(t, oomKill.Sequence, again.Sequence)
+	assert.Equal(t, 1, len(getAllEvents(t, eventManager, info.EventOomKill)))
+	assert.Equal(t, 2, countLines(t, logPath))
+	assert.Empty(t, eventManager.replayedEvents)
+}
+
+func TestReplayedEventsExpire(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	now := time.Now()
+
+	eventManager := newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	require.NoError(t, eventManager.AddEvent(&info.Event{
+		ContainerName: "/docker/abc",
+		Timestamp:     now.Add(-30 * time.Minute),
+		EventType:     info.EventContainerCreation,
+	}))
+	eventManager.eventLog.close()
+
+	storagePolicy := DefaultStoragePolicy()
+	storagePolicy.PerTypeMaxAge[info.EventContainerCreation] = time.Hour
+	eventManager = newTestPersistentEventManager(t, storagePolicy, logPath)
+	defer eventManager.eventLog.close()
+	require.Equal(t, 1, len(eventManager.replayedEvents))
+
+	eventManager.eventsLock.Lock()
+	eventManager.expireReplayedEvents(now.Add(20 * time.Minute))
+	assert.Equal(t, 1, len(eventManager.replayedEvents))
+	eventManager.expireReplayedEvents(now.Add(40 * time.Minute))
+	assert.Empty(t, eventManager.replayedEvents)
+	eventManager.eventsLock.Unlock()
+}
+
+func TestEventLogCompactionKeepsAppendedEvents(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	eventLog, _, _, err := openEventLog(logPath)
+	require.NoError(t, err)
+	defer eventLog.close()
+	now := time.Now()
+	for i := 0; i <= minEventsToCompact; i++ {
+		require.NoError(t, eventLog.append(&info.Event{ContainerName: "/", Timestamp: now, EventType: info.EventOom}))
+	}
+
+	require.True(t, eventLog.startCompaction(1))
+	assert.False(t, eventLog.startCompaction(1))
+	// Appended while the stored events are written.
+	require.NoError(t, eventLog.append(&info.Event{ContainerName: "/a", Timestamp: now, EventType: info.EventOom}))
+	require.NoError(t, eventLog.compact([]*info.Event{{ContainerName: "/", Timestamp: now, EventType: info.EventOom}}, 7))
+
+	logged, lastSequence, err := readEventLog(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), lastSequence)
+	require.Equal(t, 2, len(logged))
+	assert.Equal(t, "/a", logged[1].ContainerName)
+	assert.Equal(t, 2, eventLog.numEvents)
+	assert.Empty(t, eventLog.pending)
+}
+
+func TestReplayAppliesStoragePolicy(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	now := time.Now()
+
+	eventManager := newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, eventManager.AddEvent(&info.Event{
+			ContainerName: "/",
+			Timestamp:     now.Add(time.Duration(i-5) * time.Minute),
+			EventType:     info.EventOom,
+		}))
+	}
+	require.NoError(t, eventManager.AddEvent(&info.Event{
+		ContainerName: "/",
+		Timestamp:     now.Add(-2 * time.Hour),
+		EventType:     info.EventContainerDeletion,
+	}))
+	eventManager.eventLog.close()
+
+	storagePolicy := DefaultStoragePolicy()
+	storagePolicy.PerTypeMaxNumEvents[info.EventOom] = 3
+	storagePolicy.PerTypeMaxAge[info.EventContainerDeletion] = time.Hour
+	eventManager = newTestPersistentEventManager(t, storagePolicy, logPath)
+	defer eventManager.eventLog.close()
+
+	returned := getAllEvents(t, eventManager, info.EventOom, info.EventContainerDeletion)
+	require.Equal(t, 3, len(returned))
+	for _, e := range returned {
+		assert.Equal(t, info.EventOom, e.EventType)
+	}
+	assert.True(t, now.Add(-3*time.Minute).Equal(returned[0].Timestamp))
+	// The log only has the last sequence number and the retained events.
+	assert.Equal(t, 4, countLines(t, logPath))
+}
+
+func TestReplaySkipsCorruptedEvents(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+	content := `{"container_name":"/a","timestamp":"` + timestamp + `","event_type":"oom"}
+not an event
+{"container_name":"/b","timestamp":"` + timestamp + `","event_type":"o`
+	require.NoError(t, ioutil.WriteFile(logPath, []byte(content), 0644))
+
+	eventManager := newTestPersistentEventManager(t, DefaultStoragePolicy(), logPath)
+	defer eventManager.eventLog.close()
+	returned := getAllEvents(t, eventManager, info.EventOom)
+	require.Equal(t, 1, len(returned))
+	assert.Equal(t, "/a", returned[0].ContainerName)
+	assert.Equal(t, 2, countLines(t, logPath))
+}
+
+func TestEventLogIsCompacted(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	storagePolicy := DefaultStoragePolicy()
+	storagePolicy.DefaultMaxNumEvents = 10
+
+	eventManager := newTestPersistentEventManager(t, storagePolicy, logPath)
+	defer eventManager.eventLog.close()
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i <= minEventsToCompact; i++ {
+		require.NoError(t, eventManager.AddEvent(&info.Event{
+			ContainerName: "/",
+			Timestamp:     start.Add(time.Duration(i) * time.Millisecond),
+			EventType:     info.EventOom,
+		}))
+	}
+	// The log is compacted in the background.
+	eventManager.compactions.Wait()
+	assert.Equal(t, 11, countLines(t, logPath))
+	assert.Equal(t, 10, eventManager.eventLog.numEvents)
+
+	// Events are still appended after compaction.
+	require.NoError(t, eventManager.AddEvent(&info.Event{
+		ContainerName: "/",
+		Timestamp:     time.Now(),
+		EventType:     info.EventOom,
+	}))
+	assert.Equal(t, 12, countLines(t, logPath))
+}
+
+func TestSequenceNumbersContinueAfterRestart(t *testing.T) {
+	logPath, cleanup := newTestEventLogPath(t)
+	defer cleanup()
+	storagePolicy := DefaultStoragePolicy()
+	storagePolicy.PerTypeMaxAge[info.EventOom] = time.Hour
+
+	// The sequence numbers of an empty log start at 1.
+	eventManager := newTestPersistentEventManager(t, storagePolicy, logPath)
+	e := &info.Event{ContainerName: "/", Timestamp: time.Now().Add(-2 * time.Hour), EventType: info.EventOom}
+	require.NoError(t, eventManager.AddEvent(e))
+	assert.Equal(t, uint64(1), e.Sequence)
+	eventManager.eventLog.close()
+
+	// The expired event is dropped from the log, but not its sequence number.
+	for i := 0; i < 2; i++ {
+		eventManager = newTestPersistentEventManager(t, storagePolicy, logPath)
+		assert.Empty(t, getAllEvents(t, eventManager, info.EventOom))
+		eventManager.eventLog.close()
+	}
+	eventManager = newTestPersistentEventManager(t, storagePolicy, logPath)
+	defer eventManager.eventLog.close()
+	e = &info.Event{ContainerName: "/", Timestamp: time.Now(), EventType: info.EventOom}
+	require.NoError(t, eventManager.AddEvent(e))
+	assert.Equal(t, uint64(2), e.Sequence)
+}
+
+func TestOpenEventLogFails(t *testing.T) {
+	_, err := NewPersistentEventManager(DefaultStoragePolicy(), DefaultWatchPolicy(), "/does/not/exist/events.log")
+	assert.Error(t, err)
+}
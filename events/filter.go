@@ -0,0 +1,139 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+type LabelOperator string
+
+const (
+	// The label has the value.
+	LabelEquals LabelOperator = "="
+	// The label is missing or has another value.
+	LabelNotEquals LabelOperator = "!="
+	// The label is present, whatever its value.
+	LabelExists LabelOperator = "exists"
+	// The label is missing.
+	LabelNotExists LabelOperator = "!exists"
+)
+
+// A requirement on a label of the container of an event.
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	Value    string
+}
+
+// Requirements that all have to be met.
+type LabelSelector []LabelRequirement
+
+// Parses a comma-separated list of requirements, each of which is one of
+// "key=value", "key==value", "key!=value", "key" or "!key".
+// e.g.: "app=web,tier!=db,canary"
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var requirements LabelSelector
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		var requirement LabelRequirement
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			requirement = LabelRequirement{Key: parts[0], Operator: LabelNotEquals, Value: parts[1]}
+		case strings.Contains(term, "=="):
+			parts := strings.SplitN(term, "==", 2)
+			requirement = LabelRequirement{Key: parts[0], Operator: LabelEquals, Value: parts[1]}
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2)
+			requirement = LabelRequirement{Key: parts[0], Operator: LabelEquals, Value: parts[1]}
+		case strings.HasPrefix(term, "!"):
+			requirement = LabelRequirement{Key: term[1:], Operator: LabelNotExists}
+		default:
+			requirement = LabelRequirement{Key: term, Operator: LabelExists}
+		}
+		requirement.Key = strings.TrimSpace(requirement.Key)
+		requirement.Value = strings.TrimSpace(requirement.Value)
+		if requirement.Key == "" {
+			return nil, fmt.Errorf("invalid label selector %q: %q has no key", selector, term)
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// Returns whether the labels meet all the requirements.
+func (self LabelSelector) Matches(labels map[string]string) bool {
+	for _, requirement := range self {
+		value, ok := labels[requirement.Key]
+		switch requirement.Operator {
+		case LabelEquals:
+			if !ok || value != requirement.Value {
+				return false
+			}
+		case LabelNotEquals:
+			if ok && value == requirement.Value {
+				return false
+			}
+		case LabelExists:
+			if !ok {
+				return false
+			}
+		case LabelNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns an error if the pattern of aliases is malformed.
+func ValidateAliasPattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid alias pattern %q: %v", pattern, err)
+	}
+	return nil
+}
+
+// Returns whether an alias of the container of the event matches the glob
+// pattern, e.g.: "web-*".
+func checkIfAliasMatches(pattern string, event *info.Event) bool {
+	for _, alias := range event.ContainerAliases {
+		if matched, _ := path.Match(pattern, alias); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether the container of the event satisfies the label selector,
+// alias pattern and namespace of the request.
+func checkIfContainerMatches(request *Request, event *info.Event) bool {
+	if request.Namespace != "" && event.ContainerNamespace != request.Namespace {
+		return false
+	}
+	if request.AliasPattern != "" && !checkIfAliasMatches(request.AliasPattern, event) {
+		return false
+	}
+	return request.LabelSelector.Matches(event.ContainerLabels)
+}
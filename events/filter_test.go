@@ -0,0 +1,129 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	cases := []struct {
+		selector string
+		expected LabelSelector
+	}{
+		{"", nil},
+		{"app=web", LabelSelector{{Key: "app", Operator: LabelEquals, Value: "web"}}},
+		{"app==web", LabelSelector{{Key: "app", Operator: LabelEquals, Value: "web"}}},
+		{"app = web , tier!=db", LabelSelector{
+			{Key: "app", Operator: LabelEquals, Value: "web"},
+			{Key: "tier", Operator: LabelNotEquals, Value: "db"},
+		}},
+		{"canary,!legacy", LabelSelector{
+			{Key: "canary", Operator: LabelExists},
+			{Key: "legacy", Operator: LabelNotExists},
+		}},
+		{"app=", LabelSelector{{Key: "app", Operator: LabelEquals, Value: ""}}},
+	}
+	for _, c := range cases {
+		selector, err := ParseLabelSelector(c.selector)
+		require.NoError(t, err, c.selector)
+		assert.Equal(t, c.expected, selector, c.selector)
+	}
+
+	for _, invalid := range []string{"=web", "!", "app=web,!=db"} {
+		_, err := ParseLabelSelector(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"app": "web", "tier": "frontend"}
+	cases := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"app=web", true},
+		{"app=db", false},
+		{"app=web,tier=frontend", true},
+		{"app=web,tier=backend", false},
+		{"tier!=backend", true},
+		{"tier!=frontend", false},
+		{"missing!=value", true},
+		{"app", true},
+		{"missing", false},
+		{"!missing", true},
+		{"!app", false},
+	}
+	for _, c := range cases {
+		selector, err := ParseLabelSelector(c.selector)
+		require.NoError(t, err)
+		assert.Equal(t, c.matches, selector.Matches(labels), c.selector)
+	}
+	assert.False(t, LabelSelector{{Key: "app", Operator: LabelExists}}.Matches(nil))
+}
+
+func TestValidateAliasPattern(t *testing.T) {
+	assert.NoError(t, ValidateAliasPattern("web-*"))
+	assert.Error(t, ValidateAliasPattern("web-["))
+}
+
+func newLabeledEvent(containerName string, labels map[string]string, aliases ...string) *info.Event {
+	return &info.Event{
+		ContainerName:      containerName,
+		Timestamp:          time.Now(),
+		EventType:          info.EventOomKill,
+		ContainerLabels:    labels,
+		ContainerAliases:   aliases,
+		ContainerNamespace: "docker",
+	}
+}
+
+func TestEventsAreFilteredByContainer(t *testing.T) {
+	eventManager := NewEventManager(DefaultStoragePolicy(), DefaultWatchPolicy())
+	request := newRequestFor(info.EventOomKill)
+	request.LabelSelector = LabelSelector{{Key: "app", Operator: LabelEquals, Value: "web"}}
+	request.AliasPattern = "web-*"
+	request.Namespace = "docker"
+	watch, err := eventManager.WatchEvents(request)
+	require.NoError(t, err)
+
+	web := newLabeledEvent("/docker/abc", map[string]string{"app": "web"}, "web-1", "abc")
+	otherApp := newLabeledEvent("/docker/def", map[string]string{"app": "db"}, "web-2", "def")
+	otherAlias := newLabeledEvent("/docker/ghi", map[string]string{"app": "web"}, "api-1", "ghi")
+	otherNamespace := newLabeledEvent("/docker/jkl", map[string]string{"app": "web"}, "web-3", "jkl")
+	otherNamespace.ContainerNamespace = "rkt"
+	unknown := &info.Event{
+		ContainerName: "/docker/mno",
+		Timestamp:     time.Now(),
+		EventType:     info.EventOomKill,
+	}
+	for _, e := range []*info.Event{web, otherApp, otherAlias, otherNamespace, unknown} {
+		require.NoError(t, eventManager.AddEvent(e))
+	}
+
+	returned, err := eventManager.GetEvents(request)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(returned))
+	assert.Equal(t, web, returned[0])
+
+	require.Equal(t, 1, len(watch.GetChannel()))
+	assert.Equal(t, web, <-watch.GetChannel())
+}
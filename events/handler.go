@@ -16,13 +16,16 @@ package events
 
 import (
 	"errors"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
 	info "github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v2"
 	"github.com/google/cadvisor/utils"
 )
 
@@ -42,12 +45,46 @@ func (e byTimestamp) Less(i, j int) bool {
 }
 
 type EventChannel struct {
+	// Number of events that were dropped because the channel was full.
+	// Accessed atomically, so it is first to be 64-bit aligned.
+	droppedEvents uint64
 	// Watch ID. Can be used by the caller to request cancellation of watch events.
 	watchId int
-	// Channel on which the caller can receive watch events.
+	// Channel on which the caller can receive watch events. It is closed when
+	// the watch is stopped.
 	channel chan *info.Event
 }
 
+// The known event types, by the name of the parameter of the events API
+// selecting them.
+var EventTypeParams = map[string]info.EventType{
+	"oom_events":                     info.EventOom,
+	"oom_kill_events":                info.EventOomKill,
+	"creation_events":                info.EventContainerCreation,
+	"deletion_events":                info.EventContainerDeletion,
+	"start_events":                   info.EventContainerStart,
+	"stop_events":                    info.EventContainerStop,
+	"exit_events":                    info.EventContainerExit,
+	"restart_events":                 info.EventContainerRestart,
+	"pause_events":                   info.EventContainerPause,
+	"unpause_events":                 info.EventContainerUnpause,
+	"health_events":                  info.EventContainerHealthStatus,
+	"alert_events":                   info.EventAlert,
+	"memory_limit_approached_events": info.EventMemoryLimitApproached,
+	"cpu_throttled_events":           info.EventCpuThrottled,
+	"fs_nearly_full_events":          info.EventFsNearlyFull,
+}
+
+// Whether the event type is one of EventTypeParams.
+func IsKnownEventType(eventType info.EventType) bool {
+	for _, known := range EventTypeParams {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
 // Request holds a set of parameters by which Event objects may be screened.
 // The caller may want events that occurred within a specific timeframe
 // or of a certain type, which may be specified in the *Request object
@@ -71,6 +108,15 @@ type Request struct {
 	// if IncludeSubcontainers is false, only events occurring in the specific
 	// container, and not the subcontainers, will be returned
 	IncludeSubcontainers bool
+	// only events of containers whose labels meet the requirements of
+	// LabelSelector are returned
+	LabelSelector LabelSelector
+	// if set, only events of containers with an alias matching the glob
+	// pattern are returned, ex. "web-*"
+	AliasPattern string
+	// if set, only events of containers in the namespace are returned,
+	// ex. "docker"
+	Namespace string
 }
 
 // EventManager is implemented by Events. It provides two ways to monitor
@@ -86,6 +132,15 @@ type EventManager interface {
 	AddEvent(e *info.Event) error
 	// Cancels a previously requested watch event.
 	StopWatch(watch_id int)
+	// AddSink() pushes the events that satisfy the request to the sink, until
+	// the returned watch id is stopped.
+	AddSink(request *Request, sink Sink) (int, error)
+	// GetEventCounts() counts the stored events that satisfy the request,
+	// grouped by type, container, label and time bucket.
+	GetEventCounts(request *Request, aggregation Aggregation) ([]v2.EventCount, error)
+	// GetEventTotals() returns the number of events added since the start
+	// per type and container.
+	GetEventTotals() []EventTotal
 }
 
 // events provides an implementation for the EventManager interface.
@@ -96,14 +151,46 @@ type events struct {
 	watchers map[int]*watch
 	// lock guarding the eventStore.
 	eventsLock sync.RWMutex
-	// lock guarding watchers.
+	// lock guarding watchers, taken after eventsLock when both are held.
 	watcherLock sync.RWMutex
 	// last allocated watch id.
 	lastId int
 	// Event storage policy.
 	storagePolicy StoragePolicy
+	// Policy for the watchers.
+	watchPolicy WatchPolicy
+	// Log the events are persisted to, if any. Guarded by eventsLock.
+	eventLog *eventLog
+	// Events replayed from the log, which are not stored again when they are
+	// added after a restart, e.g.: the creation of running containers or the
+	// OOMs still in the kernel log. Guarded by eventsLock.
+	replayedEvents map[replayKey][]*info.Event
+	// Last time the replayed events older than their max age were forgotten.
+	// Guarded by eventsLock.
+	lastReplayExpiry time.Time
+	// Compactions of the event log running in the background.
+	compactions sync.WaitGroup
+	// Sequence number of the last event added. Guarded by eventsLock.
+	lastSequence uint64
+	// Number of events added per type and container. Guarded by eventsLock.
+	totals map[totalKey]*eventTotal
 }
 
+// Identifies the events an event replayed from the log may be added again as.
+type replayKey struct {
+	containerName string
+	eventType     info.EventType
+}
+
+// How far apart the timestamps of a replayed event and of the same event added
+// again may be. The timestamps of the kernel log are relative to the boot
+// time, whose wall clock time shifts when the clock is adjusted.
+const replayTolerance = 2 * time.Second
+
+// How often the replayed events that were not added again are looked for
+// expired ones.
+const replayExpiryInterval = time.Minute
+
 // initialized by a call to WatchEvents(), a watch struct will then be added
 // to the events slice of *watch objects. When AddEvent() finds an event that
 // satisfies the request parameter of a watch object in events.watchers,
@@ -118,12 +205,14 @@ type watch struct {
 	// unique identifier of a watch that is used as a key in events' watchers
 	// map
 	id int
+	// what to do when the watch does not keep up with the events
+	slowWatcherPolicy SlowWatcherPolicy
 }
 
-func NewEventChannel(watchId int) *EventChannel {
+func NewEventChannel(watchId int, bufferSize int) *EventChannel {
 	return &EventChannel{
 		watchId: watchId,
-		channel: make(chan *info.Event, 10),
+		channel: make(chan *info.Event, bufferSize),
 	}
 }
 
@@ -149,15 +238,82 @@ func DefaultStoragePolicy() StoragePolicy {
 	}
 }
 
+// What to do with a watcher that does not keep up with the events.
+type SlowWatcherPolicy string
+
+const (
+	// Drop the events that do not fit in the buffer of the watcher.
+	DropEvents SlowWatcherPolicy = "drop"
+	// Stop the watch, which closes its channel.
+	DisconnectWatcher SlowWatcherPolicy = "disconnect"
+)
+
+// Policy specifying how events are delivered to watchers.
+type WatchPolicy struct {
+	// Number of events buffered for each watcher.
+	BufferSize int
+	// What to do when the buffer of a watcher is full.
+	SlowWatcherPolicy SlowWatcherPolicy
+}
+
+func DefaultWatchPolicy() WatchPolicy {
+	return WatchPolicy{
+		BufferSize:        100,
+		SlowWatcherPolicy: DropEvents,
+	}
+}
+
 // returns a pointer to an initialized Events object.
-func NewEventManager(storagePolicy StoragePolicy) *events {
+func NewEventManager(storagePolicy StoragePolicy, watchPolicy WatchPolicy) *events {
 	return &events{
 		eventStore:    make(map[info.EventType]*utils.TimedStore, 0),
 		watchers:      make(map[int]*watch),
+		totals:        make(map[totalKey]*eventTotal),
 		storagePolicy: storagePolicy,
+		watchPolicy:   watchPolicy,
 	}
 }
 
+// returns a pointer to an initialized Events object, which persists its events
+// to the log at the specified path. The events of the log that the storage
+// policy still retains are replayed into the store.
+func NewPersistentEventManager(storagePolicy StoragePolicy, watchPolicy WatchPolicy, logPath string) (*events, error) {
+	self := NewEventManager(storagePolicy, watchPolicy)
+	eventLog, loggedEvents, lastSequence, err := openEventLog(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log %q: %v", logPath, err)
+	}
+
+	// The sequence numbers of the events of a restarted cAdvisor follow
+	// those of the previous one.
+	self.lastSequence = lastSequence
+	now := time.Now()
+	self.replayedEvents = make(map[replayKey][]*info.Event)
+	self.lastReplayExpiry = now
+	for _, e := range loggedEvents {
+		if e.Timestamp.Before(now.Add(-self.maxAge(e.EventType))) {
+			continue
+		}
+		if e.Sequence == 0 {
+			// Logged before events had sequence numbers.
+			self.lastSequence++
+			e.Sequence = self.lastSequence
+		}
+		self.addToStore(e)
+		key := replayKey{e.ContainerName, e.EventType}
+		self.replayedEvents[key] = append(self.replayedEvents[key], e)
+	}
+	glog.Infof("Replayed %d events from event log %q", self.numStoredEvents(), logPath)
+
+	// Drop the events that are not retained anymore.
+	if err := eventLog.compact(self.storedEvents(), self.lastSequence); err != nil {
+		eventLog.close()
+		return nil, err
+	}
+	self.eventLog = eventLog
+	return self, nil
+}
+
 // returns a pointer to an initialized Request object
 func NewRequest() *Request {
 	return &Request{
@@ -168,10 +324,11 @@ func NewRequest() *Request {
 }
 
 // returns a pointer to an initialized watch object
-func newWatch(request *Request, eventChannel *EventChannel) *watch {
+func newWatch(request *Request, eventChannel *EventChannel, slowWatcherPolicy SlowWatcherPolicy) *watch {
 	return &watch{
-		request:      request,
-		eventChannel: eventChannel,
+		request:           request,
+		eventChannel:      eventChannel,
+		slowWatcherPolicy: slowWatcherPolicy,
 	}
 }
 
@@ -183,6 +340,12 @@ func (self *EventChannel) GetWatchId() int {
 	return self.watchId
 }
 
+// Returns the number of events that were dropped because the caller did not
+// receive them fast enough.
+func (self *EventChannel) GetDroppedEvents() uint64 {
+	return atomic.LoadUint64(&self.droppedEvents)
+}
+
 // sorts and returns up to the last MaxEventsReturned chronological elements
 func getMaxEventsReturned(request *Request, eSlice []*info.Event) []*info.Event {
 	sort.Sort(byTimestamp(eSlice))
@@ -222,6 +385,9 @@ func checkIfEventSatisfiesRequest(request *Request, event *info.Event) bool {
 	if !request.EventType[event.EventType] {
 		return false
 	}
+	if !checkIfContainerMatches(request, event) {
+		return false
+	}
 	if request.ContainerName != "" {
 		return checkIfIsSubcontainer(request, event)
 	}
@@ -264,6 +430,10 @@ func (self *events) GetEvents(request *Request) ([]*info.Event, error) {
 // request should be uninitialized because the purpose is to watch indefinitely
 // for events that will happen in the future
 func (self *events) WatchEvents(request *Request) (*EventChannel, error) {
+	return self.watchEvents(request, self.watchPolicy.BufferSize, self.watchPolicy.SlowWatcherPolicy)
+}
+
+func (self *events) watchEvents(request *Request, bufferSize int, slowWatcherPolicy SlowWatcherPolicy) (*EventChannel, error) {
 	if !request.StartTime.IsZero() || !request.EndTime.IsZero() {
 		return nil, errors.New(
 			"for a call to watch, request.StartTime and request.EndTime must be uninitialized")
@@ -271,32 +441,145 @@ func (self *events) WatchEvents(request *Request) (*EventChannel, error) {
 	self.watcherLock.Lock()
 	defer self.watcherLock.Unlock()
 	new_id := self.lastId + 1
-	returnEventChannel := NewEventChannel(new_id)
-	newWatcher := newWatch(request, returnEventChannel)
+	returnEventChannel := NewEventChannel(new_id, bufferSize)
+	newWatcher := newWatch(request, returnEventChannel, slowWatcherPolicy)
 	self.watchers[new_id] = newWatcher
 	self.lastId = new_id
 	return returnEventChannel, nil
 }
 
-// helper function to update the event manager's eventStore
+// helper function to update the event manager's eventStore, and its log
+// when persisted. eventsLock must be held.
 func (self *events) updateEventStore(e *info.Event) {
-	self.eventsLock.Lock()
-	defer self.eventsLock.Unlock()
+	if replayed := self.findReplayedEvent(e); replayed != nil {
+		e.Sequence = replayed.Sequence
+		return
+	}
+	self.lastSequence++
+	e.Sequence = self.lastSequence
+	if self.eventLog != nil {
+		// The event is still stored in memory when it can not be logged.
+		if err := self.eventLog.append(e); err != nil {
+			glog.Errorf("Failed to persist event %v: %v", e, err)
+		}
+	}
+	self.addToStore(e)
+	self.countEvent(e)
+
+	if self.eventLog != nil && self.eventLog.startCompaction(self.numStoredEvents()) {
+		stored, lastSequence := self.storedEvents(), self.lastSequence
+		self.compactions.Add(1)
+		go func() {
+			defer self.compactions.Done()
+			if err := self.eventLog.compact(stored, lastSequence); err != nil {
+				glog.Errorf("%v", err)
+			}
+		}()
+	}
+}
+
+// Returns the event replayed from the log the event is added again as, if any,
+// which is then not replayed anymore. eventsLock must be held.
+func (self *events) findReplayedEvent(e *info.Event) *info.Event {
+	if len(self.replayedEvents) == 0 {
+		return nil
+	}
+	now := time.Now()
+	if now.Sub(self.lastReplayExpiry) >= replayExpiryInterval {
+		self.expireReplayedEvents(now)
+	}
+	key := replayKey{e.ContainerName, e.EventType}
+	replayed := self.replayedEvents[key]
+	closest := -1
+	for i, r := range replayed {
+		if absDuration(r.Timestamp.Sub(e.Timestamp)) > replayTolerance {
+			continue
+		}
+		if closest < 0 || absDuration(r.Timestamp.Sub(e.Timestamp)) < absDuration(replayed[closest].Timestamp.Sub(e.Timestamp)) {
+			closest = i
+		}
+	}
+	if closest < 0 {
+		return nil
+	}
+	found := replayed[closest]
+	replayed = append(replayed[:closest], replayed[closest+1:]...)
+	if len(replayed) == 0 {
+		delete(self.replayedEvents, key)
+	} else {
+		self.replayedEvents[key] = replayed
+	}
+	return found
+}
+
+// Forgets the replayed events that are older than the events that are
+// replayed. eventsLock must be held.
+func (self *events) expireReplayedEvents(now time.Time) {
+	self.lastReplayExpiry = now
+	for key, replayed := range self.replayedEvents {
+		horizon := now.Add(-self.maxAge(key.eventType))
+		retained := replayed[:0]
+		for _, e := range replayed {
+			if !e.Timestamp.Before(horizon) {
+				retained = append(retained, e)
+			}
+		}
+		if len(retained) == 0 {
+			delete(self.replayedEvents, key)
+		} else {
+			self.replayedEvents[key] = retained
+		}
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func (self *events) maxAge(eventType info.EventType) time.Duration {
+	if age, ok := self.storagePolicy.PerTypeMaxAge[eventType]; ok {
+		return age
+	}
+	return self.storagePolicy.DefaultMaxAge
+}
+
+// Adds an event to the store of its type. eventsLock must be held.
+func (self *events) addToStore(e *info.Event) {
 	if _, ok := self.eventStore[e.EventType]; !ok {
-		maxAge := self.storagePolicy.DefaultMaxAge
 		maxNumEvents := self.storagePolicy.DefaultMaxNumEvents
-		if age, ok := self.storagePolicy.PerTypeMaxAge[e.EventType]; ok {
-			maxAge = age
-		}
 		if numEvents, ok := self.storagePolicy.PerTypeMaxNumEvents[e.EventType]; ok {
 			maxNumEvents = numEvents
 		}
 
-		self.eventStore[e.EventType] = utils.NewTimedStore(maxAge, maxNumEvents)
+		self.eventStore[e.EventType] = utils.NewTimedStore(self.maxAge(e.EventType), maxNumEvents)
 	}
 	self.eventStore[e.EventType].Add(e.Timestamp, e)
 }
 
+// Returns the number of stored events. eventsLock must be held.
+func (self *events) numStoredEvents() int {
+	n := 0
+	for _, store := range self.eventStore {
+		n += store.Size()
+	}
+	return n
+}
+
+// Returns the stored events in chronological order. eventsLock must be held.
+func (self *events) storedEvents() []*info.Event {
+	stored := make([]*info.Event, 0, self.numStoredEvents())
+	for _, store := range self.eventStore {
+		for _, in := range store.InTimeRange(time.Time{}, time.Time{}, -1) {
+			stored = append(stored, in.(*info.Event))
+		}
+	}
+	sort.Sort(byTimestamp(stored))
+	return stored
+}
+
 func (self *events) findValidWatchers(e *info.Event) []*watch {
 	watchesToSend := make([]*watch, 0)
 	for _, watcher := range self.watchers {
@@ -310,27 +593,59 @@ func (self *events) findValidWatchers(e *info.Event) []*watch {
 
 // method of Events object that adds the argument Event object to the
 // eventStore. It also feeds the event to a set of watch channels
-// held by the manager if it satisfies the request keys of the channels
+// held by the manager if it satisfies the request keys of the channels.
+// Watchers whose channel is full do not block the caller, their event is
+// dropped or they are disconnected, depending on the watch policy.
 func (self *events) AddEvent(e *info.Event) error {
-	self.updateEventStore(e)
-	self.watcherLock.RLock()
-	defer self.watcherLock.RUnlock()
-	watchesToSend := self.findValidWatchers(e)
-	for _, watchObject := range watchesToSend {
-		watchObject.eventChannel.GetChannel() <- e
+	slowWatchers := func() []int {
+		self.eventsLock.Lock()
+		defer self.eventsLock.Unlock()
+		self.updateEventStore(e)
+		// Sent before the next event is numbered, so that the watchers
+		// receive the events in the order of their sequence numbers.
+		return self.sendToWatchers(e)
+	}()
+	for _, watchId := range slowWatchers {
+		glog.Warningf("Disconnecting watcher %d, which does not keep up with the events", watchId)
+		self.StopWatch(watchId)
 	}
 	glog.V(4).Infof("Added event %v", e)
 	return nil
 }
 
+// Sends an event to the watchers it satisfies without blocking. Returns the
+// ids of the watchers to disconnect.
+func (self *events) sendToWatchers(e *info.Event) []int {
+	self.watcherLock.RLock()
+	defer self.watcherLock.RUnlock()
+	var slowWatchers []int
+	for _, watchObject := range self.findValidWatchers(e) {
+		eventChannel := watchObject.eventChannel
+		select {
+		case eventChannel.channel <- e:
+			continue
+		default:
+		}
+		dropped := atomic.AddUint64(&eventChannel.droppedEvents, 1)
+		if watchObject.slowWatcherPolicy == DisconnectWatcher {
+			slowWatchers = append(slowWatchers, eventChannel.watchId)
+		} else if dropped == 1 {
+			glog.Warningf("Watcher %d does not keep up with the events, dropping events", eventChannel.watchId)
+		}
+	}
+	return slowWatchers
+}
+
 // Removes a watch instance from the EventManager's watchers map
 func (self *events) StopWatch(watchId int) {
 	self.watcherLock.Lock()
 	defer self.watcherLock.Unlock()
-	_, ok := self.watchers[watchId]
+	watcher, ok := self.watchers[watchId]
 	if !ok {
-		glog.Errorf("Could not find watcher instance %v", watchId)
+		// The watch may have been disconnected already.
+		glog.V(2).Infof("Could not find watcher instance %v", watchId)
+		return
 	}
-	close(self.watchers[watchId].eventChannel.GetChannel())
+	close(watcher.eventChannel.GetChannel())
 	delete(self.watchers, watchId)
 }
@@ -15,11 +15,13 @@
 package events
 
 import (
+	"sync"
 	"testing"
 	"time"
 
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func createOldTime(t *testing.T) time.Time {
@@ -47,7 +49,7 @@ func initializeScenario(t *testing.T) (*events, *Request, *info.Event, *info.Eve
 	fakeEvent := makeEvent(createOldTime(t), "/")
 	fakeEvent2 := makeEvent(time.Now(), "/")
 
-	return NewEventManager(DefaultStoragePolicy()), NewRequest(), fakeEvent, fakeEvent2
+	return NewEventManager(DefaultStoragePolicy(), DefaultWatchPolicy()), NewRequest(), fakeEvent, fakeEvent2
 }
 
 func checkNumberOfEvents(t *testing.T, numEventsExpected int, numEventsReceived int) {
@@ -123,26 +125,14 @@ func TestWatchEventsDetectsNewEvents(t *testing.T) {
 	myEventHolder.AddEvent(fakeEvent)
 	myEventHolder.AddEvent(fakeEvent2)
 
-	startTime := time.Now()
-	go func() {
-		time.Sleep(5 * time.Second)
-		if time.Since(startTime) > (5 * time.Second) {
-			t.Errorf("Took too long to receive all the events")
+	for _, expectedEvent := range []*info.Event{fakeEvent, fakeEvent2} {
+		select {
+		case event := <-returnEventChannel.GetChannel():
+			ensureProperEventReturned(t, expectedEvent, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Took too long to receive all the events")
 		}
-	}()
-
-	eventsFound := 0
-	go func() {
-		for event := range returnEventChannel.GetChannel() {
-			eventsFound += 1
-			if eventsFound == 1 {
-				ensureProperEventReturned(t, fakeEvent, event)
-			} else if eventsFound == 2 {
-				ensureProperEventReturned(t, fakeEvent2, event)
-				break
-			}
-		}
-	}()
+	}
 }
 
 func TestAddEventAddsEventsToEventManager(t *testing.T) {
@@ -194,3 +184,183 @@ func TestGetEventsForNoTypeRequested(t *testing.T) {
 	assert.Nil(t, err)
 	checkNumberOfEvents(t, 0, len(receivedEvents))
 }
+
+func newTestEventManager(bufferSize int, slowWatcherPolicy SlowWatcherPolicy) *events {
+	return NewEventManager(DefaultStoragePolicy(), WatchPolicy{
+		BufferSize:        bufferSize,
+		SlowWatcherPolicy: slowWatcherPolicy,
+	})
+}
+
+func newRequestFor(eventType info.EventType) *Request {
+	request := NewRequest()
+	request.EventType[eventType] = true
+	return request
+}
+
+func TestAddEventDropsEventsOfSlowWatcher(t *testing.T) {
+	myEventHolder := newTestEventManager(2, DropEvents)
+	slowChannel, err := myEventHolder.WatchEvents(newRequestFor(info.EventOom))
+	assert.Nil(t, err)
+
+	fakeEvents := []*info.Event{}
+	for i := 0; i < 5; i++ {
+		fakeEvents = append(fakeEvents, makeEvent(time.Now(), "/"))
+		assert.Nil(t, myEventHolder.AddEvent(fakeEvents[i]))
+	}
+
+	assert.Equal(t, uint64(3), slowChannel.GetDroppedEvents())
+	ensureProperEventReturned(t, fakeEvents[0], <-slowChannel.GetChannel())
+	ensureProperEventReturned(t, fakeEvents[1], <-slowChannel.GetChannel())
+
+	// The watcher receives events again once it caught up.
+	assert.Nil(t, myEventHolder.AddEvent(fakeEvents[4]))
+	ensureProperEventReturned(t, fakeEvents[4], <-slowChannel.GetChannel())
+	assert.Equal(t, uint64(3), slowChannel.GetDroppedEvents())
+
+	// All the events are stored.
+	request := newRequestFor(info.EventOom)
+	request.MaxEventsReturned = -1
+	storedEvents, err := myEventHolder.GetEvents(request)
+	assert.Nil(t, err)
+	checkNumberOfEvents(t, 6, len(storedEvents))
+}
+
+func TestAddEventDisconnectsSlowWatcher(t *testing.T) {
+	myEventHolder := newTestEventManager(1, DisconnectWatcher)
+	slowChannel, err := myEventHolder.WatchEvents(newRequestFor(info.EventOom))
+	assert.Nil(t, err)
+	fastChannel, err := myEventHolder.WatchEvents(newRequestFor(info.EventOom))
+	assert.Nil(t, err)
+
+	fakeEvent := makeEvent(time.Now(), "/")
+	fakeEvent2 := makeEvent(time.Now(), "/")
+	assert.Nil(t, myEventHolder.AddEvent(fakeEvent))
+	ensureProperEventReturned(t, fakeEvent, <-fastChannel.GetChannel())
+	assert.Nil(t, myEventHolder.AddEvent(fakeEvent2))
+
+	// The slow watcher received the events that fit and was closed.
+	ensureProperEventReturned(t, fakeEvent, <-slowChannel.GetChannel())
+	_, ok := <-slowChannel.GetChannel()
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), slowChannel.GetDroppedEvents())
+	ensureProperEventReturned(t, fakeEvent2, <-fastChannel.GetChannel())
+
+	checkNumberOfEvents(t, 1, len(myEventHolder.watchers))
+	_, ok = myEventHolder.watchers[fastChannel.GetWatchId()]
+	assert.True(t, ok)
+
+	// Stopping a disconnected watch is a no-op.
+	myEventHolder.StopWatch(slowChannel.GetWatchId())
+	checkNumberOfEvents(t, 1, len(myEventHolder.watchers))
+}
+
+func TestStopWatchUnknownWatch(t *testing.T) {
+	myEventHolder := newTestEventManager(1, DropEvents)
+	myEventHolder.StopWatch(42)
+	checkNumberOfEvents(t, 0, len(myEventHolder.watchers))
+}
+
+// Receives up to the specified number of events, until the channel is closed
+// or no event arrives for a while.
+func receiveEvents(eventChannel *EventChannel, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case _, ok := <-eventChannel.GetChannel():
+			if !ok {
+				return
+			}
+		case <-time.After(10 * time.Millisecond):
+			return
+		}
+	}
+}
+
+// Events are added while watchers come and go, some of which do not receive
+// their events. Run with -race.
+func TestAddEventWithConcurrentWatchers(t *testing.T) {
+	for _, policy := range []SlowWatcherPolicy{DropEvents, DisconnectWatcher} {
+		myEventHolder := newTestEventManager(4, policy)
+		const numAdders = 4
+		const numEventsPerAdder = 200
+		var adders sync.WaitGroup
+		for i := 0; i < numAdders; i++ {
+			adders.Add(1)
+			go func() {
+				defer adders.Done()
+				for j := 0; j < numEventsPerAdder; j++ {
+					myEventHolder.AddEvent(makeEvent(time.Now(), "/"))
+				}
+			}()
+		}
+
+		var watchers sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			watchers.Add(1)
+			go func(i int) {
+				defer watchers.Done()
+				eventChannel, err := myEventHolder.WatchEvents(newRequestFor(info.EventOom))
+				assert.Nil(t, err)
+				if i%2 == 0 {
+					// A watcher that never receives its events.
+					time.Sleep(10 * time.Millisecond)
+				} else {
+					receiveEvents(eventChannel, 10)
+				}
+				eventChannel.GetDroppedEvents()
+				myEventHolder.StopWatch(eventChannel.GetWatchId())
+			}(i)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			adders.Wait()
+			watchers.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("adding events blocked with %q policy", policy)
+		}
+		checkNumberOfEvents(t, 0, len(myEventHolder.watchers))
+	}
+}
+
+// Events added concurrently reach a watcher in the order of their sequence
+// numbers.
+func TestWatchersReceiveEventsInSequenceOrder(t *testing.T) {
+	const numAdders = 4
+	const numEventsPerAdder = 100
+	myEventHolder := newTestEventManager(numAdders*numEventsPerAdder, DropEvents)
+	eventChannel, err := myEventHolder.WatchEvents(newRequestFor(info.EventOom))
+	require.NoError(t, err)
+
+	var adders sync.WaitGroup
+	for i := 0; i < numAdders; i++ {
+		adders.Add(1)
+		go func() {
+			defer adders.Done()
+			for j := 0; j < numEventsPerAdder; j++ {
+				myEventHolder.AddEvent(makeEvent(time.Now(), "/"))
+			}
+		}()
+	}
+	adders.Wait()
+
+	var last uint64
+	for i := 0; i < numAdders*numEventsPerAdder; i++ {
+		e := <-eventChannel.GetChannel()
+		assert.True(t, e.Sequence > last, "sequence %d received after %d", e.Sequence, last)
+		last = e.Sequence
+	}
+	assert.Equal(t, uint64(0), eventChannel.GetDroppedEvents())
+}
+
+func TestAddEventAssignsSequenceNumbers(t *testing.T) {
+	myEventHolder, _, fakeEvent, fakeEvent2 := initializeScenario(t)
+	require.NoError(t, myEventHolder.AddEvent(fakeEvent))
+	require.NoError(t, myEventHolder.AddEvent(fakeEvent2))
+	assert.Equal(t, uint64(1), fakeEvent.Sequence)
+	assert.Equal(t, uint64(2), fakeEvent2.Sequence)
+}
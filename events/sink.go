@@ -0,0 +1,51 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Number of events buffered for a sink while it is busy.
+const sinkBufferSize = 1000
+
+// A destination events are pushed to, e.g.: a webhook or a storage driver.
+type Sink interface {
+	// Pushes an event. Retries, if any, are up to the sink.
+	AddEvent(e *info.Event) error
+}
+
+// Pushes the events that satisfy the request to the sink from a goroutine,
+// so that a slow sink does not delay the events. Events that arrive while
+// the buffer of the sink is full are dropped. The sink is removed by stopping
+// the returned watch id.
+func (self *events) AddSink(request *Request, sink Sink) (int, error) {
+	eventChannel, err := self.watchEvents(request, sinkBufferSize, DropEvents)
+	if err != nil {
+		return 0, err
+	}
+	go func() {
+		for e := range eventChannel.GetChannel() {
+			if err := sink.AddEvent(e); err != nil {
+				glog.Errorf("Failed to push event %v to sink %d: %v", e, eventChannel.GetWatchId(), err)
+			}
+		}
+		if dropped := eventChannel.GetDroppedEvents(); dropped > 0 {
+			glog.Warningf("Sink %d dropped %d events", eventChannel.GetWatchId(), dropped)
+		}
+	}()
+	return eventChannel.GetWatchId(), nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A sink that sends the events it receives over a channel.
+type channelSink chan *info.Event
+
+func (self channelSink) AddEvent(e *info.Event) error {
+	self <- e
+	return nil
+}
+
+func receiveSinkEvent(t *testing.T, sink channelSink) *info.Event {
+	select {
+	case e := <-sink:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the sink did not receive an event")
+	}
+	return nil
+}
+
+func TestAddSink(t *testing.T) {
+	myEventHolder := newTestEventManager(1, DisconnectWatcher)
+	request := newRequestFor(info.EventOomKill)
+	request.ContainerName = "/docker"
+	request.IncludeSubcontainers = true
+	sink := make(channelSink)
+	watchId, err := myEventHolder.AddSink(request, sink)
+	require.NoError(t, err)
+
+	oomKill := &info.Event{ContainerName: "/docker/abc", Timestamp: time.Now(), EventType: info.EventOomKill}
+	otherType := &info.Event{ContainerName: "/docker/abc", Timestamp: time.Now(), EventType: info.EventOom}
+	otherContainer := &info.Event{ContainerName: "/system.slice", Timestamp: time.Now(), EventType: info.EventOomKill}
+	for _, e := range []*info.Event{otherType, otherContainer, oomKill, oomKill} {
+		require.NoError(t, myEventHolder.AddEvent(e))
+	}
+	// Sinks are not disconnected by the watch policy while they are busy.
+	ensureProperEventReturned(t, oomKill, receiveSinkEvent(t, sink))
+	ensureProperEventReturned(t, oomKill, receiveSinkEvent(t, sink))
+
+	myEventHolder.StopWatch(watchId)
+	require.NoError(t, myEventHolder.AddEvent(oomKill))
+	select {
+	case e := <-sink:
+		t.Errorf("a stopped sink received event %v", e)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var lock sync.Mutex
+	received := []*info.Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := &info.Event{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(e))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, e)
+	}))
+	defer server.Close()
+
+	e := &info.Event{
+		ContainerName: "/docker/abc",
+		Timestamp:     time.Now().Truncate(time.Second),
+		EventType:     info.EventOomKill,
+		EventData: info.EventData{
+			OomKill: &info.OomKillEventData{Pid: 42, ProcessName: "stress"},
+		},
+	}
+	sink := NewWebhookSink(server.URL, time.Second, 0, time.Millisecond)
+	require.NoError(t, sink.AddEvent(e))
+	require.Equal(t, 1, len(received))
+	assert.Equal(t, e.ContainerName, received[0].ContainerName)
+	assert.True(t, e.Timestamp.Equal(received[0].Timestamp))
+	assert.Equal(t, e.EventData, received[0].EventData)
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	for _, test := range []struct {
+		statuses   []int
+		maxRetries int
+		posts      int
+		success    bool
+	}{
+		// Server errors are retried.
+		{[]int{500, 503, 200}, 3, 3, true},
+		{[]int{500, 500, 500, 500}, 2, 3, false},
+		{[]int{429, 204}, 1, 2, true},
+		// Client errors are not.
+		{[]int{400, 200}, 3, 1, false},
+	} {
+		posts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.statuses[posts])
+			posts++
+		}))
+
+		sink := NewWebhookSink(server.URL, time.Second, test.maxRetries, time.Millisecond)
+		err := sink.AddEvent(&info.Event{EventType: info.EventOom})
+		assert.Equal(t, test.success, err == nil, "statuses %v: %v", test.statuses, err)
+		assert.Equal(t, test.posts, posts, "statuses %v", test.statuses)
+		server.Close()
+	}
+}
+
+func TestWebhookSinkConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	sink := NewWebhookSink(url, time.Second, 1, time.Millisecond)
+	assert.Error(t, sink.AddEvent(&info.Event{EventType: info.EventOom}))
+}
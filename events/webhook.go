@@ -0,0 +1,88 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// A sink that posts each event as JSON to a URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+	// Number of times a failed post is retried.
+	maxRetries int
+	// Delay before the first retry, which doubles with every retry.
+	retryInterval time.Duration
+}
+
+// Returns a sink that posts the events to the URL. Posts that fail with a
+// connection error or a server error are retried up to maxRetries times.
+func NewWebhookSink(url string, timeout time.Duration, maxRetries int, retryInterval time.Duration) Sink {
+	return &webhookSink{
+		url: url,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+		maxRetries:    maxRetries,
+		retryInterval: retryInterval,
+	}
+}
+
+func (self *webhookSink) AddEvent(e *info.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	retryInterval := self.retryInterval
+	for retry := 0; ; retry++ {
+		retriable, err := self.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retriable || retry >= self.maxRetries {
+			return err
+		}
+		glog.V(2).Infof("Retrying to post event to %q in %v: %v", self.url, retryInterval, err)
+		time.Sleep(retryInterval)
+		retryInterval *= 2
+	}
+}
+
+// Posts an event, returning whether a failure may be retried.
+func (self *webhookSink) post(body []byte) (bool, error) {
+	resp, err := self.client.Post(self.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, fmt.Errorf("failed to post event to %q: %v", self.url, err)
+	}
+	// Read the body so that the connection is reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("failed to post event to %q: %s", self.url, resp.Status)
+	// 429 is Too Many Requests, which net/http names only since Go 1.6.
+	return resp.StatusCode >= 500 || resp.StatusCode == 429, err
+}
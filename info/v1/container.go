@@ -37,6 +37,11 @@ type MemorySpec struct {
 	// The amount of swap space requested. Default is unlimited (-1).
 	// Units: bytes.
 	SwapLimit uint64 `json:"swap_limit,omitempty"`
+
+	// The amount of huge pages requested per page size (e.g.: "2MB").
+	// Default is unlimited (-1).
+	// Units: bytes.
+	HugetlbLimits map[string]uint64 `json:"hugetlb_limits,omitempty"`
 }
 
 type ContainerSpec struct {
@@ -59,10 +64,23 @@ type ContainerSpec struct {
 	// HasDiskIo when true, indicates that DiskIo stats will be available.
 	HasDiskIo bool `json:"has_diskio"`
 
+	HasProcesses bool        `json:"has_processes"`
+	Processes    ProcessSpec `json:"processes,omitempty"`
+
+	// HasPressure when true, indicates that pressure stall information will be
+	// available, which needs a kernel with PSI.
+	HasPressure bool `json:"has_pressure"`
+
 	HasCustomMetrics bool         `json:"has_custom_metrics"`
 	CustomMetrics    []MetricSpec `json:"custom_metrics,omitempty"`
 }
 
+type ProcessSpec struct {
+	// The maximum number of tasks (processes and threads) in the pids cgroup.
+	// Unlimited is the maximum uint64 value, and unknown is 0.
+	Limit uint64 `json:"limit,omitempty"`
+}
+
 // Container reference contains enough information to uniquely identify a container
 type ContainerReference struct {
 	// The absolute name of the container. This is unique on the machine.
@@ -193,6 +211,15 @@ func (self *ContainerSpec) Eq(b *ContainerSpec) bool {
 	if self.HasDiskIo != b.HasDiskIo {
 		return false
 	}
+	if self.HasProcesses != b.HasProcesses {
+		return false
+	}
+	if self.Processes != b.Processes {
+		return false
+	}
+	if self.HasPressure != b.HasPressure {
+		return false
+	}
 	if self.HasCustomMetrics != b.HasCustomMetrics {
 		return false
 	}
@@ -271,14 +298,56 @@ type CpuUsage struct {
 	System uint64 `json:"system"`
 }
 
+// CFS throttling stats of a container with a CPU quota. All are cumulative
+// from the creation of the container.
+type CpuCFS struct {
+	// Number of elapsed enforcement periods.
+	Periods uint64 `json:"periods"`
+
+	// Number of periods in which the container was throttled.
+	ThrottledPeriods uint64 `json:"throttled_periods"`
+
+	// Total time the container was throttled for.
+	// Unit: nanoseconds.
+	ThrottledTime uint64 `json:"throttled_time"`
+}
+
+// Scheduler stats of the tasks of a container, from /proc/<pid>/schedstat.
+// These are summed over the current tasks, so they drop when tasks exit.
+type CpuSchedstat struct {
+	// Time spent running on a CPU.
+	// Unit: nanoseconds.
+	RunTime uint64 `json:"run_time"`
+
+	// Time spent runnable and waiting for a CPU.
+	// Unit: nanoseconds.
+	RunqueueTime uint64 `json:"runqueue_time"`
+
+	// Number of times tasks were scheduled on a CPU.
+	RunPeriods uint64 `json:"run_periods"`
+}
+
+// Load averages of a container, as in /proc/loadavg: the exponentially
+// decayed average number of runnable and uninterruptible tasks.
+type CpuLoadAverages struct {
+	OneMinute      float64 `json:"one_minute"`
+	FiveMinutes    float64 `json:"five_minutes"`
+	FifteenMinutes float64 `json:"fifteen_minutes"`
+}
+
 // All CPU usage metrics are cumulative from the creation of the container
 type CpuStats struct {
 	Usage CpuUsage `json:"usage"`
-	// Smoothed average of number of runnable threads x 1000.
+	CFS   CpuCFS   `json:"cfs"`
+	// Scheduler run-queue delay of the tasks of the container.
+	Schedstat CpuSchedstat `json:"schedstat"`
+	// One minute load average x 1000.
 	// We multiply by thousand to avoid using floats, but preserving precision.
-	// Load is smoothed over the last 10 seconds. Instantaneous value can be read
-	// from LoadStats.NrRunning.
+	// Instantaneous values can be read from LoadStats.
 	LoadAverage int32 `json:"load_average"`
+	// Load averages over 1, 5 and 15 minutes. Only set when the load reader
+	// is enabled.
+	LoadAverages *CpuLoadAverages `json:"load_averages,omitempty"`
 }
 
 type PerDiskStats struct {
@@ -309,8 +378,86 @@ type MemoryStats struct {
 	// Units: Bytes.
 	WorkingSet uint64 `json:"working_set"`
 
+	// Maximum memory usage recorded.
+	// Units: Bytes.
+	MaxUsage uint64 `json:"max_usage"`
+
+	// Number of times memory usage hit the limit.
+	Failcnt uint64 `json:"failcnt"`
+
+	// Breakdown of the memory usage as reported by memory.stat, including
+	// that of the descendants of the container.
+	// Units: Bytes.
+	Cache        uint64 `json:"cache"`
+	RSS          uint64 `json:"rss"`
+	RSSHuge      uint64 `json:"rss_huge"`
+	MappedFile   uint64 `json:"mapped_file"`
+	Swap         uint64 `json:"swap"`
+	Writeback    uint64 `json:"writeback"`
+	ActiveFile   uint64 `json:"active_file"`
+	InactiveFile uint64 `json:"inactive_file"`
+
+	// Kernel memory (e.g.: slab, stacks and page tables) charged to the
+	// container, which is included in the usage.
+	// Units: Bytes.
+	KernelUsage uint64 `json:"kernel_usage"`
+
 	ContainerData    MemoryStatsMemoryData `json:"container_data,omitempty"`
 	HierarchicalData MemoryStatsMemoryData `json:"hierarchical_data,omitempty"`
+
+	// Memory usage per NUMA node, as in MachineInfo.Topology.
+	NumaNodes []NodeMemoryStats `json:"numa_nodes,omitempty"`
+}
+
+// Memory usage of a container on a NUMA node.
+// Units: Bytes.
+type NodeMemoryStats struct {
+	Node        int    `json:"node_id"`
+	Total       uint64 `json:"total"`
+	File        uint64 `json:"file"`
+	Anon        uint64 `json:"anon"`
+	Unevictable uint64 `json:"unevictable"`
+}
+
+type HugetlbStats struct {
+	// Current usage of huge pages.
+	// Units: Bytes.
+	Usage uint64 `json:"usage"`
+
+	// Maximum usage of huge pages recorded.
+	// Units: Bytes.
+	MaxUsage uint64 `json:"max_usage,omitempty"`
+
+	// Number of allocations of huge pages that failed because of the limit.
+	Failcnt uint64 `json:"failcnt"`
+}
+
+// Pressure stall information of a resource over the last 10, 60 and 300
+// seconds, as a percentage of wall time, and in total.
+type PSIData struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+
+	// Total stall time.
+	// Units: microseconds.
+	Total uint64 `json:"total"`
+}
+
+type PSIStats struct {
+	// Time some tasks were stalled on the resource.
+	Some PSIData `json:"some"`
+
+	// Time all non-idle tasks were stalled on the resource at the same time.
+	Full PSIData `json:"full"`
+}
+
+// Pressure stall information (PSI) of kernels that support it. Those of the
+// root container are the ones of the whole host.
+type PressureStats struct {
+	Cpu    PSIStats `json:"cpu"`
+	Memory PSIStats `json:"memory"`
+	Io     PSIStats `json:"io"`
 }
 
 type MemoryStatsMemoryData struct {
@@ -342,6 +489,91 @@ type InterfaceStats struct {
 type NetworkStats struct {
 	InterfaceStats `json:",inline"`
 	Interfaces     []InterfaceStats `json:"interfaces,omitempty"`
+	// TCP connection stats (Established, Listen...)
+	Tcp TcpStat `json:"tcp"`
+	// TCP6 connection stats (Established, Listen...)
+	Tcp6 TcpStat `json:"tcp6"`
+	// UDP socket stats
+	Udp UdpStat `json:"udp"`
+	// UDP6 socket stats
+	Udp6 UdpStat `json:"udp6"`
+	// TCP and UDP counters of the network namespace (retransmits, resets...)
+	Protocol ProtocolStat `json:"protocol"`
+}
+
+// Number of TCP sockets in each state.
+type TcpStat struct {
+	Established uint64 `json:"established"`
+	SynSent     uint64 `json:"syn_sent"`
+	SynRecv     uint64 `json:"syn_recv"`
+	FinWait1    uint64 `json:"fin_wait1"`
+	FinWait2    uint64 `json:"fin_wait2"`
+	TimeWait    uint64 `json:"time_wait"`
+	Close       uint64 `json:"close"`
+	CloseWait   uint64 `json:"close_wait"`
+	LastAck     uint64 `json:"last_ack"`
+	Listen      uint64 `json:"listen"`
+	Closing     uint64 `json:"closing"`
+}
+
+type UdpStat struct {
+	// Number of sockets not connected to a remote address.
+	Listen uint64 `json:"listen"`
+
+	// Number of datagrams dropped by the sockets currently open. This is a
+	// gauge, not a counter: it decreases when a socket that dropped datagrams
+	// is closed.
+	Dropped uint64 `json:"dropped"`
+
+	// Bytes queued for receiving, summed over all sockets.
+	RxQueued uint64 `json:"rx_queued"`
+
+	// Bytes queued for sending, summed over all sockets.
+	TxQueued uint64 `json:"tx_queued"`
+}
+
+// Cumulative TCP and UDP counters of a network namespace, as reported by
+// /proc/net/snmp, /proc/net/netstat and /proc/net/snmp6. The TCP counters are
+// for IPv4 and IPv6, the Udp counters for IPv4 and the Udp6 counters for IPv6.
+type ProtocolStat struct {
+	// Number of TCP segments retransmitted.
+	TcpRetransSegs uint64 `json:"tcp_retrans_segs"`
+
+	// Number of TCP segments sent with the RST flag.
+	TcpOutRsts uint64 `json:"tcp_out_rsts"`
+
+	// Number of established TCP connections that were reset.
+	TcpEstabResets uint64 `json:"tcp_estab_resets"`
+
+	// Number of failed TCP connection attempts.
+	TcpAttemptFails uint64 `json:"tcp_attempt_fails"`
+
+	// Number of TCP retransmission timeouts.
+	TcpTimeouts uint64 `json:"tcp_timeouts"`
+
+	// Number of times the accept queue of a listening socket overflowed.
+	TcpListenOverflows uint64 `json:"tcp_listen_overflows"`
+
+	// Number of incoming TCP connections dropped by listening sockets.
+	TcpListenDrops uint64 `json:"tcp_listen_drops"`
+
+	// Number of UDP datagrams that could not be received, other than for a missing port.
+	UdpInErrors uint64 `json:"udp_in_errors"`
+
+	// Number of UDP datagrams dropped because the receive buffer was full.
+	UdpRcvbufErrors uint64 `json:"udp_rcvbuf_errors"`
+
+	// Number of UDP datagrams dropped because the send buffer was full.
+	UdpSndbufErrors uint64 `json:"udp_sndbuf_errors"`
+
+	// Number of UDP datagrams over IPv6 that could not be received, other than for a missing port.
+	Udp6InErrors uint64 `json:"udp6_in_errors"`
+
+	// Number of UDP datagrams over IPv6 dropped because the receive buffer was full.
+	Udp6RcvbufErrors uint64 `json:"udp6_rcvbuf_errors"`
+
+	// Number of UDP datagrams over IPv6 dropped because the send buffer was full.
+	Udp6SndbufErrors uint64 `json:"udp6_sndbuf_errors"`
 }
 
 type FsStats struct {
@@ -412,6 +644,17 @@ type FsStats struct {
 	WeightedIoTime uint64 `json:"weighted_io_time"`
 }
 
+type ProcessStats struct {
+	// Number of tasks (processes and threads) accounted in the pids cgroup.
+	Pids uint64 `json:"pids"`
+
+	// Number of threads of the processes in the container.
+	ThreadCount uint64 `json:"thread_count"`
+
+	// Number of file descriptors opened by the processes in the container.
+	FdCount uint64 `json:"fd_count"`
+}
+
 type ContainerStats struct {
 	// The time of this stat point.
 	Timestamp time.Time    `json:"timestamp"`
@@ -426,6 +669,15 @@ type ContainerStats struct {
 	// Task load stats
 	TaskStats LoadStats `json:"task_stats,omitempty"`
 
+	// Process stats
+	Processes ProcessStats `json:"processes,omitempty"`
+
+	// Huge page stats per page size (e.g.: "2MB")
+	Hugetlb map[string]HugetlbStats `json:"hugetlb,omitempty"`
+
+	// Pressure stall information, if the spec has pressure.
+	Pressure PressureStats `json:"pressure"`
+
 	//Custom metrics from all collectors
 	CustomMetrics map[string][]MetricVal `json:"custom_metrics,omitempty"`
 }
@@ -512,6 +764,18 @@ type Event struct {
 	// the original event object and all of its extraneous data, ex. an
 	// OomInstance
 	EventData EventData `json:"event_data,omitempty"`
+
+	// the labels, aliases and namespace of the container when the event
+	// occurred, by which events may be filtered. Unset when the container
+	// was not known yet
+	ContainerLabels    map[string]string `json:"container_labels,omitempty"`
+	ContainerAliases   []string          `json:"container_aliases,omitempty"`
+	ContainerNamespace string            `json:"container_namespace,omitempty"`
+
+	// position of the event among the events cAdvisor detected, which
+	// increases with every event, also across restarts. Set when the event
+	// is added
+	Sequence uint64 `json:"sequence,omitempty"`
 }
 
 // EventType is an enumerated type which lists the categories under which
@@ -523,12 +787,41 @@ const (
 	EventOomKill                     = "oomKill"
 	EventContainerCreation           = "containerCreation"
 	EventContainerDeletion           = "containerDeletion"
+
+	// Lifecycle events reported by the container runtime.
+	EventContainerStart        = "containerStart"
+	EventContainerStop         = "containerStop"
+	EventContainerExit         = "containerExit"
+	EventContainerRestart      = "containerRestart"
+	EventContainerPause        = "containerPause"
+	EventContainerUnpause      = "containerUnpause"
+	EventContainerHealthStatus = "containerHealthStatus"
+
+	// An alerting rule started or stopped firing for a container.
+	EventAlert = "alert"
+
+	// A container approached or hit one of its resource limits.
+	EventMemoryLimitApproached = "memoryLimitApproached"
+	EventCpuThrottled          = "cpuThrottled"
+	EventFsNearlyFull          = "fsNearlyFull"
 )
 
 // Extra information about an event. Only one type will be set.
 type EventData struct {
 	// Information about an OOM kill event.
 	OomKill *OomKillEventData `json:"oom,omitempty"`
+
+	// Information about a container exit event.
+	ContainerExit *ContainerExitEventData `json:"container_exit,omitempty"`
+
+	// Information about a container health status event.
+	ContainerHealth *ContainerHealthEventData `json:"container_health,omitempty"`
+
+	// Information about an alert event.
+	Alert *AlertEventData `json:"alert,omitempty"`
+
+	// Information about a container approaching a resource limit.
+	ResourceLimit *ResourceLimitEventData `json:"resource_limit,omitempty"`
 }
 
 // Information related to an OOM kill instance
@@ -538,4 +831,87 @@ type OomKillEventData struct {
 
 	// The name of the killed process
 	ProcessName string `json:"process_name"`
+
+	// Memory usage, limit and failure count of the container whose limit was
+	// hit, in bytes. Not reported for system OOMs.
+	MemoryUsage   uint64 `json:"memory_usage,omitempty"`
+	MemoryLimit   uint64 `json:"memory_limit,omitempty"`
+	MemoryFailcnt uint64 `json:"memory_failcnt,omitempty"`
+
+	// The gfp mask and order of the allocation that triggered the OOM.
+	GfpMask string `json:"gfp_mask,omitempty"`
+	Order   int    `json:"order"`
+
+	// The tasks that were considered for the OOM kill.
+	Tasks []OomTask `json:"tasks,omitempty"`
+}
+
+// A task of the OOM report of the kernel.
+type OomTask struct {
+	Pid  int `json:"pid"`
+	Uid  int `json:"uid"`
+	Tgid int `json:"tgid"`
+
+	// Virtual memory size and resident set size of the task, in bytes.
+	TotalVm uint64 `json:"total_vm"`
+	Rss     uint64 `json:"rss"`
+
+	OomScoreAdj int    `json:"oom_score_adj"`
+	Name        string `json:"name"`
+}
+
+// Information related to the exit of a container's main process.
+type ContainerExitEventData struct {
+	// Exit code of the container's main process.
+	ExitCode int `json:"exit_code"`
+
+	// Whether the container was killed for running out of memory.
+	OomKilled bool `json:"oom_killed"`
+
+	// Error reported by the container runtime, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Information related to a change in the health of a container.
+type ContainerHealthEventData struct {
+	// The new health status (e.g.: healthy, unhealthy).
+	Status string `json:"status"`
+}
+
+// Information related to a container approaching or hitting a resource limit.
+type ResourceLimitEventData struct {
+	// Usage and limit of the resource: the working set and limit of the
+	// memory or the usage and capacity of a filesystem in bytes, or the
+	// throttled and total CFS periods since the previous sample.
+	Usage uint64 `json:"usage"`
+	Limit uint64 `json:"limit"`
+
+	// Ratio of the usage to the limit, and the threshold it reached.
+	Ratio     float64 `json:"ratio"`
+	Threshold float64 `json:"threshold"`
+
+	// Number of times the memory limit was hit since the previous sample.
+	FailcntIncrease uint64 `json:"failcnt_increase,omitempty"`
+
+	// Device of the filesystem.
+	Device string `json:"device,omitempty"`
+}
+
+// Information related to an alerting rule starting or stopping to fire.
+type AlertEventData struct {
+	// Name of the rule.
+	Rule string `json:"rule"`
+
+	// The condition of the rule, e.g.: "memory_working_set / memory_limit > 0.9".
+	Expression string `json:"expression"`
+
+	// The new state of the alert: firing or resolved.
+	State string `json:"state"`
+
+	// Value of the left side of the condition when the state changed. Not
+	// reported when the condition could not be evaluated anymore.
+	Value float64 `json:"value"`
+
+	// Time since which the condition held.
+	ActiveSince time.Time `json:"active_since"`
 }
@@ -50,6 +50,11 @@ type MemorySpec struct {
 	// The amount of swap space requested. Default is unlimited (-1).
 	// Units: bytes.
 	SwapLimit uint64 `json:"swap_limit,omitempty"`
+
+	// The amount of huge pages requested per page size (e.g.: "2MB").
+	// Default is unlimited (-1).
+	// Units: bytes.
+	HugetlbLimits map[string]uint64 `json:"hugetlb_limits,omitempty"`
 }
 
 type ContainerSpec struct {
@@ -73,6 +78,9 @@ type ContainerSpec struct {
 	HasMemory bool       `json:"has_memory"`
 	Memory    MemorySpec `json:"memory,omitempty"`
 
+	HasProcesses bool           `json:"has_processes"`
+	Processes    v1.ProcessSpec `json:"processes,omitempty"`
+
 	HasCustomMetrics bool            `json:"has_custom_metrics"`
 	CustomMetrics    []v1.MetricSpec `json:"custom_metrics,omitempty"`
 
@@ -80,6 +88,7 @@ type ContainerSpec struct {
 	HasNetwork    bool `json:"has_network"`
 	HasFilesystem bool `json:"has_filesystem"`
 	HasDiskIo     bool `json:"has_diskio"`
+	HasPressure   bool `json:"has_pressure"`
 }
 
 type ContainerStats struct {
@@ -94,6 +103,8 @@ type ContainerStats struct {
 	// Memory statistics
 	HasMemory bool           `json:"has_memory"`
 	Memory    v1.MemoryStats `json:"memory,omitempty"`
+	// Huge page statistics per page size
+	Hugetlb map[string]v1.HugetlbStats `json:"hugetlb,omitempty"`
 	// Network statistics
 	HasNetwork bool         `json:"has_network"`
 	Network    NetworkStats `json:"network,omitempty"`
@@ -103,6 +114,12 @@ type ContainerStats struct {
 	// Task load statistics
 	HasLoad bool         `json:"has_load"`
 	Load    v1.LoadStats `json:"load_stats,omitempty"`
+	// Process statistics
+	HasProcesses bool            `json:"has_processes"`
+	Processes    v1.ProcessStats `json:"processes,omitempty"`
+	// Pressure stall information
+	HasPressure bool             `json:"has_pressure"`
+	Pressure    v1.PressureStats `json:"pressure,omitempty"`
 	// Custom Metrics
 	HasCustomMetrics bool                      `json:"has_custom_metrics"`
 	CustomMetrics    map[string][]v1.MetricVal `json:"custom_metrics,omitempty"`
@@ -133,6 +150,8 @@ type Usage struct {
 	Cpu Percentiles `json:"cpu"`
 	// Mean, Max, and 90p memory size in bytes.
 	Memory Percentiles `json:"memory"`
+	// Mean, Max, and 90p time tasks waited for a cpu in milliseconds/second.
+	CpuRunqueueDelay Percentiles `json:"cpu_runqueue_delay"`
 }
 
 // latest sample collected for a container.
@@ -141,6 +160,8 @@ type InstantUsage struct {
 	Cpu uint64 `json:"cpu"`
 	// Memory usage in bytes.
 	Memory uint64 `json:"memory"`
+	// Time tasks waited for a cpu in milliseconds/second.
+	CpuRunqueueDelay uint64 `json:"cpu_runqueue_delay"`
 }
 
 type DerivedStats struct {
@@ -203,4 +224,46 @@ type ProcessInfo struct {
 type NetworkStats struct {
 	// Network stats by interface.
 	Interfaces []v1.InterfaceStats `json:"interfaces,omitempty"`
+	// TCP connection stats (Established, Listen...)
+	Tcp v1.TcpStat `json:"tcp"`
+	// TCP6 connection stats (Established, Listen...)
+	Tcp6 v1.TcpStat `json:"tcp6"`
+	// UDP socket stats
+	Udp v1.UdpStat `json:"udp"`
+	// UDP6 socket stats
+	Udp6 v1.UdpStat `json:"udp6"`
+	// TCP and UDP counters of the network namespace (retransmits, resets...)
+	Protocol v1.ProtocolStat `json:"protocol"`
+}
+
+// An alerting rule whose condition holds for a container.
+type Alert struct {
+	// Name of the rule.
+	Rule string `json:"rule"`
+	// Absolute name of the container.
+	ContainerName string `json:"container_name"`
+	// The condition of the rule.
+	Expression string `json:"expression"`
+	// "pending" until the condition held for the duration of the rule, then "firing".
+	State string `json:"state"`
+	// Value of the left side of the condition at the last evaluation.
+	Value float64 `json:"value"`
+	// Time since which the condition holds.
+	ActiveSince time.Time `json:"active_since"`
+	// Time since which the alert fires.
+	FiringSince time.Time `json:"firing_since,omitempty"`
+}
+
+// Number of events of a group, in a time bucket when the events are bucketed.
+type EventCount struct {
+	// Type of the events, when grouped by type.
+	EventType v1.EventType `json:"event_type,omitempty"`
+	// Absolute name of the container of the events, when grouped by container.
+	ContainerName string `json:"container_name,omitempty"`
+	// Value of the label of the container of the events, when grouped by a
+	// label. Empty for the containers without the label.
+	LabelValue string `json:"label_value,omitempty"`
+	// Start of the time bucket, when the events are bucketed.
+	BucketStart time.Time `json:"bucket_start,omitempty"`
+	Count       uint64    `json:"count"`
 }
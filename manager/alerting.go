@@ -0,0 +1,119 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"flag"
+	"io/ioutil"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/alerts"
+	"github.com/google/cadvisor/info/v2"
+)
+
+var alertingRules = flag.String("alerting_rules", "", "Path of a JSON file configuring the alerting rules evaluated on the stats of the containers")
+var alertingInterval = flag.Duration("alerting_interval", 10*time.Second, "Interval between evaluations of the alerting rules")
+
+// Starts evaluating the configured alerting rules.
+func (self *manager) startAlerting() error {
+	if *alertingRules == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(*alertingRules)
+	if err != nil {
+		return err
+	}
+	rules, err := alerts.ParseRules(content)
+	if err != nil {
+		return err
+	}
+	self.alertEngine = alerts.NewEngine(rules)
+	quitAlerting := make(chan error)
+	self.quitChannels = append(self.quitChannels, quitAlerting)
+	go self.evaluateAlerts(quitAlerting)
+	glog.Infof("Evaluating %d alerting rules every %v", len(rules), *alertingInterval)
+	return nil
+}
+
+func (self *manager) evaluateAlerts(quit chan error) {
+	ticker := time.NewTicker(*alertingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, e := range self.alertEngine.Evaluate(self.alertSamples(), now) {
+				if err := self.addEvent(e); err != nil {
+					glog.Errorf("failed to add alert event for %q: %v", e.ContainerName, err)
+				}
+			}
+		case <-quit:
+			quit <- nil
+			glog.Infof("Exiting alerting thread")
+			return
+		}
+	}
+}
+
+// Returns the samples the rules are evaluated on, one per container.
+func (self *manager) alertSamples() []*alerts.Sample {
+	var containers []*containerData
+	func() {
+		self.containersLock.RLock()
+		defer self.containersLock.RUnlock()
+		for name, cont := range self.containers {
+			// Skip the aliases.
+			if name.Namespace == "" {
+				containers = append(containers, cont)
+			}
+		}
+	}()
+	samples := make([]*alerts.Sample, 0, len(containers))
+	for _, cont := range containers {
+		cinfo, err := cont.GetInfo()
+		if err != nil {
+			continue
+		}
+		var empty time.Time
+		stats, err := self.memoryCache.RecentStats(cinfo.Name, empty, empty, 2)
+		if err != nil {
+			continue
+		}
+		sample := &alerts.Sample{
+			ContainerReference: cinfo.ContainerReference,
+			Spec:               self.getAdjustedSpec(cinfo),
+			Stats:              stats,
+		}
+		if derived, err := cont.DerivedStats(); err == nil {
+			sample.Derived = &derived
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+func (self *manager) GetAlerts(containerName string, options v2.RequestOptions) ([]v2.Alert, error) {
+	if self.alertEngine == nil {
+		return []v2.Alert{}, nil
+	}
+	containers, err := self.getRequestedContainers(containerName, options)
+	if err != nil {
+		return nil, err
+	}
+	return self.alertEngine.Alerts(func(name string) bool {
+		_, ok := containers[name]
+		return ok
+	}), nil
+}
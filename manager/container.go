@@ -18,7 +18,7 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"math"
+	"os"
 	"os/exec"
 	"path"
 	"regexp"
@@ -33,6 +33,7 @@ import (
 	"github.com/google/cadvisor/cache/memory"
 	"github.com/google/cadvisor/collector"
 	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/info/v2"
 	"github.com/google/cadvisor/summary"
@@ -44,9 +45,6 @@ var HousekeepingInterval = flag.Duration("housekeeping_interval", 1*time.Second,
 
 var cgroupPathRegExp = regexp.MustCompile(".*devices:(.*?)[,;$].*")
 
-// Decay value used for load average smoothing. Interval length of 10 seconds is used.
-var loadDecay = math.Exp(float64(-1 * (*HousekeepingInterval).Seconds() / 10))
-
 type containerInfo struct {
 	info.ContainerReference
 	Subcontainers []info.ContainerReference
@@ -60,7 +58,7 @@ type containerData struct {
 	lock                     sync.Mutex
 	loadReader               cpuload.CpuLoadReader
 	summaryReader            *summary.StatsSummary
-	loadAvg                  float64 // smoothed load average seen so far.
+	loadAverager             cpuload.LoadAverager
 	housekeepingInterval     time.Duration
 	maxHousekeepingInterval  time.Duration
 	allowDynamicHousekeeping bool
@@ -75,6 +73,15 @@ type containerData struct {
 
 	// Runs custom metric collectors.
 	collectorManager collector.CollectorManager
+
+	// Live streams the stats samples are published to. May be nil.
+	statsStreams *statsStreams
+
+	// Detects the container approaching its resource limits.
+	limitDetector *limitDetector
+
+	// Where the resource limit events are added. May be nil.
+	eventHandler events.EventManager
 }
 
 func (c *containerData) Start() error {
@@ -310,9 +317,9 @@ func newContainerData(containerName string, memoryCache *memory.InMemoryCache, h
 		allowDynamicHousekeeping: allowDynamicHousekeeping,
 		loadReader:               loadReader,
 		logUsage:                 logUsage,
-		loadAvg:                  -1.0, // negative value indicates uninitialized.
 		stop:                     make(chan bool, 1),
 		collectorManager:         collectorManager,
+		limitDetector:            newLimitDetector(limitThresholdsFromFlags()),
 	}
 	cont.info.ContainerReference = ref
 
@@ -451,15 +458,125 @@ func (c *containerData) updateSpec() error {
 	return nil
 }
 
-// Calculate new smoothed load average using the new sample of runnable threads.
-// The decay used ensures that the load will stabilize on a new constant value within
-// 10 seconds.
-func (c *containerData) updateLoad(newLoad uint64) {
-	if c.loadAvg < 0 {
-		c.loadAvg = float64(newLoad) // initialize to the first seen sample for faster stabilization.
-	} else {
-		c.loadAvg = c.loadAvg*loadDecay + float64(newLoad)*(1.0-loadDecay)
+// Read the tasks of the container and update its load averages.
+func (c *containerData) updateLoad(stats *info.ContainerStats) {
+	// TODO(vmarmol): Cache this path.
+	path, err := c.handler.GetCgroupPath("cpu")
+	if err != nil {
+		return
+	}
+	loadStats, err := c.loadReader.GetCpuLoad(c.info.Name, path)
+	if err != nil {
+		// Failing to read the load of a container does not fail its other stats.
+		if c.handler.Exists() {
+			glog.V(3).Infof("Failed to get load stats of %q - path %q: %v", c.info.Name, path, err)
+		}
+		return
+	}
+	stats.TaskStats = loadStats
+	averages := c.loadAverager.Update(loadStats, stats.Timestamp)
+	stats.Cpu.LoadAverages = &averages
+	// convert to 'milliLoad' to avoid floats and preserve precision.
+	stats.Cpu.LoadAverage = int32(averages.OneMinute * 1000)
+}
+
+// Parses the content of /proc/<pid>/schedstat: the time spent running, the time
+// spent waiting on a run queue and the number of run periods.
+func parseSchedstat(content string) (info.CpuSchedstat, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 3 {
+		return info.CpuSchedstat{}, fmt.Errorf("invalid schedstat %q", content)
+	}
+	values := make([]uint64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return info.CpuSchedstat{}, fmt.Errorf("invalid schedstat %q: %v", content, err)
+		}
+		values[i] = value
+	}
+	return info.CpuSchedstat{
+		RunTime:      values[0],
+		RunqueueTime: values[1],
+		RunPeriods:   values[2],
+	}, nil
+}
+
+// Sum the scheduler stats of the specified threads.
+func (c *containerData) updateSchedstat(threads []int, stats *info.ContainerStats) {
+	stats.Cpu.Schedstat = info.CpuSchedstat{}
+	for _, tid := range threads {
+		// Threads may exit while they are read, and kernels without
+		// CONFIG_SCHED_INFO have no schedstat.
+		content, err := ioutil.ReadFile(path.Join("/proc", strconv.Itoa(tid), "schedstat"))
+		if err != nil {
+			glog.V(4).Infof("Failed to read schedstat of thread %d of %q: %v", tid, c.info.Name, err)
+			continue
+		}
+		schedstat, err := parseSchedstat(string(content))
+		if err != nil {
+			glog.V(4).Infof("Failed to parse schedstat of thread %d of %q: %v", tid, c.info.Name, err)
+			continue
+		}
+		stats.Cpu.Schedstat.RunTime += schedstat.RunTime
+		stats.Cpu.Schedstat.RunqueueTime += schedstat.RunqueueTime
+		stats.Cpu.Schedstat.RunPeriods += schedstat.RunPeriods
+	}
+}
+
+// Count the threads and open file descriptors of the processes in the
+// container, and sum the scheduler stats of its threads, unless they are
+// disabled.
+func (c *containerData) updateProcessStats(stats *info.ContainerStats) {
+	countThreads := container.MetricEnabled(container.ProcessMetrics)
+	sumSchedstat := container.MetricEnabled(container.SchedstatMetrics)
+	if countThreads || sumSchedstat {
+		threads, err := c.handler.ListThreads(container.ListSelf)
+		if err != nil {
+			glog.V(4).Infof("Failed to list threads of %q: %v", c.info.Name, err)
+		} else {
+			if countThreads {
+				stats.Processes.ThreadCount = uint64(len(threads))
+			}
+			if sumSchedstat {
+				c.updateSchedstat(threads, stats)
+			}
+		}
+	}
+
+	if !container.MetricEnabled(container.FdMetrics) {
+		return
+	}
+	pids, err := c.handler.ListProcesses(container.ListSelf)
+	if err != nil {
+		glog.V(4).Infof("Failed to list processes of %q: %v", c.info.Name, err)
+		return
+	}
+	stats.Processes.FdCount = 0
+	for _, pid := range pids {
+		// Processes may exit while they are counted.
+		fdCount, err := countFds(pid)
+		if err != nil {
+			glog.V(4).Infof("Failed to read open files of process %d of %q: %v", pid, c.info.Name, err)
+			continue
+		}
+		stats.Processes.FdCount += fdCount
+	}
+}
+
+// Returns the number of open file descriptors of a process. Only the names of
+// the descriptors are read, which does not stat the files they refer to.
+func countFds(pid int) (uint64, error) {
+	dir, err := os.Open(path.Join("/proc", strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return 0, err
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return 0, err
 	}
+	return uint64(len(names)), nil
 }
 
 func (c *containerData) updateStats() error {
@@ -477,18 +594,13 @@ func (c *containerData) updateStats() error {
 		return statsErr
 	}
 	if c.loadReader != nil {
-		// TODO(vmarmol): Cache this path.
-		path, err := c.handler.GetCgroupPath("cpu")
-		if err == nil {
-			loadStats, err := c.loadReader.GetCpuLoad(c.info.Name, path)
-			if err != nil {
-				return fmt.Errorf("failed to get load stat for %q - path %q, error %s", c.info.Name, path, err)
-			}
-			stats.TaskStats = loadStats
-			c.updateLoad(loadStats.NrRunning)
-			// convert to 'milliLoad' to avoid floats and preserve precision.
-			stats.Cpu.LoadAverage = int32(c.loadAvg * 1000)
-		}
+		c.updateLoad(stats)
+	}
+	c.lock.Lock()
+	hasProcesses := c.info.Spec.HasProcesses
+	c.lock.Unlock()
+	if hasProcesses {
+		c.updateProcessStats(stats)
 	}
 	if c.summaryReader != nil {
 		err := c.summaryReader.AddSample(*stats)
@@ -523,12 +635,72 @@ func (c *containerData) updateStats() error {
 	if err != nil {
 		return err
 	}
+	c.publishStats(ref, stats)
+	// Partial stats could look like usage dropped below a limit.
+	if statsErr == nil {
+		c.addLimitEvents(ref, stats)
+	}
 	if statsErr != nil {
 		return statsErr
 	}
 	return customStatsErr
 }
 
+// Replaces the reference of the container, returning the previous one.
+func (c *containerData) setReference(ref info.ContainerReference) info.ContainerReference {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	old := c.info.ContainerReference
+	c.info.ContainerReference = ref
+	return old
+}
+
+// Sets the metadata events are filtered by to those of the container.
+func (c *containerData) setEventMetadata(e *info.Event) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// The event outlives the container, so it has copies of its metadata.
+	if len(c.info.Spec.Labels) > 0 {
+		e.ContainerLabels = make(map[string]string, len(c.info.Spec.Labels))
+		for key, value := range c.info.Spec.Labels {
+			e.ContainerLabels[key] = value
+		}
+	}
+	e.ContainerAliases = append([]string(nil), c.info.Aliases...)
+	e.ContainerNamespace = c.info.Namespace
+}
+
+// Adds the events of the resource limits the stats approach or hit.
+func (c *containerData) addLimitEvents(ref info.ContainerReference, stats *info.ContainerStats) {
+	if c.eventHandler == nil {
+		return
+	}
+	c.lock.Lock()
+	spec := c.info.Spec
+	c.lock.Unlock()
+	for _, e := range c.limitDetector.detect(ref.Name, &spec, stats) {
+		c.setEventMetadata(e)
+		if err := c.eventHandler.AddEvent(e); err != nil {
+			glog.Errorf("failed to add %s event for %q: %v", e.EventType, ref.Name, err)
+		}
+	}
+}
+
+// Publishes a stats sample to the live streams that include the container.
+func (c *containerData) publishStats(ref info.ContainerReference, stats *info.ContainerStats) {
+	if c.statsStreams == nil || !c.statsStreams.wants(ref.Name) {
+		return
+	}
+	c.lock.Lock()
+	spec := c.info.Spec
+	c.lock.Unlock()
+	c.statsStreams.publish(&info.ContainerInfo{
+		ContainerReference: ref,
+		Spec:               spec,
+		Stats:              []*info.ContainerStats{stats},
+	})
+}
+
 func (c *containerData) updateCustomStats() (map[string][]info.MetricVal, error) {
 	_, customStats, customStatsErr := c.collectorManager.Collect()
 	if customStatsErr != nil {
@@ -17,7 +17,11 @@
 package manager
 
 import (
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -142,6 +146,140 @@ func TestUpdateStats(t *testing.T) {
 	mockHandler.AssertExpectations(t)
 }
 
+func TestUpdateProcessStats(t *testing.T) {
+	spec := itest.GenerateRandomContainerSpec(4)
+	spec.HasProcesses = true
+	cd, mockHandler, memoryCache := setupContainerData(t, spec)
+	mockHandler.On("GetStats").Return(
+		itest.GenerateRandomStats(1, 4, 1*time.Second)[0],
+		nil,
+	)
+	mockHandler.On("ListThreads", container.ListSelf).Return(
+		[]int{1, 2, 3},
+		nil,
+	)
+	// This process has at least its standard streams open.
+	mockHandler.On("ListProcesses", container.ListSelf).Return(
+		[]int{os.Getpid()},
+		nil,
+	)
+
+	require.NoError(t, cd.updateStats())
+
+	var empty time.Time
+	stats, err := memoryCache.RecentStats(containerName, empty, empty, -1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(stats))
+	assert.Equal(t, uint64(3), stats[0].Processes.ThreadCount)
+	assert.True(t, stats[0].Processes.FdCount >= 3)
+	mockHandler.AssertExpectations(t)
+}
+
+// The processes and threads are not read when their metrics are disabled.
+func TestUpdateProcessStatsDisabled(t *testing.T) {
+	disableMetrics := flag.Lookup("disable_metrics")
+	oldValue := disableMetrics.Value.String()
+	defer disableMetrics.Value.Set(oldValue)
+	require.NoError(t, disableMetrics.Value.Set("fd,process,schedstat"))
+
+	spec := itest.GenerateRandomContainerSpec(4)
+	spec.HasProcesses = true
+	cd, mockHandler, _ := setupContainerData(t, spec)
+	mockHandler.On("GetStats").Return(
+		itest.GenerateRandomStats(1, 4, 1*time.Second)[0],
+		nil,
+	)
+
+	require.NoError(t, cd.updateStats())
+	mockHandler.AssertExpectations(t)
+}
+
+func TestParseSchedstat(t *testing.T) {
+	schedstat, err := parseSchedstat("1136475131 43560412 2378\n")
+	require.NoError(t, err)
+	assert.Equal(t, info.CpuSchedstat{
+		RunTime:      1136475131,
+		RunqueueTime: 43560412,
+		RunPeriods:   2378,
+	}, schedstat)
+
+	_, err = parseSchedstat("1136475131 43560412\n")
+	assert.Error(t, err)
+	_, err = parseSchedstat("1136475131 -1 2378\n")
+	assert.Error(t, err)
+}
+
+// Threads that exited meanwhile are skipped.
+func TestUpdateSchedstat(t *testing.T) {
+	content, err := ioutil.ReadFile("/proc/self/schedstat")
+	if err != nil {
+		t.Skipf("schedstat is not available: %v", err)
+	}
+	expected, err := parseSchedstat(string(content))
+	require.NoError(t, err)
+
+	cd, _, _ := newTestContainerData(t)
+	stats := &info.ContainerStats{}
+	cd.updateSchedstat([]int{os.Getpid(), 1 << 30}, stats)
+	assert.True(t, stats.Cpu.Schedstat.RunPeriods >= expected.RunPeriods)
+	assert.True(t, stats.Cpu.Schedstat.RunTime >= expected.RunTime)
+}
+
+type fakeLoadReader struct {
+	stats info.LoadStats
+	err   error
+}
+
+func (self *fakeLoadReader) Start() error {
+	return nil
+}
+
+func (self *fakeLoadReader) Stop() {}
+
+func (self *fakeLoadReader) GetCpuLoad(name string, path string) (info.LoadStats, error) {
+	return self.stats, self.err
+}
+
+func TestUpdateLoad(t *testing.T) {
+	cd, mockHandler, memoryCache := newTestContainerData(t)
+	loadReader := &fakeLoadReader{stats: info.LoadStats{NrRunning: 2, NrUninterruptible: 1}}
+	cd.loadReader = loadReader
+	statsList := itest.GenerateRandomStats(2, 4, 1*time.Minute)
+	mockHandler.On("GetStats").Return(statsList[0], nil).Once()
+	mockHandler.On("GetStats").Return(statsList[1], nil).Once()
+	mockHandler.On("GetCgroupPath", "cpu").Return("/sys/fs/cgroup/cpu/container", nil)
+
+	require.NoError(t, cd.updateStats())
+	require.NoError(t, cd.updateStats())
+
+	var empty time.Time
+	stats, err := memoryCache.RecentStats(containerName, empty, empty, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(stats))
+	assert.Equal(t, uint64(2), stats[0].TaskStats.NrRunning)
+	require.NotNil(t, stats[0].Cpu.LoadAverages)
+	// A minute of 3 tasks after starting at 0.
+	assert.InDelta(t, 3*(1-math.Exp(-1)), stats[0].Cpu.LoadAverages.OneMinute, 1e-9)
+	assert.Equal(t, int32(stats[0].Cpu.LoadAverages.OneMinute*1000), stats[0].Cpu.LoadAverage)
+}
+
+// Failing to read the load of a container still keeps its other stats.
+func TestUpdateLoadWithError(t *testing.T) {
+	cd, mockHandler, memoryCache := newTestContainerData(t)
+	cd.loadReader = &fakeLoadReader{err: fmt.Errorf("netlink request failed")}
+	mockHandler.On("GetStats").Return(itest.GenerateRandomStats(1, 4, 1*time.Second)[0], nil)
+	mockHandler.On("GetCgroupPath", "cpu").Return("/sys/fs/cgroup/cpu/container", nil)
+	mockHandler.On("Exists").Return(true)
+
+	require.NoError(t, cd.updateStats())
+
+	var empty time.Time
+	stats, err := memoryCache.RecentStats(containerName, empty, empty, -1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(stats))
+	assert.Nil(t, stats[0].Cpu.LoadAverages)
+}
+
 func TestUpdateSpec(t *testing.T) {
 	spec := itest.GenerateRandomContainerSpec(4)
 	cd, mockHandler, _ := newTestContainerData(t)
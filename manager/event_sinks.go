@@ -0,0 +1,161 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+var eventSinksConfig = flag.String("event_sinks_config", "", "Path of a JSON file configuring the sinks to push events to, e.g.: webhooks")
+
+// Configuration of the sinks events are pushed to.
+type eventSinksConfiguration struct {
+	Sinks []eventSinkConfig `json:"sinks"`
+}
+
+// Configuration of a sink and of the events pushed to it.
+type eventSinkConfig struct {
+	// Type of the sink: "webhook" posts the events as JSON to the URL,
+	// "storage" adds them to the storage driver.
+	Type string `json:"type"`
+
+	// URL of a webhook.
+	Url string `json:"url,omitempty"`
+	// Timeout of a post to a webhook, e.g.: "10s".
+	Timeout string `json:"timeout,omitempty"`
+	// Number of times a failed post to a webhook is retried.
+	MaxRetries int `json:"max_retries,omitempty"`
+	// Delay before retrying a post to a webhook, which doubles with every retry.
+	RetryInterval string `json:"retry_interval,omitempty"`
+
+	// Types of the events to push, e.g.: "oomKill". All when empty.
+	EventTypes []info.EventType `json:"event_types,omitempty"`
+	// Absolute name of the container whose events to push. All when empty.
+	ContainerName string `json:"container_name,omitempty"`
+	// Whether to push the events of the subcontainers of the container.
+	IncludeSubcontainers bool `json:"include_subcontainers,omitempty"`
+}
+
+func parseEventSinksConfig(content []byte) (*eventSinksConfiguration, error) {
+	config := &eventSinksConfiguration{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("invalid event sinks configuration: %v", err)
+	}
+	for i, sink := range config.Sinks {
+		for _, eventType := range sink.EventTypes {
+			if !events.IsKnownEventType(eventType) {
+				return nil, fmt.Errorf("invalid event sink %d: unknown event type %q", i, eventType)
+			}
+		}
+	}
+	return config, nil
+}
+
+func parseDurationOrDefault(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Returns the request selecting the events pushed to the sink.
+func (self *eventSinkConfig) request() *events.Request {
+	request := events.NewRequest()
+	for _, eventType := range self.EventTypes {
+		request.EventType[eventType] = true
+	}
+	if len(self.EventTypes) == 0 {
+		for _, eventType := range events.EventTypeParams {
+			request.EventType[eventType] = true
+		}
+	}
+	request.ContainerName = self.ContainerName
+	request.IncludeSubcontainers = self.IncludeSubcontainers
+	return request
+}
+
+// Creates the sink the configuration describes.
+func (self *manager) newEventSink(config *eventSinkConfig) (events.Sink, error) {
+	switch config.Type {
+	case "webhook":
+		if config.Url == "" {
+			return nil, fmt.Errorf("webhook sink without url")
+		}
+		timeout, err := parseDurationOrDefault(config.Timeout, 10*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout of webhook %q: %v", config.Url, err)
+		}
+		retryInterval, err := parseDurationOrDefault(config.RetryInterval, time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry interval of webhook %q: %v", config.Url, err)
+		}
+		return events.NewWebhookSink(config.Url, timeout, config.MaxRetries, retryInterval), nil
+	case "storage":
+		if self.memoryCache == nil {
+			return nil, fmt.Errorf("no storage driver")
+		}
+		backend := self.memoryCache.EventBackend()
+		if backend == nil {
+			return nil, fmt.Errorf("the storage driver does not support events")
+		}
+		return backend, nil
+	}
+	return nil, fmt.Errorf("unknown event sink type %q", config.Type)
+}
+
+// Starts pushing events to the configured sinks.
+func (self *manager) startEventSinks() error {
+	if *eventSinksConfig == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(*eventSinksConfig)
+	if err != nil {
+		return err
+	}
+	config, err := parseEventSinksConfig(content)
+	if err != nil {
+		return err
+	}
+	for i := range config.Sinks {
+		sinkConfig := &config.Sinks[i]
+		sink, err := self.newEventSink(sinkConfig)
+		if err != nil {
+			return fmt.Errorf("invalid event sink %d: %v", i, err)
+		}
+		watchId, err := self.eventHandler.AddSink(sinkConfig.request(), sink)
+		if err != nil {
+			return err
+		}
+		self.eventSinks = append(self.eventSinks, watchId)
+		glog.Infof("Pushing events to %s sink %d", sinkConfig.Type, watchId)
+	}
+	return nil
+}
+
+// Stops pushing events to the sinks.
+func (self *manager) stopEventSinks() {
+	for _, watchId := range self.eventSinks {
+		self.eventHandler.StopWatch(watchId)
+	}
+	self.eventSinks = nil
+}
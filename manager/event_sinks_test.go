@@ -0,0 +1,93 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/cache/memory"
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	storagetest "github.com/google/cadvisor/storage/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEventSinksConfig(t *testing.T) {
+	config, err := parseEventSinksConfig([]byte(`{
+		"sinks": [
+			{
+				"type": "webhook",
+				"url": "http://alerts.example.com/oom",
+				"max_retries": 5,
+				"retry_interval": "2s",
+				"event_types": ["oomKill"],
+				"container_name": "/docker",
+				"include_subcontainers": true
+			},
+			{"type": "storage"}
+		]
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(config.Sinks))
+
+	request := config.Sinks[0].request()
+	assert.Equal(t, map[info.EventType]bool{info.EventOomKill: true}, request.EventType)
+	assert.Equal(t, "/docker", request.ContainerName)
+	assert.True(t, request.IncludeSubcontainers)
+
+	// All events by default.
+	request = config.Sinks[1].request()
+	assert.Equal(t, len(events.EventTypeParams), len(request.EventType))
+	assert.True(t, request.EventType[info.EventContainerCreation])
+	assert.Equal(t, "", request.ContainerName)
+
+	_, err = parseEventSinksConfig([]byte(`{"sinks": {}}`))
+	assert.Error(t, err)
+
+	// Unknown event types are rejected.
+	_, err = parseEventSinksConfig([]byte(`{"sinks": [{"type": "storage", "event_types": ["oomkill"]}]}`))
+	assert.Error(t, err)
+}
+
+func TestNewEventSink(t *testing.T) {
+	backend := &storagetest.MockStorageDriver{}
+	m := &manager{memoryCache: memory.New(time.Minute, backend)}
+
+	sink, err := m.newEventSink(&eventSinkConfig{Type: "storage"})
+	require.NoError(t, err)
+	e := &info.Event{EventType: info.EventOom}
+	backend.On("AddEvent", e).Return(nil)
+	require.NoError(t, sink.AddEvent(e))
+	backend.AssertExpectations(t)
+
+	_, err = m.newEventSink(&eventSinkConfig{Type: "webhook", Url: "http://localhost:1234/events"})
+	assert.NoError(t, err)
+
+	for _, config := range []eventSinkConfig{
+		{Type: "webhook"},
+		{Type: "webhook", Url: "http://localhost:1234/events", Timeout: "soon"},
+		{Type: "pigeon"},
+	} {
+		_, err := m.newEventSink(&config)
+		assert.Error(t, err, "config %+v", config)
+	}
+
+	// Storage drivers without events.
+	m = &manager{memoryCache: memory.New(time.Minute, nil)}
+	_, err = m.newEventSink(&eventSinkConfig{Type: "storage"})
+	assert.Error(t, err)
+}
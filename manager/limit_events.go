@@ -0,0 +1,159 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"flag"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+var memoryLimitEventThreshold = flag.Float64("memory_limit_event_threshold", 0.9, "Ratio of the memory limit the working set of a container reaches for a memoryLimitApproached event, 0 disables the events")
+var cpuThrottledEventThreshold = flag.Float64("cpu_throttled_event_threshold", 0.1, "Ratio of the CFS periods of a container that are throttled for a cpuThrottled event, 0 disables the events")
+var fsNearlyFullEventThreshold = flag.Float64("fs_nearly_full_event_threshold", 0.9, "Ratio of the capacity of a filesystem a container uses for a fsNearlyFull event, 0 disables the events")
+var limitEventHysteresis = flag.Float64("limit_event_hysteresis", 0.05, "How far below its threshold a ratio has to fall before another resource limit event of the container")
+
+// Thresholds of the resource limit events, as ratios of the limits.
+type limitThresholds struct {
+	memory float64
+	cpu    float64
+	fs     float64
+	// How far below its threshold a ratio has to fall before the event is
+	// emitted again, so that a usage hovering around a threshold does not
+	// emit an event at every sample.
+	hysteresis float64
+}
+
+func limitThresholdsFromFlags() limitThresholds {
+	return limitThresholds{
+		memory:     *memoryLimitEventThreshold,
+		cpu:        *cpuThrottledEventThreshold,
+		fs:         *fsNearlyFullEventThreshold,
+		hysteresis: *limitEventHysteresis,
+	}
+}
+
+// Detects a container approaching or hitting its resource limits by
+// comparing its consecutive stats with its spec.
+type limitDetector struct {
+	thresholds limitThresholds
+	lastStats  *info.ContainerStats
+	// Whether an event was emitted and the ratio did not fall back yet, per
+	// resource.
+	memoryReached bool
+	cpuReached    bool
+	fsReached     map[string]bool
+}
+
+func newLimitDetector(thresholds limitThresholds) *limitDetector {
+	return &limitDetector{
+		thresholds: thresholds,
+		fsReached:  make(map[string]bool),
+	}
+}
+
+// Returns whether an event is emitted for the ratio, updating whether the
+// threshold is reached.
+func (self *limitDetector) cross(reached *bool, ratio float64, threshold float64, hit bool) bool {
+	if *reached {
+		if ratio < threshold-self.thresholds.hysteresis && !hit {
+			*reached = false
+		}
+		return false
+	}
+	if ratio >= threshold || hit {
+		*reached = true
+		return true
+	}
+	return false
+}
+
+func newLimitEvent(containerName string, stats *info.ContainerStats, eventType info.EventType, data *info.ResourceLimitEventData) *info.Event {
+	return &info.Event{
+		ContainerName: containerName,
+		Timestamp:     stats.Timestamp,
+		EventType:     eventType,
+		EventData: info.EventData{
+			ResourceLimit: data,
+		},
+	}
+}
+
+// Returns the events of the limits the new stats approach or hit.
+func (self *limitDetector) detect(containerName string, spec *info.ContainerSpec, stats *info.ContainerStats) []*info.Event {
+	var events []*info.Event
+	previous := self.lastStats
+	self.lastStats = stats
+
+	if self.thresholds.memory > 0 && spec.HasMemory && spec.Memory.Limit > 0 {
+		var failcntIncrease uint64
+		if previous != nil && stats.Memory.Failcnt > previous.Memory.Failcnt {
+			failcntIncrease = stats.Memory.Failcnt - previous.Memory.Failcnt
+		}
+		ratio := float64(stats.Memory.WorkingSet) / float64(spec.Memory.Limit)
+		if self.cross(&self.memoryReached, ratio, self.thresholds.memory, failcntIncrease > 0) {
+			events = append(events, newLimitEvent(containerName, stats, info.EventMemoryLimitApproached, &info.ResourceLimitEventData{
+				Usage:           stats.Memory.WorkingSet,
+				Limit:           spec.Memory.Limit,
+				Ratio:           ratio,
+				Threshold:       self.thresholds.memory,
+				FailcntIncrease: failcntIncrease,
+			}))
+		}
+	}
+
+	if self.thresholds.cpu > 0 && previous != nil && stats.Cpu.CFS.Periods > previous.Cpu.CFS.Periods && stats.Cpu.CFS.ThrottledPeriods >= previous.Cpu.CFS.ThrottledPeriods {
+		periods := stats.Cpu.CFS.Periods - previous.Cpu.CFS.Periods
+		throttled := stats.Cpu.CFS.ThrottledPeriods - previous.Cpu.CFS.ThrottledPeriods
+		ratio := float64(throttled) / float64(periods)
+		if self.cross(&self.cpuReached, ratio, self.thresholds.cpu, false) {
+			events = append(events, newLimitEvent(containerName, stats, info.EventCpuThrottled, &info.ResourceLimitEventData{
+				Usage:     throttled,
+				Limit:     periods,
+				Ratio:     ratio,
+				Threshold: self.thresholds.cpu,
+			}))
+		}
+	}
+
+	if self.thresholds.fs > 0 {
+		devices := make(map[string]bool, len(stats.Filesystem))
+		for _, fs := range stats.Filesystem {
+			if fs.Limit == 0 {
+				continue
+			}
+			devices[fs.Device] = true
+			reached := self.fsReached[fs.Device]
+			ratio := float64(fs.Usage) / float64(fs.Limit)
+			if self.cross(&reached, ratio, self.thresholds.fs, false) {
+				events = append(events, newLimitEvent(containerName, stats, info.EventFsNearlyFull, &info.ResourceLimitEventData{
+					Usage:     fs.Usage,
+					Limit:     fs.Limit,
+					Ratio:     ratio,
+					Threshold: self.thresholds.fs,
+					Device:    fs.Device,
+				}))
+			}
+			self.fsReached[fs.Device] = reached
+		}
+		// Forget the filesystems that went away.
+		for device := range self.fsReached {
+			if !devices[device] {
+				delete(self.fsReached, device)
+			}
+		}
+	}
+	return events
+}
@@ -0,0 +1,186 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/events"
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testLimitThresholds = limitThresholds{
+	memory:     0.9,
+	cpu:        0.1,
+	fs:         0.9,
+	hysteresis: 0.05,
+}
+
+func newMemorySpec(limit uint64) *info.ContainerSpec {
+	spec := &info.ContainerSpec{HasMemory: true}
+	spec.Memory.Limit = limit
+	return spec
+}
+
+func newMemoryStats(workingSet, failcnt uint64) *info.ContainerStats {
+	stats := &info.ContainerStats{Timestamp: time.Now()}
+	stats.Memory.WorkingSet = workingSet
+	stats.Memory.Failcnt = failcnt
+	return stats
+}
+
+func TestMemoryLimitApproached(t *testing.T) {
+	detector := newLimitDetector(testLimitThresholds)
+	spec := newMemorySpec(1000)
+
+	assert.Empty(t, detector.detect(containerName, spec, newMemoryStats(800, 0)))
+	events := detector.detect(containerName, spec, newMemoryStats(950, 0))
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, info.EventType(info.EventMemoryLimitApproached), events[0].EventType)
+	assert.Equal(t, containerName, events[0].ContainerName)
+	assert.Equal(t, &info.ResourceLimitEventData{Usage: 950, Limit: 1000, Ratio: 0.95, Threshold: 0.9}, events[0].EventData.ResourceLimit)
+
+	// Not again until the working set falls below 85% of the limit.
+	assert.Empty(t, detector.detect(containerName, spec, newMemoryStats(880, 0)))
+	assert.Empty(t, detector.detect(containerName, spec, newMemoryStats(920, 0)))
+	assert.Empty(t, detector.detect(containerName, spec, newMemoryStats(840, 0)))
+	assert.Equal(t, 1, len(detector.detect(containerName, spec, newMemoryStats(900, 0))))
+}
+
+func TestMemoryLimitHit(t *testing.T) {
+	detector := newLimitDetector(testLimitThresholds)
+	spec := newMemorySpec(1000)
+
+	assert.Empty(t, detector.detect(containerName, spec, newMemoryStats(500, 3)))
+	events := detector.detect(containerName, spec, newMemoryStats(500, 5))
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, uint64(2), events[0].EventData.ResourceLimit.FailcntIncrease)
+
+	// Stays reached while the limit keeps being hit.
+	assert.Empty(t, detector.detect(containerName, spec, newMemoryStats(500, 6)))
+	assert.Empty(t, detector.detect(containerName, spec, newMemoryStats(500, 6)))
+	assert.Equal(t, 1, len(detector.detect(containerName, spec, newMemoryStats(500, 7))))
+}
+
+func newCpuStats(periods, throttledPeriods uint64) *info.ContainerStats {
+	stats := &info.ContainerStats{Timestamp: time.Now()}
+	stats.Cpu.CFS.Periods = periods
+	stats.Cpu.CFS.ThrottledPeriods = throttledPeriods
+	return stats
+}
+
+func TestCpuThrottled(t *testing.T) {
+	detector := newLimitDetector(testLimitThresholds)
+	spec := &info.ContainerSpec{}
+
+	// The first stats have no previous periods to compare with.
+	assert.Empty(t, detector.detect(containerName, spec, newCpuStats(100, 50)))
+	assert.Empty(t, detector.detect(containerName, spec, newCpuStats(200, 55)))
+	events := detector.detect(containerName, spec, newCpuStats(300, 75))
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, info.EventType(info.EventCpuThrottled), events[0].EventType)
+	assert.Equal(t, &info.ResourceLimitEventData{Usage: 20, Limit: 100, Ratio: 0.2, Threshold: 0.1}, events[0].EventData.ResourceLimit)
+
+	assert.Empty(t, detector.detect(containerName, spec, newCpuStats(400, 82)))
+	assert.Empty(t, detector.detect(containerName, spec, newCpuStats(500, 86)))
+	assert.Equal(t, 1, len(detector.detect(containerName, spec, newCpuStats(600, 100))))
+}
+
+func newFsStats(usage ...uint64) *info.ContainerStats {
+	stats := &info.ContainerStats{Timestamp: time.Now()}
+	for i, u := range usage {
+		stats.Filesystem = append(stats.Filesystem, info.FsStats{
+			Device: string(rune('a' + i)),
+			Limit:  100,
+			Usage:  u,
+		})
+	}
+	return stats
+}
+
+func TestFsNearlyFull(t *testing.T) {
+	detector := newLimitDetector(testLimitThresholds)
+	spec := &info.ContainerSpec{}
+
+	assert.Empty(t, detector.detect(containerName, spec, newFsStats(50, 50)))
+	events := detector.detect(containerName, spec, newFsStats(95, 50))
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, info.EventType(info.EventFsNearlyFull), events[0].EventType)
+	assert.Equal(t, "a", events[0].EventData.ResourceLimit.Device)
+
+	// Filesystems are tracked separately.
+	events = detector.detect(containerName, spec, newFsStats(95, 91))
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, "b", events[0].EventData.ResourceLimit.Device)
+
+	// A filesystem that goes away is forgotten.
+	assert.Empty(t, detector.detect(containerName, spec, newFsStats()))
+	assert.Equal(t, 2, len(detector.detect(containerName, spec, newFsStats(95, 91))))
+}
+
+func TestDisabledLimitEvents(t *testing.T) {
+	detector := newLimitDetector(limitThresholds{})
+	spec := newMemorySpec(1000)
+	detector.detect(containerName, spec, newMemoryStats(500, 0))
+	stats := newMemoryStats(1000, 5)
+	stats.Cpu.CFS.Periods = 100
+	stats.Cpu.CFS.ThrottledPeriods = 100
+	stats.Filesystem = newFsStats(100).Filesystem
+	assert.Empty(t, detector.detect(containerName, spec, stats))
+}
+
+func TestUpdateStatsAddsLimitEvents(t *testing.T) {
+	spec := info.ContainerSpec{HasMemory: true}
+	spec.Memory.Limit = 1000
+	spec.Labels = map[string]string{"app": "web"}
+	cd, mockHandler, _ := setupContainerData(t, spec)
+	cd.limitDetector = newLimitDetector(testLimitThresholds)
+	eventHandler := events.NewEventManager(events.DefaultStoragePolicy(), events.DefaultWatchPolicy())
+	cd.eventHandler = eventHandler
+	mockHandler.On("GetStats").Return(newMemoryStats(990, 0), nil)
+
+	require.NoError(t, cd.updateStats())
+
+	request := events.NewRequest()
+	request.EventType[info.EventMemoryLimitApproached] = true
+	returned, err := eventHandler.GetEvents(request)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(returned))
+	assert.Equal(t, containerName, returned[0].ContainerName)
+	assert.Equal(t, spec.Labels, returned[0].ContainerLabels)
+}
+
+func TestUpdateStatsWithErrorAddsNoLimitEvents(t *testing.T) {
+	spec := info.ContainerSpec{HasMemory: true}
+	spec.Memory.Limit = 1000
+	cd, mockHandler, _ := setupContainerData(t, spec)
+	cd.limitDetector = newLimitDetector(testLimitThresholds)
+	eventHandler := events.NewEventManager(events.DefaultStoragePolicy(), events.DefaultWatchPolicy())
+	cd.eventHandler = eventHandler
+	mockHandler.On("GetStats").Return(newMemoryStats(990, 0), fmt.Errorf("failed to read cpu stats"))
+	mockHandler.On("Exists").Return(true)
+
+	assert.Error(t, cd.updateStats())
+
+	request := events.NewRequest()
+	request.EventType[info.EventMemoryLimitApproached] = true
+	returned, err := eventHandler.GetEvents(request)
+	require.NoError(t, err)
+	assert.Empty(t, returned)
+}
@@ -28,11 +28,14 @@ import (
 
 	"github.com/docker/libcontainer/cgroups"
 	"github.com/golang/glog"
+	"github.com/google/cadvisor/alerts"
 	"github.com/google/cadvisor/cache/memory"
 	"github.com/google/cadvisor/collector"
 	"github.com/google/cadvisor/container"
 	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/container/machined"
 	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/container/systemd"
 	"github.com/google/cadvisor/events"
 	"github.com/google/cadvisor/fs"
 	info "github.com/google/cadvisor/info/v1"
@@ -44,9 +47,13 @@ import (
 
 var globalHousekeepingInterval = flag.Duration("global_housekeeping_interval", 1*time.Minute, "Interval between global housekeepings")
 var logCadvisorUsage = flag.Bool("log_cadvisor_usage", false, "Whether to log the usage of the cAdvisor container")
+var oomReplayKernelLog = flag.Bool("oom_replay_kernel_log", true, "Whether to report the OOMs already in the kernel log when cAdvisor starts, instead of only those that occur after")
 var enableLoadReader = flag.Bool("enable_load_reader", false, "Whether to enable cpu load reader")
 var eventStorageAgeLimit = flag.String("event_storage_age_limit", "default=24h", "Max length of time for which to store events (per type). Value is a comma separated list of key values, where the keys are event types (e.g.: creation, oom) or \"default\" and the value is a duration. Default is applied to all non-specified event types")
 var eventStorageEventLimit = flag.String("event_storage_event_limit", "default=100000", "Max number of events to store (per type). Value is a comma separated list of key values, where the keys are event types (e.g.: creation, oom) or \"default\" and the value is an integer. Default is applied to all non-specified event types")
+var eventStoragePath = flag.String("event_storage_path", "", "Path of a file to persist events to, so that they survive restarts. Events are only kept in memory when empty")
+var eventWatchBufferSize = flag.Int("event_watch_buffer_size", 100, "Number of events buffered for each event watcher, e.g.: a streaming API client")
+var eventSlowWatcherPolicy = flag.String("event_slow_watcher_policy", string(events.DropEvents), "What to do with event watchers whose buffer is full: \"drop\" the events that do not fit or \"disconnect\" the watcher")
 
 // The Manager interface defines operations for starting a manager and getting
 // container and machine information.
@@ -101,8 +108,24 @@ type Manager interface {
 	// Get past events that have been detected and that fit the request.
 	GetPastEvents(request *events.Request) ([]*info.Event, error)
 
+	// Count the past events that fit the request, grouped as specified.
+	GetEventCounts(request *events.Request, aggregation events.Aggregation) ([]v2.EventCount, error)
+
+	// Get the number of events detected since the start per type and container.
+	GetEventTotals() []events.EventTotal
+
 	CloseEventChannel(watch_id int)
 
+	// Get the stats samples of a container, and of its subcontainers if
+	// requested, streamed as they are collected.
+	WatchStats(containerName string, subcontainers bool) (*StatsStream, error)
+
+	// Stops a stream of stats samples.
+	StopWatchingStats(id int)
+
+	// Get the pending and firing alerts of the requested containers.
+	GetAlerts(containerName string, options v2.RequestOptions) ([]v2.Alert, error)
+
 	// Get status information about docker.
 	DockerInfo() (DockerStatus, error)
 
@@ -148,6 +171,7 @@ func New(memoryCache *memory.InMemoryCache, sysfs sysfs.SysFs, maxHousekeepingIn
 		startupTime:              time.Now(),
 		maxHousekeepingInterval:  maxHousekeepingInterval,
 		allowDynamicHousekeeping: allowDynamicHousekeeping,
+		statsStreams:             newStatsStreams(),
 	}
 
 	machineInfo, err := getMachineInfo(sysfs, fsInfo)
@@ -164,7 +188,7 @@ func New(memoryCache *memory.InMemoryCache, sysfs sysfs.SysFs, maxHousekeepingIn
 	newManager.versionInfo = *versionInfo
 	glog.Infof("Version: %+v", newManager.versionInfo)
 
-	newManager.eventHandler = events.NewEventManager(parseEventsStoragePolicy())
+	newManager.eventHandler = newEventHandler()
 	return newManager, nil
 }
 
@@ -193,6 +217,12 @@ type manager struct {
 	startupTime              time.Time
 	maxHousekeepingInterval  time.Duration
 	allowDynamicHousekeeping bool
+	// Watch ids of the sinks events are pushed to.
+	eventSinks []int
+	// Live streams of the stats of the containers.
+	statsStreams *statsStreams
+	// Evaluates the alerting rules. Nil when there are none.
+	alertEngine *alerts.Engine
 }
 
 // Start the container manager.
@@ -203,6 +233,18 @@ func (self *manager) Start() error {
 		glog.Errorf("Docker container factory registration failed: %v.", err)
 	}
 
+	// Register the systemd-machined driver. Machines are systemd scopes, so it must be registered before systemd.
+	err = machined.Register(self, self.fsInfo)
+	if err != nil {
+		glog.Infof("Registration of the machined container factory failed: %v", err)
+	}
+
+	// Register the systemd driver. It builds on raw containers, so it must be registered before them.
+	err = systemd.Register(self, self.fsInfo)
+	if err != nil {
+		glog.Infof("Registration of the systemd container factory failed: %v", err)
+	}
+
 	// Register the raw driver.
 	err = raw.Register(self, self.fsInfo)
 	if err != nil {
@@ -221,13 +263,19 @@ func (self *manager) Start() error {
 		} else {
 			err = cpuLoadReader.Start()
 			if err != nil {
-				glog.Warning("Could not start cpu load stat collector: %s", err)
+				glog.Warningf("Could not start cpu load stat collector: %s", err)
 			} else {
 				self.loadReader = cpuLoadReader
 			}
 		}
 	}
 
+	// Push events to the configured sinks.
+	err = self.startEventSinks()
+	if err != nil {
+		glog.Errorf("Failed to start event sinks: %v", err)
+	}
+
 	// Watch for OOMs.
 	err = self.watchForNewOoms()
 	if err != nil {
@@ -264,6 +312,12 @@ func (self *manager) Start() error {
 	self.quitChannels = append(self.quitChannels, quitGlobalHousekeeping)
 	go self.globalHousekeeping(quitGlobalHousekeeping)
 
+	// Evaluate the configured alerting rules.
+	err = self.startAlerting()
+	if err != nil {
+		glog.Errorf("Failed to start alerting: %v", err)
+	}
+
 	return nil
 }
 
@@ -280,6 +334,7 @@ func (self *manager) Stop() error {
 		}
 	}
 	self.quitChannels = make([]chan error, 0, 2)
+	self.stopEventSinks()
 	if self.loadReader != nil {
 		self.loadReader.Stop()
 		self.loadReader = nil
@@ -381,6 +436,8 @@ func (self *manager) getV2Spec(cinfo *containerInfo) v2.ContainerSpec {
 		HasFilesystem:    specV1.HasFilesystem,
 		HasNetwork:       specV1.HasNetwork,
 		HasDiskIo:        specV1.HasDiskIo,
+		HasProcesses:     specV1.HasProcesses,
+		HasPressure:      specV1.HasPressure,
 		HasCustomMetrics: specV1.HasCustomMetrics,
 	}
 	if specV1.HasCpu {
@@ -392,6 +449,10 @@ func (self *manager) getV2Spec(cinfo *containerInfo) v2.ContainerSpec {
 		specV2.Memory.Limit = specV1.Memory.Limit
 		specV2.Memory.Reservation = specV1.Memory.Reservation
 		specV2.Memory.SwapLimit = specV1.Memory.SwapLimit
+		specV2.Memory.HugetlbLimits = specV1.Memory.HugetlbLimits
+	}
+	if specV1.HasProcesses {
+		specV2.Processes = specV1.Processes
 	}
 	if specV1.HasCustomMetrics {
 		specV2.CustomMetrics = specV1.CustomMetrics
@@ -753,6 +814,8 @@ func (m *manager) createContainer(containerName string) error {
 	if err != nil {
 		return err
 	}
+	cont.statsStreams = m.statsStreams
+	cont.eventHandler = m.eventHandler
 
 	// Add collectors
 	labels := handler.GetContainerLabels()
@@ -809,6 +872,7 @@ func (m *manager) createContainer(containerName string) error {
 		Timestamp:     contSpec.CreationTime,
 		EventType:     info.EventContainerCreation,
 	}
+	cont.setEventMetadata(newEvent)
 	err = m.eventHandler.AddEvent(newEvent)
 	if err != nil {
 		return err
@@ -838,6 +902,7 @@ func (m *manager) destroyContainer(containerName string) error {
 	if err != nil {
 		return err
 	}
+	cont.handler.Cleanup()
 
 	// Remove the container from our records (and all its aliases).
 	delete(m.containers, namespacedName)
@@ -859,6 +924,7 @@ func (m *manager) destroyContainer(containerName string) error {
 		Timestamp:     time.Now(),
 		EventType:     info.EventContainerDeletion,
 	}
+	cont.setEventMetadata(newEvent)
 	err = m.eventHandler.AddEvent(newEvent)
 	if err != nil {
 		return err
@@ -866,6 +932,42 @@ func (m *manager) destroyContainer(containerName string) error {
 	return nil
 }
 
+// Refreshes the aliases under which a container is known, e.g.: after it was renamed.
+func (m *manager) updateContainer(containerName string) error {
+	m.containersLock.Lock()
+	defer m.containersLock.Unlock()
+
+	cont, ok := m.containers[namespacedContainerName{
+		Name: containerName,
+	}]
+	if !ok {
+		// Not known yet, it will be picked up when it is created.
+		return nil
+	}
+
+	ref, err := cont.handler.ContainerReference()
+	if err != nil {
+		return err
+	}
+
+	// Replace the old aliases with the new ones.
+	old := cont.setReference(ref)
+	for _, alias := range old.Aliases {
+		delete(m.containers, namespacedContainerName{
+			Namespace: old.Namespace,
+			Name:      alias,
+		})
+	}
+	for _, alias := range ref.Aliases {
+		m.containers[namespacedContainerName{
+			Namespace: ref.Namespace,
+			Name:      alias,
+		}] = cont
+	}
+	glog.V(3).Infof("Updated container: %q (aliases: %v, namespace: %q)", containerName, ref.Aliases, ref.Namespace)
+	return nil
+}
+
 // Detect all containers that have been added or deleted from the specified container.
 func (m *manager) getContainersDiff(containerName string) (added []info.ContainerReference, removed []info.ContainerReference, err error) {
 	m.containersLock.RLock()
@@ -960,6 +1062,10 @@ func (self *manager) watchForNewContainers(quit chan error) error {
 		return err
 	}
 
+	// Also listen to factories that learn about their containers directly (e.g.: from Docker events).
+	lifecycleEvents := make(chan *info.Event, 16)
+	container.WatchFactories(eventsChannel, lifecycleEvents)
+
 	// There is a race between starting the watch and new container creation so we do a detection before we read new containers.
 	err = self.detectSubcontainers("/")
 	if err != nil {
@@ -976,13 +1082,23 @@ func (self *manager) watchForNewContainers(quit chan error) error {
 					err = self.createContainer(event.Name)
 				case event.EventType == container.SubcontainerDelete:
 					err = self.destroyContainer(event.Name)
+				case event.EventType == container.SubcontainerUpdate:
+					err = self.updateContainer(event.Name)
 				}
 				if err != nil {
 					glog.Warningf("Failed to process watch event: %v", err)
 				}
+			case event := <-lifecycleEvents:
+				err := self.addEvent(event)
+				if err != nil {
+					glog.Errorf("failed to add %s event for %q: %v", event.EventType, event.ContainerName, err)
+				}
 			case <-quit:
 				// Stop processing events if asked to quit.
-				err := root.handler.StopWatchingSubcontainers()
+				err := container.StopWatchingFactories()
+				if err == nil {
+					err = root.handler.StopWatchingSubcontainers()
+				}
 				quit <- err
 				if err == nil {
 					glog.Infof("Exiting thread watching subcontainers")
@@ -994,10 +1110,35 @@ func (self *manager) watchForNewContainers(quit chan error) error {
 	return nil
 }
 
+// Converts an OOM instance of the kernel log to the data of an OOM kill event.
+func newOomKillEventData(oomInstance *oomparser.OomInstance) *info.OomKillEventData {
+	data := &info.OomKillEventData{
+		Pid:           oomInstance.Pid,
+		ProcessName:   oomInstance.ProcessName,
+		MemoryUsage:   oomInstance.MemoryUsage,
+		MemoryLimit:   oomInstance.MemoryLimit,
+		MemoryFailcnt: oomInstance.MemoryFailcnt,
+		GfpMask:       oomInstance.GfpMask,
+		Order:         oomInstance.Order,
+	}
+	for _, task := range oomInstance.Tasks {
+		data.Tasks = append(data.Tasks, info.OomTask{
+			Pid:         task.Pid,
+			Uid:         task.Uid,
+			Tgid:        task.Tgid,
+			TotalVm:     task.TotalVm,
+			Rss:         task.Rss,
+			OomScoreAdj: task.OomScoreAdj,
+			Name:        task.Name,
+		})
+	}
+	return data
+}
+
 func (self *manager) watchForNewOoms() error {
 	glog.Infof("Started watching for new ooms in manager")
 	outStream := make(chan *oomparser.OomInstance, 10)
-	oomLog, err := oomparser.New()
+	oomLog, err := oomparser.New(*oomReplayKernelLog)
 	if err != nil {
 		return err
 	}
@@ -1011,7 +1152,7 @@ func (self *manager) watchForNewOoms() error {
 				Timestamp:     oomInstance.TimeOfDeath,
 				EventType:     info.EventOom,
 			}
-			err := self.eventHandler.AddEvent(newEvent)
+			err := self.addEvent(newEvent)
 			if err != nil {
 				glog.Errorf("failed to add OOM event for %q: %v", oomInstance.ContainerName, err)
 			}
@@ -1022,13 +1163,10 @@ func (self *manager) watchForNewOoms() error {
 				Timestamp:     oomInstance.TimeOfDeath,
 				EventType:     info.EventOomKill,
 				EventData: info.EventData{
-					OomKill: &info.OomKillEventData{
-						Pid:         oomInstance.Pid,
-						ProcessName: oomInstance.ProcessName,
-					},
+					OomKill: newOomKillEventData(oomInstance),
 				},
 			}
-			err = self.eventHandler.AddEvent(newEvent)
+			err = self.addEvent(newEvent)
 			if err != nil {
 				glog.Errorf("failed to add OOM kill event for %q: %v", oomInstance.ContainerName, err)
 			}
@@ -1037,6 +1175,14 @@ func (self *manager) watchForNewOoms() error {
 	return nil
 }
 
+// Adds an event with the metadata of its container, when it is known.
+func (self *manager) addEvent(e *info.Event) error {
+	if cont, err := self.getContainer(e.ContainerName); err == nil {
+		cont.setEventMetadata(e)
+	}
+	return self.eventHandler.AddEvent(e)
+}
+
 // can be called by the api which will take events returned on the channel
 func (self *manager) WatchForEvents(request *events.Request) (*events.EventChannel, error) {
 	return self.eventHandler.WatchEvents(request)
@@ -1047,11 +1193,47 @@ func (self *manager) GetPastEvents(request *events.Request) ([]*info.Event, erro
 	return self.eventHandler.GetEvents(request)
 }
 
+// can be called by the api which will return the counts of the events satisfying the request
+func (self *manager) GetEventCounts(request *events.Request, aggregation events.Aggregation) ([]v2.EventCount, error) {
+	return self.eventHandler.GetEventCounts(request, aggregation)
+}
+
+func (self *manager) GetEventTotals() []events.EventTotal {
+	return self.eventHandler.GetEventTotals()
+}
+
 // called by the api when a client is no longer listening to the channel
 func (self *manager) CloseEventChannel(watch_id int) {
 	self.eventHandler.StopWatch(watch_id)
 }
 
+// can be called by the api which will take the stats samples returned on the channel
+func (self *manager) WatchStats(containerName string, subcontainers bool) (*StatsStream, error) {
+	if !self.Exists(containerName) {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+	return self.statsStreams.add(containerName, subcontainers), nil
+}
+
+// called by the api when a client is no longer listening to the stats samples
+func (self *manager) StopWatchingStats(id int) {
+	self.statsStreams.remove(id)
+}
+
+// Creates the event handler, which persists the events when configured to.
+func newEventHandler() events.EventManager {
+	storagePolicy := parseEventsStoragePolicy()
+	watchPolicy := parseEventsWatchPolicy()
+	if *eventStoragePath != "" {
+		eventHandler, err := events.NewPersistentEventManager(storagePolicy, watchPolicy, *eventStoragePath)
+		if err == nil {
+			return eventHandler
+		}
+		glog.Errorf("Keeping events in memory only: %v", err)
+	}
+	return events.NewEventManager(storagePolicy, watchPolicy)
+}
+
 // Parses the events StoragePolicy from the flags.
 func parseEventsStoragePolicy() events.StoragePolicy {
 	policy := events.DefaultStoragePolicy()
@@ -1099,6 +1281,23 @@ func parseEventsStoragePolicy() events.StoragePolicy {
 	return policy
 }
 
+// Parses the events WatchPolicy from the flags.
+func parseEventsWatchPolicy() events.WatchPolicy {
+	policy := events.DefaultWatchPolicy()
+	if *eventWatchBufferSize > 0 {
+		policy.BufferSize = *eventWatchBufferSize
+	} else {
+		glog.Warningf("Invalid event watch buffer size %d, using %d", *eventWatchBufferSize, policy.BufferSize)
+	}
+	switch slowWatcherPolicy := events.SlowWatcherPolicy(*eventSlowWatcherPolicy); slowWatcherPolicy {
+	case events.DropEvents, events.DisconnectWatcher:
+		policy.SlowWatcherPolicy = slowWatcherPolicy
+	default:
+		glog.Warningf("Unknown slow event watcher policy %q, using %q", slowWatcherPolicy, policy.SlowWatcherPolicy)
+	}
+	return policy
+}
+
 type DockerStatus struct {
 	Version       string            `json:"version"`
 	KernelVersion string            `json:"kernel_version"`
@@ -26,8 +26,10 @@ import (
 	"github.com/google/cadvisor/collector"
 	"github.com/google/cadvisor/container"
 	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
 	itest "github.com/google/cadvisor/info/v1/test"
+	"github.com/google/cadvisor/utils/oomparser"
 	"github.com/google/cadvisor/utils/sysfs/fakesysfs"
 )
 
@@ -210,3 +212,92 @@ func TestNewNilManager(t *testing.T) {
 		t.Fatalf("Expected nil manager to return error")
 	}
 }
+
+func TestNewOomKillEventData(t *testing.T) {
+	data := newOomKillEventData(&oomparser.OomInstance{
+		Pid:           4242,
+		ProcessName:   "stress",
+		MemoryUsage:   1024,
+		MemoryLimit:   2048,
+		MemoryFailcnt: 3,
+		GfpMask:       "0xcc0(GFP_KERNEL)",
+		Order:         1,
+		Tasks: []oomparser.OomTask{
+			{Pid: 4242, Uid: 1, Tgid: 4242, TotalVm: 8192, Rss: 4096, OomScoreAdj: 1000, Name: "stress"},
+		},
+	})
+	expected := &info.OomKillEventData{
+		Pid:           4242,
+		ProcessName:   "stress",
+		MemoryUsage:   1024,
+		MemoryLimit:   2048,
+		MemoryFailcnt: 3,
+		GfpMask:       "0xcc0(GFP_KERNEL)",
+		Order:         1,
+		Tasks: []info.OomTask{
+			{Pid: 4242, Uid: 1, Tgid: 4242, TotalVm: 8192, Rss: 4096, OomScoreAdj: 1000, Name: "stress"},
+		},
+	}
+	if !reflect.DeepEqual(expected, data) {
+		t.Errorf("expected %+v, got %+v", expected, data)
+	}
+}
+
+func TestAddEventSetsContainerMetadata(t *testing.T) {
+	memoryCache := memory.New(time.Minute, nil)
+	m := createManagerAndAddContainers(memoryCache, &fakesysfs.FakeSysFs{}, []string{"/docker/abc"}, func(h *container.MockContainerHandler) {}, t)
+	m.eventHandler = events.NewEventManager(events.DefaultStoragePolicy(), events.DefaultWatchPolicy())
+	cont := m.containers[namespacedContainerName{Name: "/docker/abc"}]
+	cont.info.Spec.Labels = map[string]string{"app": "web"}
+	cont.info.Aliases = []string{"web-1", "abc"}
+	cont.info.Namespace = docker.DockerNamespace
+
+	known := &info.Event{ContainerName: "/docker/abc", Timestamp: time.Now(), EventType: info.EventOomKill}
+	if err := m.addEvent(known); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cont.info.Spec.Labels, known.ContainerLabels) || !reflect.DeepEqual(cont.info.Aliases, known.ContainerAliases) || known.ContainerNamespace != docker.DockerNamespace {
+		t.Errorf("expected the metadata of %+v, got %+v", cont.info, known)
+	}
+	// The event keeps its own copy.
+	cont.info.Spec.Labels["app"] = "db"
+	if known.ContainerLabels["app"] != "web" {
+		t.Errorf("expected the labels of the event to be a copy, got %v", known.ContainerLabels)
+	}
+
+	unknown := &info.Event{ContainerName: "/docker/def", Timestamp: time.Now(), EventType: info.EventOomKill}
+	if err := m.addEvent(unknown); err != nil {
+		t.Fatal(err)
+	}
+	if unknown.ContainerLabels != nil || unknown.ContainerAliases != nil || unknown.ContainerNamespace != "" {
+		t.Errorf("expected no metadata, got %+v", unknown)
+	}
+}
+
+func TestUpdateContainerReplacesAliases(t *testing.T) {
+	memoryCache := memory.New(time.Minute, nil)
+	var handler *container.MockContainerHandler
+	m := createManagerAndAddContainers(memoryCache, &fakesysfs.FakeSysFs{}, []string{"/c1"}, func(h *container.MockContainerHandler) { handler = h }, t)
+	cont := m.containers[namespacedContainerName{Name: "/c1"}]
+	cont.info.Aliases = []string{"old"}
+	m.containers[namespacedContainerName{Name: "old"}] = cont
+	handler.Aliases = []string{"new"}
+
+	// Read the reference concurrently, as housekeeping does.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cont.setEventMetadata(&info.Event{})
+	}()
+	if err := m.updateContainer("/c1"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if _, ok := m.containers[namespacedContainerName{Name: "old"}]; ok {
+		t.Errorf("expected the old alias to be removed")
+	}
+	if m.containers[namespacedContainerName{Name: "new"}] != cont {
+		t.Errorf("expected the new alias to refer to the container")
+	}
+}
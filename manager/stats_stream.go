@@ -0,0 +1,132 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/glog"
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Number of samples buffered for each stats stream.
+const statsStreamBufferSize = 100
+
+// A stream of the stats samples of a container, and of its subcontainers if
+// requested, as housekeeping collects them.
+type StatsStream struct {
+	// Number of samples that were dropped because the channel was full.
+	// Accessed atomically, so it is first to be 64-bit aligned.
+	droppedSamples uint64
+	id             int
+	containerName  string
+	subcontainers  bool
+	// Channel on which the samples are received. Each sample has the
+	// reference, the spec and a single stats of a container. It is closed
+	// when the stream is stopped.
+	channel chan *info.ContainerInfo
+}
+
+func (self *StatsStream) GetChannel() <-chan *info.ContainerInfo {
+	return self.channel
+}
+
+func (self *StatsStream) GetId() int {
+	return self.id
+}
+
+// Returns the number of samples that were dropped because the caller did not
+// receive them fast enough.
+func (self *StatsStream) GetDroppedSamples() uint64 {
+	return atomic.LoadUint64(&self.droppedSamples)
+}
+
+// Whether the stream has the samples of the container.
+func (self *StatsStream) includes(containerName string) bool {
+	if containerName == self.containerName {
+		return true
+	}
+	return self.subcontainers && (self.containerName == "/" || strings.HasPrefix(containerName, self.containerName+"/"))
+}
+
+// The stats streams of a manager.
+type statsStreams struct {
+	lock    sync.RWMutex
+	streams map[int]*StatsStream
+	lastId  int
+}
+
+func newStatsStreams() *statsStreams {
+	return &statsStreams{
+		streams: make(map[int]*StatsStream),
+	}
+}
+
+func (self *statsStreams) add(containerName string, subcontainers bool) *StatsStream {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.lastId++
+	stream := &StatsStream{
+		id:            self.lastId,
+		containerName: containerName,
+		subcontainers: subcontainers,
+		channel:       make(chan *info.ContainerInfo, statsStreamBufferSize),
+	}
+	self.streams[stream.id] = stream
+	return stream
+}
+
+func (self *statsStreams) remove(id int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	stream, ok := self.streams[id]
+	if !ok {
+		return
+	}
+	close(stream.channel)
+	delete(self.streams, id)
+}
+
+// Whether any stream has the samples of the container.
+func (self *statsStreams) wants(containerName string) bool {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, stream := range self.streams {
+		if stream.includes(containerName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends a sample to the streams that include its container, without blocking.
+func (self *statsStreams) publish(sample *info.ContainerInfo) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, stream := range self.streams {
+		if !stream.includes(sample.Name) {
+			continue
+		}
+		select {
+		case stream.channel <- sample:
+		default:
+			if atomic.AddUint64(&stream.droppedSamples, 1) == 1 {
+				glog.Warningf("Stats stream %d does not keep up with the samples, dropping samples", stream.id)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStatsSample(containerName string) *info.ContainerInfo {
+	return &info.ContainerInfo{
+		ContainerReference: info.ContainerReference{Name: containerName},
+		Stats:              []*info.ContainerStats{{Timestamp: time.Now()}},
+	}
+}
+
+func TestStatsStreamIncludes(t *testing.T) {
+	streams := newStatsStreams()
+	self := streams.add("/docker", false)
+	subtree := streams.add("/docker", true)
+	root := streams.add("/", true)
+
+	assert.True(t, self.includes("/docker"))
+	assert.False(t, self.includes("/docker/abc"))
+	assert.True(t, subtree.includes("/docker/abc"))
+	assert.False(t, subtree.includes("/dockerd"))
+	assert.True(t, root.includes("/system.slice"))
+}
+
+func TestStatsStreamsPublish(t *testing.T) {
+	streams := newStatsStreams()
+	assert.False(t, streams.wants("/docker/abc"))
+	stream := streams.add("/docker", true)
+	assert.True(t, streams.wants("/docker/abc"))
+	assert.False(t, streams.wants("/system.slice"))
+
+	sample := newStatsSample("/docker/abc")
+	streams.publish(newStatsSample("/system.slice"))
+	streams.publish(sample)
+	require.Equal(t, 1, len(stream.GetChannel()))
+	assert.Equal(t, sample, <-stream.GetChannel())
+
+	streams.remove(stream.GetId())
+	_, ok := <-stream.GetChannel()
+	assert.False(t, ok)
+	assert.False(t, streams.wants("/docker/abc"))
+	// Removing twice is harmless.
+	streams.remove(stream.GetId())
+}
+
+func TestStatsStreamsDropSamples(t *testing.T) {
+	streams := newStatsStreams()
+	stream := streams.add("/", false)
+	for i := 0; i < statsStreamBufferSize+3; i++ {
+		streams.publish(newStatsSample("/"))
+	}
+	assert.Equal(t, statsStreamBufferSize, len(stream.GetChannel()))
+	assert.Equal(t, uint64(3), stream.GetDroppedSamples())
+}
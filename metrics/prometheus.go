@@ -19,6 +19,8 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -29,6 +31,15 @@ type subcontainersInfoProvider interface {
 	SubcontainersInfo(containerName string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error)
 }
 
+// Implemented by the infoProviders that count the events of the containers,
+// usually manager.Manager.
+type eventTotalsProvider interface {
+	// Get the number of events detected since the start per type and container.
+	GetEventTotals() []events.EventTotal
+}
+
+var eventsTotalDesc = prometheus.NewDesc("container_events_total", "Number of events of the container detected by type", []string{"name", "id", "type"}, nil)
+
 // metricValue describes a single metric value for a given set of label values
 // within a parent containerMetric.
 type metricValue struct {
@@ -50,6 +61,33 @@ func fsValues(fsStats []info.FsStats, valueFn func(*info.FsStats) float64) metri
 	return values
 }
 
+// tcpValues is a helper method for assembling the number of TCP sockets per state.
+func tcpValues(stat info.TcpStat) metricValues {
+	return metricValues{
+		{value: float64(stat.Established), labels: []string{"established"}},
+		{value: float64(stat.SynSent), labels: []string{"synsent"}},
+		{value: float64(stat.SynRecv), labels: []string{"synrecv"}},
+		{value: float64(stat.FinWait1), labels: []string{"finwait1"}},
+		{value: float64(stat.FinWait2), labels: []string{"finwait2"}},
+		{value: float64(stat.TimeWait), labels: []string{"timewait"}},
+		{value: float64(stat.Close), labels: []string{"close"}},
+		{value: float64(stat.CloseWait), labels: []string{"closewait"}},
+		{value: float64(stat.LastAck), labels: []string{"lastack"}},
+		{value: float64(stat.Listen), labels: []string{"listen"}},
+		{value: float64(stat.Closing), labels: []string{"closing"}},
+	}
+}
+
+// udpValues is a helper method for assembling the UDP socket stats.
+func udpValues(stat info.UdpStat) metricValues {
+	return metricValues{
+		{value: float64(stat.Listen), labels: []string{"listen"}},
+		{value: float64(stat.Dropped), labels: []string{"dropped"}},
+		{value: float64(stat.RxQueued), labels: []string{"rxqueued"}},
+		{value: float64(stat.TxQueued), labels: []string{"txqueued"}},
+	}
+}
+
 // A containerMetric describes a multi-dimensional metric used for exposing
 // a certain type of container statistic.
 type containerMetric struct {
@@ -57,7 +95,33 @@ type containerMetric struct {
 	help        string
 	valueType   prometheus.ValueType
 	extraLabels []string
-	getValues   func(s *info.ContainerStats) metricValues
+	// Whether the metric is exported for a container with the given spec,
+	// always if nil.
+	condition func(s info.ContainerSpec) bool
+	getValues func(s *info.ContainerStats) metricValues
+}
+
+// Pressure stall information is only exported by kernels that have it.
+func hasPressure(s info.ContainerSpec) bool {
+	return s.HasPressure
+}
+
+// Costly metrics are only exported when they are collected, rather than as
+// zeros.
+func hasTcpMetrics(s info.ContainerSpec) bool {
+	return container.MetricEnabled(container.TcpMetrics)
+}
+
+func hasUdpMetrics(s info.ContainerSpec) bool {
+	return container.MetricEnabled(container.UdpMetrics)
+}
+
+func hasProcessMetrics(s info.ContainerSpec) bool {
+	return container.MetricEnabled(container.ProcessMetrics)
+}
+
+func hasFdMetrics(s info.ContainerSpec) bool {
+	return container.MetricEnabled(container.FdMetrics)
 }
 
 func (cm *containerMetric) desc() *prometheus.Desc {
@@ -117,6 +181,57 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 					}
 					return values
 				},
+			}, {
+				name:      "container_cpu_cfs_periods_total",
+				help:      "Number of elapsed enforcement period intervals.",
+				valueType: prometheus.CounterValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Cpu.CFS.Periods)}}
+				},
+			}, {
+				name:      "container_cpu_cfs_throttled_periods_total",
+				help:      "Number of throttled period intervals.",
+				valueType: prometheus.CounterValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Cpu.CFS.ThrottledPeriods)}}
+				},
+			}, {
+				name:      "container_cpu_cfs_throttled_seconds_total",
+				help:      "Total time duration the container has been throttled.",
+				valueType: prometheus.CounterValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Cpu.CFS.ThrottledTime) / float64(time.Second)}}
+				},
+			}, {
+				name:      "container_cpu_load_average_1m",
+				help:      "Load average of the container over the last minute.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					if s.Cpu.LoadAverages == nil {
+						return nil
+					}
+					return metricValues{{value: s.Cpu.LoadAverages.OneMinute}}
+				},
+			}, {
+				name:      "container_cpu_load_average_5m",
+				help:      "Load average of the container over the last 5 minutes.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					if s.Cpu.LoadAverages == nil {
+						return nil
+					}
+					return metricValues{{value: s.Cpu.LoadAverages.FiveMinutes}}
+				},
+			}, {
+				name:      "container_cpu_load_average_15m",
+				help:      "Load average of the container over the last 15 minutes.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					if s.Cpu.LoadAverages == nil {
+						return nil
+					}
+					return metricValues{{value: s.Cpu.LoadAverages.FifteenMinutes}}
+				},
 			}, {
 				name:      "container_memory_usage_bytes",
 				help:      "Current memory usage in bytes.",
@@ -131,6 +246,83 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				getValues: func(s *info.ContainerStats) metricValues {
 					return metricValues{{value: float64(s.Memory.WorkingSet)}}
 				},
+			}, {
+				name:      "container_memory_max_usage_bytes",
+				help:      "Maximum memory usage recorded in bytes.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.MaxUsage)}}
+				},
+			}, {
+				name:      "container_memory_failcnt",
+				help:      "Number of memory usage hits limits.",
+				valueType: prometheus.CounterValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.Failcnt)}}
+				},
+			}, {
+				name:      "container_memory_cache",
+				help:      "Number of bytes of page cache memory.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.Cache)}}
+				},
+			}, {
+				name:      "container_memory_rss",
+				help:      "Size of RSS in bytes.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.RSS)}}
+				},
+			}, {
+				name:      "container_memory_rss_huge",
+				help:      "Size of RSS of transparent huge pages in bytes.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.RSSHuge)}}
+				},
+			}, {
+				name:      "container_memory_mapped_file",
+				help:      "Size of memory mapped files in bytes.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.MappedFile)}}
+				},
+			}, {
+				name:      "container_memory_swap",
+				help:      "Container swap usage in bytes.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.Swap)}}
+				},
+			}, {
+				name:      "container_memory_writeback",
+				help:      "Number of bytes of file cache queued for syncing to disk.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.Writeback)}}
+				},
+			}, {
+				name:      "container_memory_active_file",
+				help:      "Number of bytes of file cache on the active LRU list.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.ActiveFile)}}
+				},
+			}, {
+				name:      "container_memory_inactive_file",
+				help:      "Number of bytes of file cache on the inactive LRU list.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.InactiveFile)}}
+				},
+			}, {
+				name:      "container_memory_kernel_usage",
+				help:      "Size of kernel memory allocated in bytes.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Memory.KernelUsage)}}
+				},
 			}, {
 				name:        "container_memory_failures_total",
 				help:        "Cumulative count of memory allocation failures.",
@@ -156,6 +348,29 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 						},
 					}
 				},
+			}, {
+				name:      "container_pids",
+				help:      "Number of tasks in the pids cgroup of the container.",
+				valueType: prometheus.GaugeValue,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Processes.Pids)}}
+				},
+			}, {
+				name:      "container_threads",
+				help:      "Number of threads running inside the container.",
+				valueType: prometheus.GaugeValue,
+				condition: hasProcessMetrics,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Processes.ThreadCount)}}
+				},
+			}, {
+				name:      "container_file_descriptors",
+				help:      "Number of open file descriptors of the container.",
+				valueType: prometheus.GaugeValue,
+				condition: hasFdMetrics,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Processes.FdCount)}}
+				},
 			}, {
 				name:        "container_fs_limit_bytes",
 				help:        "Number of bytes that can be consumed by the container on this filesystem.",
@@ -342,6 +557,90 @@ func NewPrometheusCollector(infoProvider subcontainersInfoProvider) *PrometheusC
 				getValues: func(s *info.ContainerStats) metricValues {
 					return metricValues{{value: float64(s.Network.TxErrors)}}
 				},
+			}, {
+				name:        "container_network_tcp_usage_total",
+				help:        "Number of TCP sockets in given state",
+				extraLabels: []string{"tcp_state"},
+				valueType:   prometheus.GaugeValue,
+				condition:   hasTcpMetrics,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return tcpValues(s.Network.Tcp)
+				},
+			}, {
+				name:        "container_network_tcp6_usage_total",
+				help:        "Number of TCP6 sockets in given state",
+				extraLabels: []string{"tcp_state"},
+				valueType:   prometheus.GaugeValue,
+				condition:   hasTcpMetrics,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return tcpValues(s.Network.Tcp6)
+				},
+			}, {
+				name:        "container_network_udp_usage_total",
+				help:        "Number of UDP sockets in given state",
+				extraLabels: []string{"udp_state"},
+				valueType:   prometheus.GaugeValue,
+				condition:   hasUdpMetrics,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return udpValues(s.Network.Udp)
+				},
+			}, {
+				name:        "container_network_udp6_usage_total",
+				help:        "Number of UDP6 sockets in given state",
+				extraLabels: []string{"udp_state"},
+				valueType:   prometheus.GaugeValue,
+				condition:   hasUdpMetrics,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return udpValues(s.Network.Udp6)
+				},
+			}, {
+				name:      "container_pressure_cpu_waiting_seconds_total",
+				help:      "Total time some tasks were stalled on cpu.",
+				valueType: prometheus.CounterValue,
+				condition: hasPressure,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Pressure.Cpu.Some.Total) / float64(time.Second/time.Microsecond)}}
+				},
+			}, {
+				name:      "container_pressure_cpu_stalled_seconds_total",
+				help:      "Total time all non-idle tasks were stalled on cpu.",
+				valueType: prometheus.CounterValue,
+				condition: hasPressure,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Pressure.Cpu.Full.Total) / float64(time.Second/time.Microsecond)}}
+				},
+			}, {
+				name:      "container_pressure_memory_waiting_seconds_total",
+				help:      "Total time some tasks were stalled on memory.",
+				valueType: prometheus.CounterValue,
+				condition: hasPressure,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Pressure.Memory.Some.Total) / float64(time.Second/time.Microsecond)}}
+				},
+			}, {
+				name:      "container_pressure_memory_stalled_seconds_total",
+				help:      "Total time all non-idle tasks were stalled on memory.",
+				valueType: prometheus.CounterValue,
+				condition: hasPressure,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Pressure.Memory.Full.Total) / float64(time.Second/time.Microsecond)}}
+				},
+			}, {
+				name:      "container_pressure_io_waiting_seconds_total",
+				help:      "Total time some tasks were stalled on io.",
+				valueType: prometheus.CounterValue,
+				condition: hasPressure,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Pressure.Io.Some.Total) / float64(time.Second/time.Microsecond)}}
+				},
+			}, {
+				name:      "container_pressure_io_stalled_seconds_total",
+				help:      "Total time all non-idle tasks were stalled on io.",
+				valueType: prometheus.CounterValue,
+				condition: hasPressure,
+				getValues: func(s *info.ContainerStats) metricValues {
+					return metricValues{{value: float64(s.Pressure.Io.Full.Total) / float64(time.Second/time.Microsecond)}}
+				},
 			}, {
 				name:        "container_tasks_state",
 				help:        "Number of tasks in given state",
@@ -384,6 +683,9 @@ func (c *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 	for _, cm := range c.containerMetrics {
 		ch <- cm.desc()
 	}
+	if _, ok := c.infoProvider.(eventTotalsProvider); ok {
+		ch <- eventsTotalDesc
+	}
 }
 
 // Collect fetches the stats from all containers and delivers them as
@@ -404,11 +706,23 @@ func (c *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
 		stats := container.Stats[0]
 
 		for _, cm := range c.containerMetrics {
+			if cm.condition != nil && !cm.condition(container.Spec) {
+				continue
+			}
 			desc := cm.desc()
 			for _, metricValue := range cm.getValues(stats) {
 				ch <- prometheus.MustNewConstMetric(desc, cm.valueType, float64(metricValue.value), append([]string{name, id}, metricValue.labels...)...)
 			}
 		}
 	}
+	if provider, ok := c.infoProvider.(eventTotalsProvider); ok {
+		for _, total := range provider.GetEventTotals() {
+			name := total.ContainerName
+			if total.Alias != "" {
+				name = total.Alias
+			}
+			ch <- prometheus.MustNewConstMetric(eventsTotalDesc, prometheus.CounterValue, float64(total.Count), name, total.ContainerName, string(total.EventType))
+		}
+	}
 	c.errors.Collect(ch)
 }
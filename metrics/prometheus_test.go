@@ -15,6 +15,7 @@
 package metrics
 
 import (
+	"flag"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -22,8 +23,10 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/cadvisor/events"
 	info "github.com/google/cadvisor/info/v1"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 type testSubcontainersInfoProvider struct{}
@@ -34,6 +37,9 @@ func (p testSubcontainersInfoProvider) SubcontainersInfo(string, *info.Container
 			ContainerReference: info.ContainerReference{
 				Name: "testcontainer",
 			},
+			Spec: info.ContainerSpec{
+				HasPressure: true,
+			},
 			Stats: []*info.ContainerStats{
 				{
 					Cpu: info.CpuStats{
@@ -43,10 +49,31 @@ func (p testSubcontainersInfoProvider) SubcontainersInfo(string, *info.Container
 							User:   6,
 							System: 7,
 						},
+						CFS: info.CpuCFS{
+							Periods:          723,
+							ThrottledPeriods: 18,
+							ThrottledTime:    1724314000,
+						},
+						LoadAverages: &info.CpuLoadAverages{
+							OneMinute:      1.5,
+							FiveMinutes:    0.75,
+							FifteenMinutes: 0.25,
+						},
 					},
 					Memory: info.MemoryStats{
-						Usage:      8,
-						WorkingSet: 9,
+						Usage:        8,
+						WorkingSet:   9,
+						MaxUsage:     55,
+						Failcnt:      56,
+						Cache:        57,
+						RSS:          58,
+						RSSHuge:      59,
+						MappedFile:   60,
+						Swap:         61,
+						Writeback:    62,
+						ActiveFile:   63,
+						InactiveFile: 64,
+						KernelUsage:  90,
 						ContainerData: info.MemoryStatsMemoryData{
 							Pgfault:    10,
 							Pgmajfault: 11,
@@ -68,6 +95,46 @@ func (p testSubcontainersInfoProvider) SubcontainersInfo(string, *info.Container
 							TxErrors:  20,
 							TxDropped: 21,
 						},
+						Tcp: info.TcpStat{
+							Established: 70,
+							SynSent:     71,
+							SynRecv:     72,
+							FinWait1:    73,
+							FinWait2:    74,
+							TimeWait:    75,
+							Close:       76,
+							CloseWait:   77,
+							LastAck:     78,
+							Listen:      79,
+							Closing:     80,
+						},
+						Tcp6: info.TcpStat{
+							Established: 81,
+							Listen:      82,
+						},
+						Udp: info.UdpStat{
+							Listen:   83,
+							Dropped:  84,
+							RxQueued: 85,
+							TxQueued: 86,
+						},
+						Udp6: info.UdpStat{
+							Listen: 87,
+						},
+					},
+					Pressure: info.PressureStats{
+						Cpu: info.PSIStats{
+							Some: info.PSIData{Avg10: 1.5, Total: 1000000},
+							Full: info.PSIData{Total: 500000},
+						},
+						Memory: info.PSIStats{
+							Some: info.PSIData{Total: 2000000},
+							Full: info.PSIData{Total: 1500000},
+						},
+						Io: info.PSIStats{
+							Some: info.PSIData{Total: 250000},
+							Full: info.PSIData{Total: 125000},
+						},
 					},
 					Filesystem: []info.FsStats{
 						{
@@ -103,6 +170,11 @@ func (p testSubcontainersInfoProvider) SubcontainersInfo(string, *info.Container
 							WeightedIoTime:  49,
 						},
 					},
+					Processes: info.ProcessStats{
+						Pids:        65,
+						ThreadCount: 66,
+						FdCount:     67,
+					},
 					TaskStats: info.LoadStats{
 						NrSleeping:        50,
 						NrRunning:         51,
@@ -116,7 +188,21 @@ func (p testSubcontainersInfoProvider) SubcontainersInfo(string, *info.Container
 	}, nil
 }
 
+// Collects all the metrics, including the socket metrics that are disabled by
+// default.
+func enableAllMetrics(t *testing.T) func() {
+	disableMetrics := flag.Lookup("disable_metrics")
+	oldValue := disableMetrics.Value.String()
+	if err := disableMetrics.Value.Set(""); err != nil {
+		t.Fatalf("failed to enable all the metrics: %v", err)
+	}
+	return func() {
+		disableMetrics.Value.Set(oldValue)
+	}
+}
+
 func TestPrometheusCollector(t *testing.T) {
+	defer enableAllMetrics(t)()
 	prometheus.MustRegister(NewPrometheusCollector(testSubcontainersInfoProvider{}))
 
 	rw := httptest.NewRecorder()
@@ -146,3 +232,85 @@ func TestPrometheusCollector(t *testing.T) {
 		}
 	}
 }
+
+type testEventTotalsProvider struct {
+	testSubcontainersInfoProvider
+}
+
+func (p testEventTotalsProvider) GetEventTotals() []events.EventTotal {
+	return []events.EventTotal{
+		{EventType: info.EventOomKill, ContainerName: "/docker/abc", Alias: "web", Count: 3},
+	}
+}
+
+func TestPrometheusCollectorEventTotals(t *testing.T) {
+	ch := make(chan prometheus.Metric, 1000)
+	NewPrometheusCollector(testEventTotalsProvider{}).Collect(ch)
+	close(ch)
+
+	found := false
+	for metric := range ch {
+		if metric.Desc() != eventsTotalDesc {
+			continue
+		}
+		found = true
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatalf("failed to write metric: %v", err)
+		}
+		if m.GetCounter().GetValue() != 3 {
+			t.Errorf("expected 3 events, got %v", m.GetCounter().GetValue())
+		}
+		labels := make(map[string]string)
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		expected := map[string]string{"name": "web", "id": "/docker/abc", "type": string(info.EventOomKill)}
+		for name, value := range expected {
+			if labels[name] != value {
+				t.Errorf("expected label %s=%q, got %q", name, value, labels[name])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("container_events_total was not collected")
+	}
+}
+
+// Provider of the test container on a kernel without pressure stall information.
+type testNoPressureProvider struct {
+	testSubcontainersInfoProvider
+}
+
+func (p testNoPressureProvider) SubcontainersInfo(name string, query *info.ContainerInfoRequest) ([]*info.ContainerInfo, error) {
+	containers, err := p.testSubcontainersInfoProvider.SubcontainersInfo(name, query)
+	for _, container := range containers {
+		container.Spec.HasPressure = false
+	}
+	return containers, err
+}
+
+func TestPrometheusCollectorWithoutPressure(t *testing.T) {
+	ch := make(chan prometheus.Metric, 1000)
+	NewPrometheusCollector(testNoPressureProvider{}).Collect(ch)
+	close(ch)
+
+	for metric := range ch {
+		if strings.Contains(metric.Desc().String(), "container_pressure_") {
+			t.Errorf("unexpected metric %s", metric.Desc())
+		}
+	}
+}
+
+func TestPrometheusCollectorWithoutSocketMetrics(t *testing.T) {
+	ch := make(chan prometheus.Metric, 1000)
+	NewPrometheusCollector(testSubcontainersInfoProvider{}).Collect(ch)
+	close(ch)
+
+	for metric := range ch {
+		desc := metric.Desc().String()
+		if strings.Contains(desc, "container_network_tcp") || strings.Contains(desc, "container_network_udp") {
+			t.Errorf("unexpected metric %s", metric.Desc())
+		}
+	}
+}
@@ -242,6 +242,7 @@ func serveContainersPage(m manager.Manager, w http.ResponseWriter, u *url.URL) e
 		ResourcesAvailable:     cont.Spec.HasCpu || cont.Spec.HasMemory || cont.Spec.HasNetwork || cont.Spec.HasFilesystem,
 		CpuAvailable:           cont.Spec.HasCpu,
 		MemoryAvailable:        cont.Spec.HasMemory,
+		ProcessesAvailable:     cont.Spec.HasProcesses,
 		NetworkAvailable:       cont.Spec.HasNetwork,
 		FsAvailable:            cont.Spec.HasFilesystem,
 		CustomMetricsAvailable: cont.Spec.HasCustomMetrics,
@@ -143,6 +143,13 @@ const containersHtmlTemplate = `
             <h3 class="panel-title">Processes</h3>
           </div>
           <div id="processes-top" class="panel-body"></div>
+	  {{if .ProcessesAvailable}}
+          <div class="panel-body">
+            <h4>Counts</h4>
+	    <div id="process-count-chart"></div>
+	    <div id="process-limit"></div>
+          </div>
+	  {{end}}
 	</div>
 	{{if .CpuAvailable}}
 	<div class="panel panel-primary">
@@ -158,6 +165,8 @@ const containersHtmlTemplate = `
 	    <div id="cpu-per-core-usage-chart"></div>
             <h4>Usage Breakdown</h4>
 	    <div id="cpu-usage-breakdown-chart"></div>
+            <h4>Throttling</h4>
+	    <div id="cpu-throttling-chart"></div>
           </div>
 	</div>
 	{{end}}
@@ -184,6 +193,12 @@ const containersHtmlTemplate = `
               </div>
               <div class="col-sm-3" id="memory-text"></div>
 	    </div>
+            <h4>Usage by Type</h4>
+	    <div id="memory-breakdown-chart"></div>
+            <ul class="list-group">
+              <li class="list-group-item"><span class="stat-label">Max Usage</span> <span id="memory-max-usage"></span></li>
+              <li class="list-group-item"><span class="stat-label">Limit Hits</span> <span id="memory-failcnt"></span></li>
+            </ul>
           </div>
 	</div>
 	{{end}}
@@ -210,6 +225,10 @@ const containersHtmlTemplate = `
             <h4>Errors</h4>
 	    <div id="network-errors-chart"></div>
 	  </div>
+	  <div class="panel-body">
+            <h4>Retransmits and Drops</h4>
+	    <div id="network-protocol-chart"></div>
+	  </div>
 	</div>
         {{end}}
 	{{if .FsAvailable}}
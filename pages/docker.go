@@ -138,6 +138,7 @@ func serveDockerPage(m manager.Manager, w http.ResponseWriter, u *url.URL) error
 			ResourcesAvailable:     cont.Spec.HasCpu || cont.Spec.HasMemory || cont.Spec.HasNetwork,
 			CpuAvailable:           cont.Spec.HasCpu,
 			MemoryAvailable:        cont.Spec.HasMemory,
+			ProcessesAvailable:     cont.Spec.HasProcesses,
 			NetworkAvailable:       cont.Spec.HasNetwork,
 			FsAvailable:            cont.Spec.HasFilesystem,
 			CustomMetricsAvailable: cont.Spec.HasCustomMetrics,
@@ -55,6 +55,7 @@ type pageData struct {
 	ResourcesAvailable     bool
 	CpuAvailable           bool
 	MemoryAvailable        bool
+	ProcessesAvailable     bool
 	NetworkAvailable       bool
 	FsAvailable            bool
 	CustomMetricsAvailable bool
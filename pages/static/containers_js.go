@@ -240,6 +240,10 @@ function drawCpuPerCoreUsage(elementId, machineInfo, stats) {
 	if (stats.spec.has_cpu && !hasResource(stats, "cpu")) {
 		return;
 	}
+	// Per core usage is not available in the cgroup v2 unified hierarchy.
+	if (!stats.stats[0].cpu.usage.per_cpu_usage) {
+		return;
+	}
 
 	// Add a title for each core.
 	var titles = ["Time"];
@@ -284,6 +288,31 @@ function drawCpuUsageBreakdown(elementId, machineInfo, containerInfo) {
 	drawLineChart(titles, data, elementId, "Cores");
 }
 
+// Draw the graph for the percentage of CFS periods in which the container was throttled.
+function drawCpuThrottling(elementId, machineInfo, containerInfo) {
+	if (containerInfo.spec.has_cpu && !hasResource(containerInfo, "cpu")) {
+		return;
+	}
+
+	var titles = ["Time", "Throttled Periods"];
+	var data = [];
+	for (var i = 1; i < containerInfo.stats.length; i++) {
+		var cur = containerInfo.stats[i];
+		var prev = containerInfo.stats[i - 1];
+		var periods = cur.cpu.cfs.periods - prev.cpu.cfs.periods;
+
+		var elements = [];
+		elements.push(cur.timestamp);
+		if (periods > 0) {
+			elements.push((cur.cpu.cfs.throttled_periods - prev.cpu.cfs.throttled_periods) * 100 / periods);
+		} else {
+			elements.push(0);
+		}
+		data.push(elements);
+	}
+	drawLineChart(titles, data, elementId, "%");
+}
+
 // Draw the gauges for overall resource usage.
 function drawOverallUsage(elementId, machineInfo, containerInfo) {
 	var cur = containerInfo.stats[containerInfo.stats.length - 1];
@@ -375,6 +404,33 @@ function drawMemoryUsage(elementId, machineInfo, containerInfo) {
 	drawLineChart(titles, data, elementId, "Megabytes");
 }
 
+// Draw the graph for the breakdown of memory usage by type.
+function drawMemoryBreakdown(elementId, machineInfo, containerInfo) {
+	if (containerInfo.spec.has_memory && !hasResource(containerInfo, "memory")) {
+		return;
+	}
+
+	var titles = ["Time", "Cache", "RSS", "Mapped File", "Swap"];
+	var data = [];
+	for (var i = 0; i < containerInfo.stats.length; i++) {
+		var cur = containerInfo.stats[i];
+
+		var elements = [];
+		elements.push(cur.timestamp);
+		elements.push(cur.memory.cache / oneMegabyte);
+		elements.push(cur.memory.rss / oneMegabyte);
+		elements.push(cur.memory.mapped_file / oneMegabyte);
+		elements.push(cur.memory.swap / oneMegabyte);
+		data.push(elements);
+	}
+
+	var cur = containerInfo.stats[containerInfo.stats.length-1];
+	$("#memory-max-usage").text(humanizeIEC(cur.memory.max_usage));
+	$("#memory-failcnt").text(cur.memory.failcnt);
+
+	drawLineChart(titles, data, elementId, "Megabytes");
+}
+
 // Get the index of the interface with the specified name.
 function getNetworkInterfaceIndex(interfaceName, interfaces) {
 	for (var i = 0; i < interfaces.length; i++) {
@@ -449,6 +505,32 @@ function drawNetworkErrors(elementId, machineInfo, stats) {
 	drawLineChart(titles, data, elementId, "Errors per second");
 }
 
+// Draw the graph for TCP retransmits, resets and UDP buffer errors of the network namespace.
+function drawNetworkProtocol(elementId, machineInfo, stats) {
+	if (stats.spec.has_network && !hasResource(stats, "network")) {
+		return;
+	}
+
+	var titles = ["Time", "TCP Retransmits", "TCP Resets Sent", "TCP Failed Connections", "UDP Receive Buffer Errors", "UDP Send Buffer Errors"];
+	var data = [];
+	for (var i = 1; i < stats.stats.length; i++) {
+		var cur = stats.stats[i].network.protocol;
+		var prev = stats.stats[i - 1].network.protocol;
+		var intervalInSec = getInterval(stats.stats[i].timestamp, stats.stats[i - 1].timestamp) / 1000000000;
+
+		var elements = [];
+		elements.push(stats.stats[i].timestamp);
+		elements.push((cur.tcp_retrans_segs - prev.tcp_retrans_segs) / intervalInSec);
+		elements.push((cur.tcp_out_rsts - prev.tcp_out_rsts) / intervalInSec);
+		elements.push((cur.tcp_attempt_fails - prev.tcp_attempt_fails) / intervalInSec);
+		// UDP over IPv4 and IPv6.
+		elements.push((cur.udp_rcvbuf_errors + cur.udp6_rcvbuf_errors - prev.udp_rcvbuf_errors - prev.udp6_rcvbuf_errors) / intervalInSec);
+		elements.push((cur.udp_sndbuf_errors + cur.udp6_sndbuf_errors - prev.udp_sndbuf_errors - prev.udp6_sndbuf_errors) / intervalInSec);
+		data.push(elements);
+	}
+	drawLineChart(titles, data, elementId, "Events per second");
+}
+
 // Update the filesystem usage values.
 function drawFileSystemUsage(machineInfo, stats) {
 	var cur = stats.stats[stats.stats.length - 1];
@@ -576,6 +658,30 @@ function startFileSystemUsage(elementId, machineInfo, stats) {
 	drawFileSystemUsage(machineInfo, stats);
 }
 
+// Draw the graph for the number of tasks, threads and open files of the container.
+function drawProcessCounts(elementId, machineInfo, containerInfo) {
+	var titles = ["Time", "Tasks", "Threads", "Open Files"];
+	var data = [];
+	for (var i = 0; i < containerInfo.stats.length; i++) {
+		var cur = containerInfo.stats[i];
+
+		var elements = [];
+		elements.push(cur.timestamp);
+		elements.push(cur.processes.pids);
+		elements.push(cur.processes.thread_count);
+		elements.push(cur.processes.fd_count);
+		data.push(elements);
+	}
+
+	// Unlimited is reported as the largest value.
+	var limit = containerInfo.spec.processes.limit;
+	if (limit && limit < Math.pow(2, 53)) {
+		$("#process-limit").text("Task limit: " + limit);
+	}
+
+	drawLineChart(titles, data, elementId, "Count");
+}
+
 // Expects an array of closures to call. After each execution the JS runtime is given control back before continuing.
 // This function returns asynchronously
 function stepExecute(steps) {
@@ -619,6 +725,16 @@ function drawCharts(machineInfo, containerInfo) {
 		steps.push(function() {
 			drawCpuUsageBreakdown("cpu-usage-breakdown-chart", machineInfo, containerInfo);
 		});
+		steps.push(function() {
+			drawCpuThrottling("cpu-throttling-chart", machineInfo, containerInfo);
+		});
+	}
+
+	// Processes.
+	if (containerInfo.spec.has_processes) {
+		steps.push(function() {
+			drawProcessCounts("process-count-chart", machineInfo, containerInfo);
+		});
 	}
 
 	// Memory.
@@ -626,6 +742,9 @@ function drawCharts(machineInfo, containerInfo) {
 		steps.push(function() {
 			drawMemoryUsage("memory-usage-chart", machineInfo, containerInfo);
 		});
+		steps.push(function() {
+			drawMemoryBreakdown("memory-breakdown-chart", machineInfo, containerInfo);
+		});
 	}
 
 	// Network.
@@ -636,6 +755,9 @@ function drawCharts(machineInfo, containerInfo) {
 		steps.push(function() {
 			drawNetworkErrors("network-errors-chart", machineInfo, containerInfo);
 		});
+		steps.push(function() {
+			drawNetworkProtocol("network-protocol-chart", machineInfo, containerInfo);
+		});
 	}
 
 	// Filesystem.
@@ -39,6 +39,10 @@ const (
 	// Cumulative Cpu usage in system and user mode
 	colCpuCumulativeUsageSystem string = "cpu_cumulative_usage_system"
 	colCpuCumulativeUsageUser   string = "cpu_cumulative_usage_user"
+	// CFS throttling of the CPU
+	colCpuCfsPeriods          string = "cpu_cfs_periods"
+	colCpuCfsThrottledPeriods string = "cpu_cfs_throttled_periods"
+	colCpuCfsThrottledTime    string = "cpu_cfs_throttled_time"
 	// Memory usage
 	colMemoryUsage string = "memory_usage"
 	// Working set size
@@ -69,7 +73,7 @@ const (
 
 // TODO(jnagal): Infer schema through reflection. (See bigquery/client/example)
 func (self *bigqueryStorage) GetSchema() *bigquery.TableSchema {
-	fields := make([]*bigquery.TableFieldSchema, 19)
+	fields := make([]*bigquery.TableFieldSchema, 22)
 	i := 0
 	fields[i] = &bigquery.TableFieldSchema{
 		Type: typeTimestamp,
@@ -104,6 +108,21 @@ func (self *bigqueryStorage) GetSchema() *bigquery.TableSchema {
 		Name: colCpuCumulativeUsageUser,
 	}
 	i++
+	fields[i] = &bigquery.TableFieldSchema{
+		Type: typeInteger,
+		Name: colCpuCfsPeriods,
+	}
+	i++
+	fields[i] = &bigquery.TableFieldSchema{
+		Type: typeInteger,
+		Name: colCpuCfsThrottledPeriods,
+	}
+	i++
+	fields[i] = &bigquery.TableFieldSchema{
+		Type: typeInteger,
+		Name: colCpuCfsThrottledTime,
+	}
+	i++
 	fields[i] = &bigquery.TableFieldSchema{
 		Type: typeInteger,
 		Name: colMemoryUsage,
@@ -201,6 +220,11 @@ func (self *bigqueryStorage) containerStatsToRows(
 	// Cumulative Cpu Usage in user mode
 	row[colCpuCumulativeUsageUser] = stats.Cpu.Usage.User
 
+	// CFS throttling
+	row[colCpuCfsPeriods] = stats.Cpu.CFS.Periods
+	row[colCpuCfsThrottledPeriods] = stats.Cpu.CFS.ThrottledPeriods
+	row[colCpuCfsThrottledTime] = stats.Cpu.CFS.ThrottledTime
+
 	// Memory Usage
 	row[colMemoryUsage] = stats.Memory.Usage
 
@@ -15,6 +15,7 @@
 package influxdb
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -39,6 +40,10 @@ const (
 	colMachineName        string = "machine"
 	colContainerName      string = "container_name"
 	colCpuCumulativeUsage string = "cpu_cumulative_usage"
+	// CFS throttling of the CPU
+	colCpuCfsPeriods          string = "cpu_cfs_periods"
+	colCpuCfsThrottledPeriods string = "cpu_cfs_throttled_periods"
+	colCpuCfsThrottledTime    string = "cpu_cfs_throttled_time"
 	// Memory Usage
 	colMemoryUsage string = "memory_usage"
 	// Working set size
@@ -57,8 +62,15 @@ const (
 	colFsLimit = "fs_limit"
 	// Filesystem usage.
 	colFsUsage = "fs_usage"
+	// Type of an event.
+	colEventType = "event_type"
+	// Data of an event, as JSON.
+	colEventData = "event_data"
 )
 
+// Suffix of the name of the series of events.
+const eventsTableSuffix = "_events"
+
 func (self *influxdbStorage) getSeriesDefaultValues(
 	ref info.ContainerReference,
 	stats *info.ContainerStats,
@@ -115,6 +127,16 @@ func (self *influxdbStorage) containerStatsToValues(
 	columns = append(columns, colCpuCumulativeUsage)
 	values = append(values, stats.Cpu.Usage.Total)
 
+	// CFS throttling
+	columns = append(columns, colCpuCfsPeriods)
+	values = append(values, stats.Cpu.CFS.Periods)
+
+	columns = append(columns, colCpuCfsThrottledPeriods)
+	values = append(values, stats.Cpu.CFS.ThrottledPeriods)
+
+	columns = append(columns, colCpuCfsThrottledTime)
+	values = append(values, stats.Cpu.CFS.ThrottledTime)
+
 	// Memory Usage
 	columns = append(columns, colMemoryUsage)
 	values = append(values, stats.Memory.Usage)
@@ -175,6 +197,23 @@ func (self *influxdbStorage) AddStats(ref info.ContainerReference, stats *info.C
 	return nil
 }
 
+// Events are written right away to a separate series, with their data as JSON.
+func (self *influxdbStorage) AddEvent(event *info.Event) error {
+	data, err := json.Marshal(event.EventData)
+	if err != nil {
+		return err
+	}
+	columns := []string{colTimestamp, colMachineName, colContainerName, colEventType, colEventData}
+	values := []interface{}{event.Timestamp.UnixNano() / 1E3, self.machineName, event.ContainerName, string(event.EventType), string(data)}
+	series := self.newSeries(columns, values)
+	series.Name = self.tableName + eventsTableSuffix
+	err = self.client.WriteSeriesWithTimePrecision([]*influxdb.Series{series}, influxdb.Microsecond)
+	if err != nil {
+		return fmt.Errorf("failed to write event to influxDb - %s", err)
+	}
+	return nil
+}
+
 func (self *influxdbStorage) Close() error {
 	self.client = nil
 	return nil
@@ -28,6 +28,7 @@ type redisStorage struct {
 	machineName    string
 	redisKey       string
 	bufferDuration time.Duration
+	maxEvents      int
 	lastWrite      time.Time
 	lock           sync.Mutex
 	readyToFlush   func() bool
@@ -89,6 +90,33 @@ func (self *redisStorage) AddStats(ref info.ContainerReference, stats *info.Cont
 	return nil
 }
 
+type eventSpec struct {
+	Timestamp   int64       `json:"timestamp"`
+	MachineName string      `json:"machine_name,omitempty"`
+	Event       *info.Event `json:"event"`
+}
+
+// Events are pushed right away to their own key, which keeps only the latest
+// maxEvents of them.
+func (self *redisStorage) AddEvent(event *info.Event) error {
+	b, err := json.Marshal(&eventSpec{
+		Timestamp:   event.Timestamp.UnixNano() / 1E3,
+		MachineName: self.machineName,
+		Event:       event,
+	})
+	if err != nil {
+		return err
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	_, err = self.conn.Do("LPUSH", self.redisKey+"_events", b)
+	if err != nil {
+		return err
+	}
+	_, err = self.conn.Do("LTRIM", self.redisKey+"_events", 0, self.maxEvents-1)
+	return err
+}
+
 func (self *redisStorage) Close() error {
 	return self.conn.Close()
 }
@@ -98,10 +126,12 @@ func (self *redisStorage) Close() error {
 // instance is running on.
 // redisHost: The host which runs redis.
 // redisKey: The key for the Data that stored in the redis
+// maxEvents: The number of events kept in redis
 func New(machineName,
 	redisKey,
 	redisHost string,
 	bufferDuration time.Duration,
+	maxEvents int,
 ) (storage.StorageDriver, error) {
 	conn, err := redis.Dial("tcp", redisHost)
 	if err != nil {
@@ -112,6 +142,7 @@ func New(machineName,
 		machineName:    machineName,
 		redisKey:       redisKey,
 		bufferDuration: bufferDuration,
+		maxEvents:      maxEvents,
 		lastWrite:      time.Now(),
 	}
 	ret.readyToFlush = ret.defaultReadyToFlush
@@ -26,6 +26,10 @@ type statsdStorage struct {
 
 const (
 	colCpuCumulativeUsage string = "cpu_cumulative_usage"
+	// CFS throttling of the CPU
+	colCpuCfsPeriods          string = "cpu_cfs_periods"
+	colCpuCfsThrottledPeriods string = "cpu_cfs_throttled_periods"
+	colCpuCfsThrottledTime    string = "cpu_cfs_throttled_time"
 	// Memory Usage
 	colMemoryUsage string = "memory_usage"
 	// Working set size
@@ -54,6 +58,11 @@ func (self *statsdStorage) containerStatsToValues(
 	// Cumulative Cpu Usage
 	series[colCpuCumulativeUsage] = stats.Cpu.Usage.Total
 
+	// CFS throttling
+	series[colCpuCfsPeriods] = stats.Cpu.CFS.Periods
+	series[colCpuCfsThrottledPeriods] = stats.Cpu.CFS.ThrottledPeriods
+	series[colCpuCfsThrottledTime] = stats.Cpu.CFS.ThrottledTime
+
 	// Memory Usage
 	series[colMemoryUsage] = stats.Memory.Usage
 
@@ -16,7 +16,9 @@ package stdout
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	info "github.com/google/cadvisor/info/v1"
 )
@@ -27,6 +29,10 @@ type stdoutStorage struct {
 
 const (
 	colCpuCumulativeUsage = "cpu_cumulative_usage"
+	// CFS throttling of the CPU
+	colCpuCfsPeriods          = "cpu_cfs_periods"
+	colCpuCfsThrottledPeriods = "cpu_cfs_throttled_periods"
+	colCpuCfsThrottledTime    = "cpu_cfs_throttled_time"
 	// Memory Usage
 	colMemoryUsage = "memory_usage"
 	// Working set size
@@ -53,6 +59,11 @@ func (driver *stdoutStorage) containerStatsToValues(stats *info.ContainerStats)
 	// Cumulative Cpu Usage
 	series[colCpuCumulativeUsage] = stats.Cpu.Usage.Total
 
+	// CFS throttling
+	series[colCpuCfsPeriods] = stats.Cpu.CFS.Periods
+	series[colCpuCfsThrottledPeriods] = stats.Cpu.CFS.ThrottledPeriods
+	series[colCpuCfsThrottledTime] = stats.Cpu.CFS.ThrottledTime
+
 	// Memory Usage
 	series[colMemoryUsage] = stats.Memory.Usage
 
@@ -104,6 +115,15 @@ func (driver *stdoutStorage) AddStats(ref info.ContainerReference, stats *info.C
 	return err
 }
 
+func (driver *stdoutStorage) AddEvent(event *info.Event) error {
+	data, err := json.Marshal(event.EventData)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Printf("event cName=%s host=%s type=%s timestamp=%s data=%s\n", event.ContainerName, driver.Namespace, event.EventType, event.Timestamp.Format(time.RFC3339Nano), data)
+	return err
+}
+
 func (driver *stdoutStorage) Close() error {
 	return nil
 }
@@ -24,3 +24,8 @@ type StorageDriver interface {
 	// on the implementation of the storage driver.
 	Close() error
 }
+
+// Implemented by the storage drivers that can also store events.
+type EventStorageDriver interface {
+	AddEvent(event *info.Event) error
+}
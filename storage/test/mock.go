@@ -29,6 +29,11 @@ func (self *MockStorageDriver) AddStats(ref info.ContainerReference, stats *info
 	return args.Error(0)
 }
 
+func (self *MockStorageDriver) AddEvent(event *info.Event) error {
+	args := self.Called(event)
+	return args.Error(0)
+}
+
 func (self *MockStorageDriver) Close() error {
 	if self.MockCloseMethod {
 		args := self.Called()
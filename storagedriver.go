@@ -37,6 +37,7 @@ var argDbName = flag.String("storage_driver_db", "cadvisor", "database name")
 var argDbTable = flag.String("storage_driver_table", "stats", "table name")
 var argDbIsSecure = flag.Bool("storage_driver_secure", false, "use secure connection with database")
 var argDbBufferDuration = flag.Duration("storage_driver_buffer_duration", 60*time.Second, "Writes in the storage driver will be buffered for this duration, and committed to the non memory backends as a single transaction")
+var argRedisMaxEvents = flag.Int("storage_driver_redis_max_events", 10000, "Number of the latest events the redis storage driver keeps")
 var storageDuration = flag.Duration("storage_duration", 2*time.Minute, "How long to keep data stored (Default: 2min).")
 
 // Creates a memory storage with an optional backend storage option.
@@ -89,6 +90,7 @@ func NewMemoryStorage(backendStorageName string) (*memory.InMemoryCache, error)
 			*argDbName,
 			*argDbHost,
 			*argDbBufferDuration,
+			*argRedisMaxEvents,
 		)
 	case "statsd":
 		backendStorage, err = statsd.New(
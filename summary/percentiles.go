@@ -130,13 +130,16 @@ func NewResource(size int) *resource {
 func GetDerivedPercentiles(stats []*info.Usage) info.Usage {
 	cpu := NewResource(len(stats))
 	memory := NewResource(len(stats))
+	runqueueDelay := NewResource(len(stats))
 	for _, stat := range stats {
 		cpu.Add(stat.Cpu)
 		memory.Add(stat.Memory)
+		runqueueDelay.Add(stat.CpuRunqueueDelay)
 	}
 	usage := info.Usage{}
 	usage.Cpu = cpu.GetAllPercentiles()
 	usage.Memory = memory.GetAllPercentiles()
+	usage.CpuRunqueueDelay = runqueueDelay.GetAllPercentiles()
 	return usage
 }
 
@@ -169,11 +172,26 @@ func getCpuRate(latest, previous secondSample) (uint64, error) {
 	return cpuRate, nil
 }
 
+// Calculate the run-queue delay rate from two consecutive samples.
+func getRunqueueRate(latest, previous secondSample) (uint64, error) {
+	elapsed := latest.Timestamp.Sub(previous.Timestamp).Nanoseconds()
+	if elapsed < 10*milliSecondsToNanoSeconds {
+		return 0, fmt.Errorf("elapsed time too small: %d ns", elapsed)
+	}
+	// The delay is summed over the current tasks, so it drops when tasks exit.
+	if latest.RunqueueTime < previous.RunqueueTime {
+		return 0, fmt.Errorf("run-queue time dropped from %d to %d", previous.RunqueueTime, latest.RunqueueTime)
+	}
+	// The rate is calculated in milliseconds per second.
+	return (latest.RunqueueTime - previous.RunqueueTime) * secondsToMilliSeconds / uint64(elapsed), nil
+}
+
 // Returns a percentile sample for a minute by aggregating seconds samples.
 func GetMinutePercentiles(stats []*secondSample) info.Usage {
 	lastSample := secondSample{}
 	cpu := NewResource(len(stats))
 	memory := NewResource(len(stats))
+	runqueueDelay := NewResource(len(stats))
 	for _, stat := range stats {
 		if !lastSample.Timestamp.IsZero() {
 			cpuRate, err := getCpuRate(*stat, lastSample)
@@ -182,6 +200,10 @@ func GetMinutePercentiles(stats []*secondSample) info.Usage {
 			}
 			cpu.AddSample(cpuRate)
 			memory.AddSample(stat.Memory)
+			delay, err := getRunqueueRate(*stat, lastSample)
+			if err == nil {
+				runqueueDelay.AddSample(delay)
+			}
 		} else {
 			memory.AddSample(stat.Memory)
 		}
@@ -189,8 +211,9 @@ func GetMinutePercentiles(stats []*secondSample) info.Usage {
 	}
 	percent := getPercentComplete(stats)
 	return info.Usage{
-		PercentComplete: percent,
-		Cpu:             cpu.GetAllPercentiles(),
-		Memory:          memory.GetAllPercentiles(),
+		PercentComplete:  percent,
+		Cpu:              cpu.GetAllPercentiles(),
+		Memory:           memory.GetAllPercentiles(),
+		CpuRunqueueDelay: runqueueDelay.GetAllPercentiles(),
 	}
 }
@@ -150,6 +150,38 @@ func TestSamplesCloseInTimeIgnored(t *testing.T) {
 	}
 }
 
+func TestRunqueueDelay(t *testing.T) {
+	N := uint64(100)
+	var i uint64
+	ct := time.Now()
+	stats := make([]*secondSample, 0, N)
+	for i = 1; i < N; i++ {
+		s := &secondSample{
+			Timestamp: ct.Add(time.Duration(i) * time.Second),
+			Cpu:       i * Nanosecond,
+			// Tasks wait for a quarter of a second every second.
+			RunqueueTime: i * Nanosecond / 4,
+		}
+		stats = append(stats, s)
+	}
+	// The delay drops when a task that waited for 5 seconds exits, which is ignored.
+	for _, s := range stats[50:] {
+		s.RunqueueTime -= 5 * Nanosecond
+	}
+	usage := GetMinutePercentiles(stats)
+	delayExpected := info.Percentiles{
+		Present:    true,
+		Mean:       250,
+		Max:        250,
+		Fifty:      250,
+		Ninety:     250,
+		NinetyFive: 250,
+	}
+	if usage.CpuRunqueueDelay != delayExpected {
+		t.Errorf("run-queue delay stats are %+v. Expected %+v", usage.CpuRunqueueDelay, delayExpected)
+	}
+}
+
 func TestDerivedStats(t *testing.T) {
 	N := uint64(100)
 	var i uint64
@@ -31,9 +31,10 @@ import (
 
 // Usage fields we track for generating percentiles.
 type secondSample struct {
-	Timestamp time.Time // time when the sample was recorded.
-	Cpu       uint64    // cpu usage
-	Memory    uint64    // memory usage
+	Timestamp    time.Time // time when the sample was recorded.
+	Cpu          uint64    // cpu usage
+	Memory       uint64    // memory usage
+	RunqueueTime uint64    // time spent waiting for a cpu
 }
 
 type availableResources struct {
@@ -62,6 +63,7 @@ func (s *StatsSummary) AddSample(stat v1.ContainerStats) error {
 	sample.Timestamp = stat.Timestamp
 	if s.available.Cpu {
 		sample.Cpu = stat.Cpu.Usage.Total
+		sample.RunqueueTime = stat.Cpu.Schedstat.RunqueueTime
 	}
 	if s.available.Memory {
 		sample.Memory = stat.Memory.WorkingSet
@@ -107,6 +109,10 @@ func (s *StatsSummary) updateLatestUsage() {
 		if err == nil {
 			usage.Cpu = cpu
 		}
+		delay, err := getRunqueueRate(*latest, *previous)
+		if err == nil {
+			usage.CpuRunqueueDelay = delay
+		}
 	}
 
 	s.dataLock.Lock()
@@ -0,0 +1,57 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuload
+
+import (
+	"math"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+)
+
+// Computes the 1, 5 and 15 minute load averages of a container from samples
+// of its tasks, like the kernel does for /proc/loadavg: the load is the number
+// of runnable and uninterruptible tasks, and each average decays
+// exponentially with its window. The kernel samples every 5 seconds while
+// samples are taken on every housekeeping, so the decay accounts for the
+// time elapsed between samples. Like after a boot, the averages start at 0.
+type LoadAverager struct {
+	averages   info.CpuLoadAverages
+	lastSample time.Time
+}
+
+// Adds a sample of the tasks of the container and returns the new averages.
+// Samples older than the last one are ignored.
+func (self *LoadAverager) Update(stats info.LoadStats, timestamp time.Time) info.CpuLoadAverages {
+	if self.lastSample.IsZero() {
+		self.lastSample = timestamp
+		return self.averages
+	}
+	elapsed := timestamp.Sub(self.lastSample)
+	if elapsed <= 0 {
+		return self.averages
+	}
+	self.lastSample = timestamp
+
+	load := float64(stats.NrRunning + stats.NrUninterruptible)
+	decay := func(average float64, window time.Duration) float64 {
+		factor := math.Exp(-elapsed.Seconds() / window.Seconds())
+		return average*factor + load*(1-factor)
+	}
+	self.averages.OneMinute = decay(self.averages.OneMinute, time.Minute)
+	self.averages.FiveMinutes = decay(self.averages.FiveMinutes, 5*time.Minute)
+	self.averages.FifteenMinutes = decay(self.averages.FifteenMinutes, 15*time.Minute)
+	return self.averages
+}
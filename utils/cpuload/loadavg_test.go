@@ -0,0 +1,51 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpuload
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAverager(t *testing.T) {
+	var averager LoadAverager
+	start := time.Unix(1000, 0)
+	stats := info.LoadStats{NrRunning: 3, NrUninterruptible: 1, NrSleeping: 10}
+
+	// The averages start at 0.
+	assert.Equal(t, info.CpuLoadAverages{}, averager.Update(stats, start))
+
+	// Samples every 5 seconds for 15 minutes decay like a single sample.
+	var averages info.CpuLoadAverages
+	for i := 1; i <= 180; i++ {
+		averages = averager.Update(stats, start.Add(time.Duration(i)*5*time.Second))
+	}
+	assert.InDelta(t, 4*(1-math.Exp(-15)), averages.OneMinute, 1e-9)
+	assert.InDelta(t, 4*(1-math.Exp(-3)), averages.FiveMinutes, 1e-9)
+	assert.InDelta(t, 4*(1-math.Exp(-1)), averages.FifteenMinutes, 1e-9)
+
+	// Idle for a minute.
+	end := start.Add(16 * time.Minute)
+	averages = averager.Update(info.LoadStats{}, end)
+	assert.InDelta(t, 4*(1-math.Exp(-15))*math.Exp(-1), averages.OneMinute, 1e-9)
+
+	// Out of order samples are ignored.
+	assert.Equal(t, averages, averager.Update(stats, end.Add(-time.Second)))
+	assert.Equal(t, averages, averager.Update(stats, end))
+}
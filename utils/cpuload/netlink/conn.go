@@ -18,10 +18,27 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"io"
 	"os"
 	"syscall"
 )
 
+// A netlink connection, which tests replace with a fake.
+type connection interface {
+	WriteMessage(msg syscall.NetlinkMessage) error
+	ReadMessage() (syscall.NetlinkMessage, error)
+	Close() error
+}
+
+// Opens a generic netlink connection.
+func dialNetlink() (connection, error) {
+	conn, err := newConnection()
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
 type Connection struct {
 	// netlink socket
 	fd int
@@ -90,6 +107,6 @@ func (self *Connection) ReadMessage() (msg syscall.NetlinkMessage, err error) {
 		return msg, err
 	}
 	msg.Data = make([]byte, msg.Header.Len-syscall.NLMSG_HDRLEN)
-	_, err = self.rbuf.Read(msg.Data)
+	_, err = io.ReadFull(self.rbuf, msg.Data)
 	return msg, err
 }
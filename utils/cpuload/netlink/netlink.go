@@ -72,9 +72,22 @@ func padding(size int, alignment int) int {
 }
 
 // Get family id for taskstats subsystem.
-func getFamilyId(conn *Connection) (uint16, error) {
+// An error of the netlink socket itself rather than of a request, after which
+// the connection can not be trusted anymore.
+type connectionError struct {
+	err error
+}
+
+func (self connectionError) Error() string {
+	return fmt.Sprintf("netlink connection failed: %v", self.err)
+}
+
+func getFamilyId(conn connection) (uint16, error) {
 	msg := prepareFamilyMessage()
-	conn.WriteMessage(msg.toRawMsg())
+	err := conn.WriteMessage(msg.toRawMsg())
+	if err != nil {
+		return 0, err
+	}
 
 	resp, err := conn.ReadMessage()
 	if err != nil {
@@ -211,7 +224,7 @@ func verifyHeader(msg syscall.NetlinkMessage) error {
 	case syscall.NLMSG_ERROR:
 		buf := bytes.NewBuffer(msg.Data)
 		var errno int32
-		binary.Read(buf, Endian, errno)
+		binary.Read(buf, Endian, &errno)
 		return fmt.Errorf("netlink request failed with error %s", syscall.Errno(-errno))
 	}
 	return nil
@@ -221,16 +234,16 @@ func verifyHeader(msg syscall.NetlinkMessage) error {
 // id: family id for taskstats.
 // fd: fd to path to the cgroup directory under cpu hierarchy.
 // conn: open netlink connection used to communicate with kernel.
-func getLoadStats(id uint16, fd uintptr, conn *Connection) (info.LoadStats, error) {
+func getLoadStats(id uint16, fd uintptr, conn connection) (info.LoadStats, error) {
 	msg := prepareCmdMessage(id, fd)
 	err := conn.WriteMessage(msg.toRawMsg())
 	if err != nil {
-		return info.LoadStats{}, err
+		return info.LoadStats{}, connectionError{err}
 	}
 
 	resp, err := conn.ReadMessage()
 	if err != nil {
-		return info.LoadStats{}, err
+		return info.LoadStats{}, connectionError{err}
 	}
 
 	parsedmsg, err := parseLoadStatsResp(resp)
@@ -17,39 +17,70 @@ package netlink
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/golang/glog"
 	info "github.com/google/cadvisor/info/v1"
 )
 
 type NetlinkReader struct {
+	// Protects the connection, which is shared by the housekeeping of all containers.
+	lock     sync.Mutex
 	familyId uint16
-	conn     *Connection
+	conn     connection
+	stopped  bool
+
+	// Opens a new connection.
+	dial func() (connection, error)
 }
 
 func New() (*NetlinkReader, error) {
-	conn, err := newConnection()
+	return newReader(dialNetlink)
+}
+
+func newReader(dial func() (connection, error)) (*NetlinkReader, error) {
+	reader := &NetlinkReader{
+		dial: dial,
+	}
+	err := reader.connect()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create a new connection: %s", err)
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Opens a connection and looks up the taskstats family on it.
+func (self *NetlinkReader) connect() error {
+	conn, err := self.dial()
+	if err != nil {
+		return fmt.Errorf("failed to create a new connection: %s", err)
 	}
 
 	id, err := getFamilyId(conn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get netlink family id for task stats: %s", err)
+		conn.Close()
+		return fmt.Errorf("failed to get netlink family id for task stats: %s", err)
 	}
 	glog.V(4).Infof("Family id for taskstats: %d", id)
-	return &NetlinkReader{
-		familyId: id,
-		conn:     conn,
-	}, nil
+	self.familyId = id
+	self.conn = conn
+	return nil
 }
 
-func (self *NetlinkReader) Stop() {
+func (self *NetlinkReader) disconnect() {
 	if self.conn != nil {
 		self.conn.Close()
+		self.conn = nil
 	}
 }
 
+func (self *NetlinkReader) Stop() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.stopped = true
+	self.disconnect()
+}
+
 func (self *NetlinkReader) Start() error {
 	// We do the start setup for netlink in New(). Nothing to do here.
 	return nil
@@ -68,10 +99,38 @@ func (self *NetlinkReader) GetCpuLoad(name string, path string) (info.LoadStats,
 	if err != nil {
 		return info.LoadStats{}, fmt.Errorf("failed to open cgroup path %s: %q", path, err)
 	}
+	defer cfd.Close()
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.stopped {
+		return info.LoadStats{}, fmt.Errorf("netlink reader is stopped")
+	}
+
+	// A previous failure may have closed the connection.
+	if self.conn == nil {
+		err = self.connect()
+		if err != nil {
+			return info.LoadStats{}, err
+		}
+	}
 
 	stats, err := getLoadStats(self.familyId, cfd.Fd(), self.conn)
+	if _, ok := err.(connectionError); ok {
+		// Retry once on a new connection, which is kept for later requests.
+		glog.Warningf("Reconnecting the netlink load reader: %v", err)
+		self.disconnect()
+		err = self.connect()
+		if err != nil {
+			return info.LoadStats{}, err
+		}
+		stats, err = getLoadStats(self.familyId, cfd.Fd(), self.conn)
+		if _, ok := err.(connectionError); ok {
+			self.disconnect()
+		}
+	}
 	if err != nil {
-		return info.LoadStats{}, err
+		return info.LoadStats{}, fmt.Errorf("failed to get load stats of %q: %v", name, err)
 	}
 	glog.V(4).Infof("Task stats for %q: %+v", path, stats)
 	return stats, nil
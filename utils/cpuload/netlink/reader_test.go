@@ -0,0 +1,179 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+
+	info "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFamilyId = 22
+
+// A fake netlink connection that replies to requests with the taskstats
+// family id or the configured load stats, or fails.
+type fakeConnection struct {
+	stats    info.LoadStats
+	errno    syscall.Errno
+	readErr  error
+	requests []syscall.NetlinkMessage
+	closed   bool
+}
+
+func (self *fakeConnection) WriteMessage(msg syscall.NetlinkMessage) error {
+	self.requests = append(self.requests, msg)
+	return nil
+}
+
+func (self *fakeConnection) ReadMessage() (syscall.NetlinkMessage, error) {
+	request := self.requests[len(self.requests)-1]
+	buf := bytes.NewBuffer(nil)
+	binary.Write(buf, Endian, genMsghdr{Version: 1})
+	switch {
+	case request.Header.Type == genlIdCtrl:
+		addAttribute(buf, ctrlAttrFamilyId, uint16(testFamilyId), 2)
+		return syscall.NetlinkMessage{Header: syscall.NlMsghdr{Type: genlIdCtrl}, Data: buf.Bytes()}, nil
+	case self.readErr != nil:
+		return syscall.NetlinkMessage{}, self.readErr
+	case self.errno != 0:
+		errBuf := bytes.NewBuffer(nil)
+		binary.Write(errBuf, Endian, -int32(self.errno))
+		return syscall.NetlinkMessage{Header: syscall.NlMsghdr{Type: syscall.NLMSG_ERROR}, Data: errBuf.Bytes()}, nil
+	}
+	addAttribute(buf, 1, self.stats, binary.Size(self.stats))
+	return syscall.NetlinkMessage{Header: syscall.NlMsghdr{Type: testFamilyId}, Data: buf.Bytes()}, nil
+}
+
+func (self *fakeConnection) Close() error {
+	self.closed = true
+	return nil
+}
+
+// Creates a reader whose successive connections are the specified ones.
+func newTestReader(t *testing.T, conns ...*fakeConnection) *NetlinkReader {
+	dial := func() (connection, error) {
+		if len(conns) == 0 {
+			return nil, fmt.Errorf("no more connections")
+		}
+		conn := conns[0]
+		conns = conns[1:]
+		return conn, nil
+	}
+	reader, err := newReader(dial)
+	require.NoError(t, err)
+	return reader
+}
+
+func newTestCgroup(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestGetCpuLoad(t *testing.T) {
+	cgroup := newTestCgroup(t)
+	defer os.RemoveAll(cgroup)
+	conn := &fakeConnection{stats: info.LoadStats{NrSleeping: 5, NrRunning: 2, NrIoWait: 1}}
+	reader := newTestReader(t, conn)
+	defer reader.Stop()
+
+	stats, err := reader.GetCpuLoad("/test", cgroup)
+	require.NoError(t, err)
+	assert.Equal(t, conn.stats, stats)
+	require.Equal(t, 2, len(conn.requests))
+	assert.Equal(t, uint16(testFamilyId), conn.requests[1].Header.Type)
+}
+
+func TestGetCpuLoadReconnects(t *testing.T) {
+	cgroup := newTestCgroup(t)
+	defer os.RemoveAll(cgroup)
+	broken := &fakeConnection{readErr: syscall.ENOBUFS}
+	conn := &fakeConnection{stats: info.LoadStats{NrRunning: 3}}
+	reader := newTestReader(t, broken, conn)
+	defer reader.Stop()
+
+	stats, err := reader.GetCpuLoad("/test", cgroup)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.NrRunning)
+	assert.True(t, broken.closed)
+
+	// The new connection is kept.
+	_, err = reader.GetCpuLoad("/test", cgroup)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(conn.requests))
+}
+
+// The connection is reopened on the next request when reconnecting fails.
+func TestGetCpuLoadReconnectFails(t *testing.T) {
+	cgroup := newTestCgroup(t)
+	defer os.RemoveAll(cgroup)
+	reader := newTestReader(t, &fakeConnection{readErr: syscall.ENOBUFS})
+	defer reader.Stop()
+
+	_, err := reader.GetCpuLoad("/test", cgroup)
+	assert.Error(t, err)
+	assert.Nil(t, reader.conn)
+
+	reader.dial = func() (connection, error) {
+		return &fakeConnection{stats: info.LoadStats{NrRunning: 1}}, nil
+	}
+	stats, err := reader.GetCpuLoad("/test", cgroup)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.NrRunning)
+}
+
+// Errors of a request of a container do not reset the connection.
+func TestGetCpuLoadRequestError(t *testing.T) {
+	cgroup := newTestCgroup(t)
+	defer os.RemoveAll(cgroup)
+	conn := &fakeConnection{errno: syscall.EINVAL}
+	reader := newTestReader(t, conn)
+	defer reader.Stop()
+
+	_, err := reader.GetCpuLoad("/test", cgroup)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), syscall.EINVAL.Error())
+	assert.False(t, conn.closed)
+}
+
+func TestGetCpuLoadMissingCgroup(t *testing.T) {
+	reader := newTestReader(t, &fakeConnection{})
+	defer reader.Stop()
+
+	_, err := reader.GetCpuLoad("/test", "/does/not/exist")
+	assert.Error(t, err)
+	_, err = reader.GetCpuLoad("/test", "")
+	assert.Error(t, err)
+}
+
+func TestGetCpuLoadStopped(t *testing.T) {
+	cgroup := newTestCgroup(t)
+	defer os.RemoveAll(cgroup)
+	conn := &fakeConnection{}
+	reader := newTestReader(t, conn)
+	reader.Stop()
+	assert.True(t, conn.closed)
+
+	_, err := reader.GetCpuLoad("/test", cgroup)
+	assert.Error(t, err)
+}
@@ -0,0 +1,184 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oomparser
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const kmsgPath = "/dev/kmsg"
+
+// Has the boot time in its btime line.
+var procStatPath = "/proc/stat"
+
+// Maximum size of a record of /dev/kmsg, including its dictionary.
+const kmsgMaxRecordSize = 8192
+
+// A record of the kernel log buffer.
+type kmsgRecord struct {
+	// Time since boot the record was logged at.
+	timestamp time.Duration
+	// Lines of the message.
+	lines []string
+}
+
+// Parses a record of /dev/kmsg, which has a header with its priority,
+// sequence number, timestamp in microseconds and flags, and a message that
+// may be followed by dictionary lines, e.g.:
+//
+//	6,1150,5864708608,-;Killed process 13536 (memorymonster)
+//	 SUBSYSTEM=memory
+//
+// Non-printable characters of the message, like the newlines of multi-line
+// messages, are escaped as \xNN.
+func parseKmsgRecord(record string) (kmsgRecord, error) {
+	parts := strings.SplitN(record, ";", 2)
+	if len(parts) != 2 {
+		return kmsgRecord{}, fmt.Errorf("invalid kmsg record %q", record)
+	}
+	header := strings.Split(parts[0], ",")
+	if len(header) < 4 {
+		return kmsgRecord{}, fmt.Errorf("invalid kmsg header %q", parts[0])
+	}
+	timestamp, err := strconv.ParseUint(header[2], 10, 64)
+	if err != nil {
+		return kmsgRecord{}, fmt.Errorf("invalid kmsg timestamp %q: %v", header[2], err)
+	}
+
+	// Dictionary lines follow the message.
+	message := strings.SplitN(parts[1], "\n", 2)[0]
+	return kmsgRecord{
+		timestamp: time.Duration(timestamp) * time.Microsecond,
+		lines:     strings.Split(unescapeKmsg(message), "\n"),
+	}, nil
+}
+
+// Unescapes the \xNN sequences of a message of /dev/kmsg.
+func unescapeKmsg(message string) string {
+	if !strings.Contains(message, `\x`) {
+		return message
+	}
+	unescaped := make([]byte, 0, len(message))
+	for i := 0; i < len(message); i++ {
+		if message[i] == '\\' && i+3 < len(message) && message[i+1] == 'x' {
+			if c, err := strconv.ParseUint(message[i+2:i+4], 16, 8); err == nil {
+				unescaped = append(unescaped, byte(c))
+				i += 3
+				continue
+			}
+		}
+		unescaped = append(unescaped, message[i])
+	}
+	return string(unescaped)
+}
+
+// Returns the wall clock time the system booted at, which the timestamps of
+// /dev/kmsg are relative to. The kernel reports it to the second, so that it
+// is the same every time cAdvisor starts.
+func getBootTime() (time.Time, error) {
+	content, err := ioutil.ReadFile(procStatPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseBootTime(string(content))
+}
+
+// Parses the btime line of /proc/stat, e.g.: "btime 1420000000".
+func parseBootTime(procStat string) (time.Time, error) {
+	for _, line := range strings.Split(procStat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "btime" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid boot time %q: %v", fields[1], err)
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("no boot time in %q", procStatPath)
+}
+
+// Reads the kernel log from /dev/kmsg, where every read returns a record.
+type kmsgReader struct {
+	file     io.Reader
+	bootTime time.Time
+}
+
+// Opens /dev/kmsg, skipping the records already in the log buffer unless
+// fromStart is set.
+func openKmsg(fromStart bool) (*kmsgReader, error) {
+	file, err := os.Open(kmsgPath)
+	if err != nil {
+		return nil, err
+	}
+	bootTime, err := getBootTime()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to get the boot time: %v", err)
+	}
+	if !fromStart {
+		if _, err := file.Seek(0, os.SEEK_END); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to seek to the end of %q: %v", kmsgPath, err)
+		}
+	}
+	return &kmsgReader{
+		file:     file,
+		bootTime: bootTime,
+	}, nil
+}
+
+// Sends the lines of the records of the kernel log over a channel. Returns
+// when the log ends, or with an error when it can not be read, e.g.: when
+// reading it is restricted to privileged users.
+func (self *kmsgReader) readLines(lineChannel chan logLine) error {
+	buf := make([]byte, kmsgMaxRecordSize)
+	for {
+		n, err := self.file.Read(buf)
+		if pathErr, ok := err.(*os.PathError); ok {
+			err = pathErr.Err
+		}
+		if err == syscall.EPIPE {
+			// The kernel overwrote records before they were read.
+			glog.Warningf("Kernel log buffer overflowed, some OOM events may be missed")
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %v", kmsgPath, err)
+		}
+		record, err := parseKmsgRecord(string(buf[:n]))
+		if err != nil {
+			glog.V(4).Infof("Skipping kernel log record: %v", err)
+			continue
+		}
+		timestamp := self.bootTime.Add(record.timestamp)
+		for _, line := range record.lines {
+			lineChannel <- logLine{text: line, timestamp: timestamp}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oomparser
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const kmsgContainerLogFile = "kmsgContainerOomExampleLog.txt"
+const kmsgMemcgLogFile = "kmsgMemcgOomExampleLog.txt"
+const kmsgSystemLogFile = "kmsgSystemOomExampleLog.txt"
+
+var testBootTime = time.Unix(1420000000, 0)
+
+// A fake /dev/kmsg, which returns a record per read. Nil records are read
+// as an overflow of the log buffer. Reads fail with err after the records,
+// or with io.EOF when it is not set.
+type fakeKmsg struct {
+	records []*string
+	err     error
+}
+
+func (self *fakeKmsg) Read(buf []byte) (int, error) {
+	if len(self.records) == 0 {
+		if self.err != nil {
+			return 0, self.err
+		}
+		return 0, io.EOF
+	}
+	record := self.records[0]
+	self.records = self.records[1:]
+	if record == nil {
+		return 0, syscall.EPIPE
+	}
+	return copy(buf, *record), nil
+}
+
+// Reads the records of a kmsg example log, whose dictionary lines start
+// with a space.
+func readKmsgLog(t *testing.T, file string) *fakeKmsg {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("had an error reading file: %v", err)
+	}
+	kmsg := &fakeKmsg{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		if strings.HasPrefix(line, " ") && len(kmsg.records) > 0 {
+			*kmsg.records[len(kmsg.records)-1] += line + "\n"
+			continue
+		}
+		record := line + "\n"
+		kmsg.records = append(kmsg.records, &record)
+	}
+	return kmsg
+}
+
+// Streams the OOMs of a fake /dev/kmsg until it ends.
+func streamKmsgOoms(kmsg *fakeKmsg) []*OomInstance {
+	parser := &OomParser{
+		kmsg: &kmsgReader{
+			file:     kmsg,
+			bootTime: testBootTime,
+		},
+	}
+	outStream := make(chan *OomInstance)
+	go parser.StreamOoms(outStream)
+	var ooms []*OomInstance
+	for oom := range outStream {
+		ooms = append(ooms, oom)
+	}
+	return ooms
+}
+
+func TestParseKmsgRecord(t *testing.T) {
+	record, err := parseKmsgRecord("6,1150,5864708608,-;Killed process 13536 (memorymonster)\n SUBSYSTEM=memory\n")
+	if err != nil {
+		t.Fatalf("good record fed to parseKmsgRecord should yield no error, but had error %v", err)
+	}
+	expected := kmsgRecord{
+		timestamp: 5864708608 * time.Microsecond,
+		lines:     []string{"Killed process 13536 (memorymonster)"},
+	}
+	if !reflect.DeepEqual(expected, record) {
+		t.Errorf("parseKmsgRecord should have returned %+v, not %+v", expected, record)
+	}
+
+	for _, bad := range []string{"no header", "6,1150;message", "6,1150,now,-;message"} {
+		if _, err := parseKmsgRecord(bad); err == nil {
+			t.Errorf("bad record %q fed to parseKmsgRecord should yield an error", bad)
+		}
+	}
+}
+
+func TestUnescapeKmsg(t *testing.T) {
+	for escaped, expected := range map[string]string{
+		`no escapes`:            "no escapes",
+		`anon 1\x0afile 0`:      "anon 1\nfile 0",
+		`back\x5cslash`:         `back\slash`,
+		`truncated \x0`:         `truncated \x0`,
+		`not hex \xzz`:          `not hex \xzz`,
+		`\x41\x42 at the start`: "AB at the start",
+	} {
+		if unescaped := unescapeKmsg(escaped); unescaped != expected {
+			t.Errorf("unescapeKmsg(%q) should have returned %q, not %q", escaped, expected, unescaped)
+		}
+	}
+}
+
+func TestStreamKmsgOoms(t *testing.T) {
+	pageSize := uint64(os.Getpagesize())
+	for _, test := range []struct {
+		file     string
+		expected OomInstance
+	}{
+		{
+			file: kmsgContainerLogFile,
+			expected: OomInstance{
+				Pid:                 13536,
+				ProcessName:         "memorymonster",
+				TimeOfDeath:         testBootTime.Add(5864708608 * time.Microsecond),
+				ContainerName:       "/mem2",
+				VictimContainerName: "/mem3",
+				MemoryUsage:         980 * 1024,
+				MemoryLimit:         980 * 1024,
+				MemoryFailcnt:       4152239,
+				GfpMask:             "0xd0",
+				Tasks: []OomTask{
+					{Pid: 13536, Uid: 275858, Tgid: 13536, TotalVm: 8389663 * pageSize, Rss: 343 * pageSize, Name: "memorymonster"},
+				},
+			},
+		},
+		{
+			file: kmsgMemcgLogFile,
+			expected: OomInstance{
+				Pid:                 4242,
+				ProcessName:         "stress-ng-vm",
+				TimeOfDeath:         testBootTime.Add(91234568300 * time.Microsecond),
+				ContainerName:       "/docker/abc123",
+				VictimContainerName: "/docker/abc123",
+				MemoryUsage:         102400 * 1024,
+				MemoryLimit:         102400 * 1024,
+				MemoryFailcnt:       87,
+				GfpMask:             "0xcc0(GFP_KERNEL)",
+				Tasks: []OomTask{
+					{Pid: 4241, Tgid: 4241, TotalVm: 932 * pageSize, Rss: 160 * pageSize, Name: "stress-ng"},
+					{Pid: 4242, Tgid: 4242, TotalVm: 26533 * pageSize, Rss: 25376 * pageSize, OomScoreAdj: 1000, Name: "stress-ng-vm"},
+				},
+			},
+		},
+		{
+			file: kmsgSystemLogFile,
+			expected: OomInstance{
+				Pid:                 777,
+				ProcessName:         "app-server",
+				TimeOfDeath:         testBootTime.Add(3600000000400 * time.Microsecond),
+				ContainerName:       "/system.slice/app.service",
+				VictimContainerName: "/",
+				GfpMask:             "0x140cca(GFP_HIGHUSER_MOVABLE|__GFP_COMP)",
+				Tasks: []OomTask{
+					{Pid: 777, Uid: 1000, Tgid: 777, TotalVm: 4194304 * pageSize, Rss: 3932160 * pageSize, Name: "app-server"},
+				},
+			},
+		},
+	} {
+		ooms := streamKmsgOoms(readKmsgLog(t, test.file))
+		if len(ooms) != 1 {
+			t.Errorf("%s should have had 1 OOM, not %d", test.file, len(ooms))
+			continue
+		}
+		if !reflect.DeepEqual(test.expected, *ooms[0]) {
+			t.Errorf("wrong instance returned for %s. Expected %+v and got %+v", test.file, test.expected, *ooms[0])
+		}
+	}
+}
+
+// Reading continues after the log buffer overflowed.
+func TestStreamKmsgOomsOverflow(t *testing.T) {
+	kmsg := readKmsgLog(t, kmsgMemcgLogFile)
+	system := readKmsgLog(t, kmsgSystemLogFile)
+	kmsg.records = append(append(kmsg.records, nil), system.records...)
+
+	ooms := streamKmsgOoms(kmsg)
+	if len(ooms) != 2 {
+		t.Fatalf("should have had 2 OOMs, not %d", len(ooms))
+	}
+	if ooms[0].Pid != 4242 || ooms[1].Pid != 777 {
+		t.Errorf("should have had OOMs of pids 4242 and 777, not %d and %d", ooms[0].Pid, ooms[1].Pid)
+	}
+}
+
+// An OOM report that does not end with a kill is superseded by the next one.
+func TestStreamKmsgOomsUnfinished(t *testing.T) {
+	kmsg := readKmsgLog(t, kmsgContainerLogFile)
+	system := readKmsgLog(t, kmsgSystemLogFile)
+	// Drop the kill of the container OOM.
+	kmsg.records = append(kmsg.records[:len(kmsg.records)-2], system.records...)
+
+	ooms := streamKmsgOoms(kmsg)
+	if len(ooms) != 1 {
+		t.Fatalf("should have had 1 OOM, not %d", len(ooms))
+	}
+	if ooms[0].ContainerName != "/system.slice/app.service" || ooms[0].VictimContainerName != "/" {
+		t.Errorf("container names of an unfinished OOM should not be kept, had %q and %q", ooms[0].ContainerName, ooms[0].VictimContainerName)
+	}
+}
+
+func TestParseBootTime(t *testing.T) {
+	bootTime, err := parseBootTime("cpu  1 2 3 4\nintr 100\nbtime 1420000000\nprocesses 42\n")
+	if err != nil {
+		t.Fatalf("good /proc/stat fed to parseBootTime should yield no error, but had error %v", err)
+	}
+	if !bootTime.Equal(testBootTime) {
+		t.Errorf("parseBootTime should have returned %v, not %v", testBootTime, bootTime)
+	}
+	for _, bad := range []string{"cpu  1 2 3 4\n", "btime soon\n"} {
+		if _, err := parseBootTime(bad); err == nil {
+			t.Errorf("bad /proc/stat %q fed to parseBootTime should yield an error", bad)
+		}
+	}
+}
+
+// The kernel log files are read when /dev/kmsg can not be.
+func TestStreamKmsgOomsFallsBack(t *testing.T) {
+	parser := &OomParser{
+		kmsg: &kmsgReader{
+			file:     &fakeKmsg{err: syscall.EPERM},
+			bootTime: testBootTime,
+		},
+		fallback: func() (*bufio.Reader, error) {
+			file, err := os.Open(systemLogFile)
+			if err != nil {
+				return nil, err
+			}
+			return bufio.NewReader(file), nil
+		},
+	}
+	outStream := make(chan *OomInstance)
+	go parser.StreamOoms(outStream)
+	select {
+	case oom := <-outStream:
+		if oom.Pid != 1532 {
+			t.Errorf("should have had the OOM of pid 1532 from %s, not %d", systemLogFile, oom.Pid)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no OOM read from the kernel log file")
+	}
+}
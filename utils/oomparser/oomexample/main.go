@@ -27,7 +27,8 @@ func main() {
 	// out is a user-provided channel from which the user can read incoming
 	// OomInstance objects
 	outStream := make(chan *oomparser.OomInstance)
-	oomLog, err := oomparser.New()
+	// also report the OOMs already in the kernel log
+	oomLog, err := oomparser.New(true)
 	if err != nil {
 		glog.Infof("Couldn't make a new oomparser. %v", err)
 	} else {
@@ -23,6 +23,7 @@ import (
 	"path"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -36,9 +37,47 @@ var lastLineRegexp *regexp.Regexp = regexp.MustCompile(
 var firstLineRegexp *regexp.Regexp = regexp.MustCompile(
 	`invoked oom-killer:`)
 
+// Kernels since 4.19 summarize the OOM kill in a line like
+// "oom-kill:constraint=CONSTRAINT_MEMCG,...,oom_memcg=/mem2,task_memcg=/mem2/child,task=stress,pid=1234,uid=0".
+var oomKillRegexp *regexp.Regexp = regexp.MustCompile(
+	`oom-kill:(.*)`)
+
+// Lines of /dev/kmsg have no syslog timestamp.
+var kmsgLastLineRegexp *regexp.Regexp = regexp.MustCompile(
+	`Killed process ([0-9]+) \((.+?)\)`)
+
+// The start line reports the allocation that triggered the OOM, e.g.:
+// "invoked oom-killer: gfp_mask=0xcc0(GFP_KERNEL), order=0, oom_score_adj=0".
+var allocationRegexp *regexp.Regexp = regexp.MustCompile(
+	`gfp_mask=(0x[0-9a-f]+(?:\([^)]*\))?), order=(-?[0-9]+)`)
+
+// Memory counters of the cgroup whose limit was hit.
+var memoryUsageRegexp *regexp.Regexp = regexp.MustCompile(
+	`memory: usage ([0-9]+)kB, limit ([0-9]+)kB, failcnt ([0-9]+)`)
+
+// The task table starts with a header like
+// "[ pid ]   uid  tgid total_vm      rss nr_ptes swapents oom_score_adj name",
+// whose columns vary between kernel versions.
+var taskHeaderRegexp *regexp.Regexp = regexp.MustCompile(
+	`\[\s*pid\s*\]\s+(.*)`)
+var taskRegexp *regexp.Regexp = regexp.MustCompile(
+	`\[\s*([0-9]+)\]\s+(.*)`)
+
 // struct to hold file from which we obtain OomInstances
 type OomParser struct {
 	ioreader *bufio.Reader
+	// Reader of /dev/kmsg, which is used instead of ioreader when set.
+	kmsg *kmsgReader
+	// Opens the kernel log to read instead of /dev/kmsg when it can not be
+	// read, if set.
+	fallback func() (*bufio.Reader, error)
+}
+
+// A line of the kernel log. Only the lines of /dev/kmsg have a timestamp,
+// those of log files have it in their text.
+type logLine struct {
+	text      string
+	timestamp time.Time
 }
 
 // struct that contains information related to an OOM kill instance
@@ -47,27 +86,154 @@ type OomInstance struct {
 	Pid int
 	// the name of the killed process
 	ProcessName string
-	// the time that the process was reported to be killed,
-	// accurate to the minute
+	// the time that the process was reported to be killed, accurate to the
+	// microsecond when read from /dev/kmsg and to the second otherwise
 	TimeOfDeath time.Time
 	// the absolute name of the container that OOMed
 	ContainerName string
 	// the absolute name of the container that was killed
 	// due to the OOM.
 	VictimContainerName string
+	// memory usage, limit and failure count of the container whose limit
+	// was hit, in bytes. Not reported for system OOMs.
+	MemoryUsage   uint64
+	MemoryLimit   uint64
+	MemoryFailcnt uint64
+	// the gfp mask and order of the allocation that triggered the OOM
+	GfpMask string
+	Order   int
+	// the tasks that were considered for the OOM kill
+	Tasks []OomTask
+}
+
+// struct that contains information related to a task of the OOM report
+type OomTask struct {
+	Pid  int
+	Uid  int
+	Tgid int
+	// virtual memory size and resident set size of the task, in bytes
+	TotalVm     uint64
+	Rss         uint64
+	OomScoreAdj int
+	Name        string
 }
 
 // gets the container name from a line and adds it to the oomInstance.
 func getContainerName(line string, currentOomInstance *OomInstance) error {
 	parsedLine := containerRegexp.FindStringSubmatch(line)
+	if parsedLine != nil {
+		currentOomInstance.ContainerName = path.Join("/", parsedLine[1])
+		currentOomInstance.VictimContainerName = path.Join("/", parsedLine[2])
+		return nil
+	}
+
+	parsedLine = oomKillRegexp.FindStringSubmatch(line)
 	if parsedLine == nil {
 		return nil
 	}
-	currentOomInstance.ContainerName = path.Join("/", parsedLine[1])
-	currentOomInstance.VictimContainerName = path.Join("/", parsedLine[2])
+	// Values like the nodemask may have commas, so only the known keys are used.
+	for _, field := range strings.Split(parsedLine[1], ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "task_memcg":
+			currentOomInstance.ContainerName = path.Join("/", kv[1])
+		case "oom_memcg":
+			currentOomInstance.VictimContainerName = path.Join("/", kv[1])
+		}
+	}
 	return nil
 }
 
+// gets the allocation and memory usage details from a line and adds them to
+// the oomInstance.
+func getOomDetails(line string, currentOomInstance *OomInstance) {
+	if reList := allocationRegexp.FindStringSubmatch(line); reList != nil {
+		currentOomInstance.GfpMask = reList[1]
+		currentOomInstance.Order, _ = strconv.Atoi(reList[2])
+		return
+	}
+	if reList := memoryUsageRegexp.FindStringSubmatch(line); reList != nil {
+		usage, _ := strconv.ParseUint(reList[1], 10, 64)
+		limit, _ := strconv.ParseUint(reList[2], 10, 64)
+		failcnt, _ := strconv.ParseUint(reList[3], 10, 64)
+		currentOomInstance.MemoryUsage = usage * 1024
+		currentOomInstance.MemoryLimit = limit * 1024
+		currentOomInstance.MemoryFailcnt = failcnt
+	}
+}
+
+// gets the columns of the task table from its header line, or nil if the
+// line is not the header.
+func getTaskColumns(line string) []string {
+	reList := taskHeaderRegexp.FindStringSubmatch(line)
+	if reList == nil {
+		return nil
+	}
+	return strings.Fields(reList[1])
+}
+
+// gets a task from a line of the task table with the given columns.
+func getTask(line string, columns []string) (OomTask, bool) {
+	reList := taskRegexp.FindStringSubmatch(line)
+	if reList == nil {
+		return OomTask{}, false
+	}
+	pid, err := strconv.Atoi(reList[1])
+	if err != nil {
+		return OomTask{}, false
+	}
+	// The name is the last column and may have spaces.
+	values := strings.Fields(reList[2])
+	if len(values) < len(columns) || len(columns) == 0 || columns[len(columns)-1] != "name" {
+		return OomTask{}, false
+	}
+	task := OomTask{
+		Pid:  pid,
+		Name: strings.Join(values[len(columns)-1:], " "),
+	}
+	pageSize := uint64(os.Getpagesize())
+	for i, column := range columns[:len(columns)-1] {
+		var err error
+		switch column {
+		case "uid":
+			task.Uid, err = strconv.Atoi(values[i])
+		case "tgid":
+			task.Tgid, err = strconv.Atoi(values[i])
+		case "total_vm":
+			task.TotalVm, err = strconv.ParseUint(values[i], 10, 64)
+			task.TotalVm *= pageSize
+		case "rss":
+			task.Rss, err = strconv.ParseUint(values[i], 10, 64)
+			task.Rss *= pageSize
+		case "oom_score_adj":
+			task.OomScoreAdj, err = strconv.Atoi(values[i])
+		}
+		if err != nil {
+			return OomTask{}, false
+		}
+	}
+	return task, true
+}
+
+// gets the pid and name from a line of /dev/kmsg and adds them to oomInstance
+func getKmsgProcessNamePid(line logLine, currentOomInstance *OomInstance) bool {
+	reList := kmsgLastLineRegexp.FindStringSubmatch(line.text)
+	if reList == nil {
+		return false
+	}
+	pid, err := strconv.Atoi(reList[1])
+	if err != nil {
+		return false
+	}
+	currentOomInstance.Pid = pid
+	currentOomInstance.ProcessName = reList[2]
+	currentOomInstance.TimeOfDeath = line.timestamp
+	return true
+}
+
 // gets the pid, name, and date from a line and adds it to oomInstance
 func getProcessNamePid(line string, currentOomInstance *OomInstance) (bool, error) {
 	reList := lastLineRegexp.FindStringSubmatch(line)
@@ -105,7 +271,7 @@ func checkIfStartOfOomMessages(line string) bool {
 // Should prevent EOF errors that occur when lines are read before being fully
 // written to the log. It reads line by line splitting on
 // the "\n" character.
-func readLinesFromFile(lineChannel chan string, ioreader *bufio.Reader) {
+func readLinesFromFile(lineChannel chan logLine, ioreader *bufio.Reader) {
 	linefragment := ""
 	var line string
 	var err error
@@ -121,48 +287,93 @@ func readLinesFromFile(lineChannel chan string, ioreader *bufio.Reader) {
 				line = linefragment + line
 				linefragment = ""
 			}
-			lineChannel <- line
+			lineChannel <- logLine{text: line}
 		} else if err != nil && err != io.EOF {
 			glog.Errorf("exiting analyzeLinesHelper with error %v", err)
 		}
 	}
 }
 
-// Calls goroutine for readLinesFromFile, which feeds it complete lines.
-// Lines are checked against a regexp to check for the pid, process name, etc.
-// At the end of an oom message group, StreamOoms adds the new oomInstance to
-// oomLog
+// Calls goroutine for readLinesFromFile or the /dev/kmsg reader, which feeds
+// it complete lines. Lines are checked against a regexp to check for the pid,
+// process name, etc. At the end of an oom message group, StreamOoms adds the
+// new oomInstance to oomLog. outStream is closed when the kernel log can not
+// be read anymore.
 func (self *OomParser) StreamOoms(outStream chan *OomInstance) {
-	lineChannel := make(chan string, 10)
-	go func() {
-		readLinesFromFile(lineChannel, self.ioreader)
-	}()
+	defer close(outStream)
+	lineChannel := make(chan logLine, 10)
+	go self.readLines(lineChannel)
 
+	var oomCurrentInstance *OomInstance
+	// columns of the task table of the current OOM report, once seen
+	var taskColumns []string
 	for line := range lineChannel {
-		in_oom_kernel_log := checkIfStartOfOomMessages(line)
-		if in_oom_kernel_log {
-			oomCurrentInstance := &OomInstance{
-				ContainerName: "/",
+		// A new OOM report starts over an unfinished one, e.g.: when the
+		// victim exited before it was killed.
+		if checkIfStartOfOomMessages(line.text) {
+			oomCurrentInstance = &OomInstance{
+				ContainerName:       "/",
+				VictimContainerName: "/",
 			}
-			finished := false
-			for !finished {
-				err := getContainerName(line, oomCurrentInstance)
-				if err != nil {
-					glog.Errorf("%v", err)
-				}
-				finished, err = getProcessNamePid(line, oomCurrentInstance)
-				if err != nil {
-					glog.Errorf("%v", err)
-				}
-				line = <-lineChannel
+			taskColumns = nil
+		}
+		if oomCurrentInstance == nil {
+			continue
+		}
+		getOomDetails(line.text, oomCurrentInstance)
+		if columns := getTaskColumns(line.text); columns != nil {
+			taskColumns = columns
+		} else if taskColumns != nil {
+			if task, ok := getTask(line.text, taskColumns); ok {
+				oomCurrentInstance.Tasks = append(oomCurrentInstance.Tasks, task)
+			}
+		}
+		err := getContainerName(line.text, oomCurrentInstance)
+		if err != nil {
+			glog.Errorf("%v", err)
+		}
+		var finished bool
+		if line.timestamp.IsZero() {
+			finished, err = getProcessNamePid(line.text, oomCurrentInstance)
+			if err != nil {
+				glog.Errorf("%v", err)
 			}
-			in_oom_kernel_log = false
+		} else {
+			finished = getKmsgProcessNamePid(line, oomCurrentInstance)
+		}
+		if finished {
 			outStream <- oomCurrentInstance
+			oomCurrentInstance = nil
 		}
 	}
 	glog.Infof("exiting analyzeLines")
 }
 
+// Sends the lines of the kernel log over a channel, which is closed when the
+// log can not be read anymore.
+func (self *OomParser) readLines(lineChannel chan logLine) {
+	defer close(lineChannel)
+	if self.kmsg == nil {
+		readLinesFromFile(lineChannel, self.ioreader)
+		return
+	}
+	err := self.kmsg.readLines(lineChannel)
+	if err == nil {
+		return
+	}
+	if self.fallback == nil {
+		glog.Errorf("%v", err)
+		return
+	}
+	glog.Warningf("OOM parser falling back to the kernel log files: %v", err)
+	ioreader, err := self.fallback()
+	if err != nil {
+		glog.Errorf("OOM parser could not read the kernel log: %v", err)
+		return
+	}
+	readLinesFromFile(lineChannel, ioreader)
+}
+
 func callJournalctl() (io.ReadCloser, error) {
 	cmd := exec.Command("journalctl", "-f")
 	readcloser, err := cmd.StdoutPipe()
@@ -175,16 +386,13 @@ func callJournalctl() (io.ReadCloser, error) {
 	return readcloser, err
 }
 
-func trySystemd() (*OomParser, error) {
+func trySystemd() (*bufio.Reader, error) {
 	readcloser, err := callJournalctl()
 	if err != nil {
 		return nil, err
 	}
 	glog.Infof("oomparser using systemd")
-	return &OomParser{
-		ioreader: bufio.NewReader(readcloser),
-	}, nil
-
+	return bufio.NewReader(readcloser), nil
 }
 
 // List of possible kernel log files. These are prioritized in order so that
@@ -203,9 +411,9 @@ func getSystemFile() (string, error) {
 	return "", fmt.Errorf("unable to find any kernel log file available from our set: %v", kernelLogFiles)
 }
 
-// initializes an OomParser object and calls getSystemFile to set the systemFile
-// attribute.  Returns and OomParser object and an error
-func New() (*OomParser, error) {
+// opens the first kernel log file available, or journalctl when there is
+// none. The log files are read from their end unless fromStart is set.
+func openKernelLog(fromStart bool) (*bufio.Reader, error) {
 	systemFile, err := getSystemFile()
 	if err != nil {
 		return trySystemd()
@@ -214,7 +422,37 @@ func New() (*OomParser, error) {
 	if err != nil {
 		return trySystemd()
 	}
+	if !fromStart {
+		if _, err := file.Seek(0, os.SEEK_END); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return bufio.NewReader(file), nil
+}
+
+// initializes an OomParser object, which reads /dev/kmsg when possible and
+// falls back to kernel log files and then to journalctl. The OOMs already in
+// the kernel log are reported too when fromStart is set. Returns an OomParser
+// object and an error
+func New(fromStart bool) (*OomParser, error) {
+	kmsg, err := openKmsg(fromStart)
+	if err == nil {
+		glog.Infof("OOM parser using %q", kmsgPath)
+		return &OomParser{
+			kmsg: kmsg,
+			fallback: func() (*bufio.Reader, error) {
+				return openKernelLog(fromStart)
+			},
+		}, nil
+	}
+	glog.Infof("OOM parser could not use %q: %v", kmsgPath, err)
+
+	ioreader, err := openKernelLog(fromStart)
+	if err != nil {
+		return nil, err
+	}
 	return &OomParser{
-		ioreader: bufio.NewReader(file),
+		ioreader: ioreader,
 	}, nil
 }
@@ -164,3 +164,53 @@ func mockOomParser(sysFile string, t *testing.T) *OomParser {
 		ioreader: bufio.NewReader(file),
 	}
 }
+
+func TestGetOomDetails(t *testing.T) {
+	currentOomInstance := new(OomInstance)
+	getOomDetails("Jan  5 15:19:27 kernel: [ 5864.708440] memorymonster invoked oom-killer: gfp_mask=0xd0, order=-1, oom_score_adj=0", currentOomInstance)
+	getOomDetails("Jan  5 15:19:27 kernel: [ 5864.708495] memory+swap: usage 0kB, limit 18014398509481983kB, failcnt 0", currentOomInstance)
+	getOomDetails("Jan  5 15:19:27 kernel: [ 5864.708495] memory: usage 980kB, limit 1024kB, failcnt 42", currentOomInstance)
+	expected := OomInstance{
+		MemoryUsage:   980 * 1024,
+		MemoryLimit:   1024 * 1024,
+		MemoryFailcnt: 42,
+		GfpMask:       "0xd0",
+		Order:         -1,
+	}
+	if !reflect.DeepEqual(expected, *currentOomInstance) {
+		t.Errorf("getOomDetails should have set %+v, not %+v", expected, *currentOomInstance)
+	}
+}
+
+func TestGetTask(t *testing.T) {
+	columns := getTaskColumns("Jan 28 19:58:45 localhost kernel: [  455.630787] [ pid ]   uid  tgid total_vm      rss nr_ptes swapents oom_score_adj name")
+	if len(columns) != 8 {
+		t.Fatalf("getTaskColumns should have returned 8 columns, not %v", columns)
+	}
+	if getTaskColumns(startLine) != nil {
+		t.Errorf("bad line fed to getTaskColumns should return nil")
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	task, ok := getTask("Jan 28 19:58:45 localhost kernel: [  455.633290] [  293]     0   293    12802      154      28        0         -1000 systemd udevd", columns)
+	if !ok {
+		t.Fatalf("task line fed to getTask should return true")
+	}
+	expected := OomTask{
+		Pid:         293,
+		Tgid:        293,
+		TotalVm:     12802 * pageSize,
+		Rss:         154 * pageSize,
+		OomScoreAdj: -1000,
+		Name:        "systemd udevd",
+	}
+	if !reflect.DeepEqual(expected, task) {
+		t.Errorf("getTask should have returned %+v, not %+v", expected, task)
+	}
+
+	for _, line := range []string{endLine, "[  293]     0   293", "[  293]     0   293    12802      x      28        0         0 bad"} {
+		if _, ok := getTask(line, columns); ok {
+			t.Errorf("bad line %q fed to getTask should return false", line)
+		}
+	}
+}